@@ -8,9 +8,18 @@ type ExprVisitor interface {
 	VisitAssignExpr(expt *Assign) interface{}
 	VisitBinaryExpr(expt *Binary) interface{}
 	VisitCallExpr(expt *Call) interface{}
+	VisitGetExpr(expt *Get) interface{}
 	VisitGroupingExpr(expt *Grouping) interface{}
+	VisitIndexExpr(expt *Index) interface{}
+	VisitListExpr(expt *List) interface{}
+	VisitLambdaExpr(expt *Lambda) interface{}
 	VisitLiteralExpr(expt *Literal) interface{}
 	VisitLogicalExpr(expt *Logical) interface{}
+	VisitMapExpr(expt *Map) interface{}
+	VisitSetExpr(expt *Set) interface{}
+	VisitSetIndexExpr(expt *SetIndex) interface{}
+	VisitSuperExpr(expt *Super) interface{}
+	VisitThisExpr(expt *This) interface{}
 	VisitUnaryExpr(expt *Unary) interface{}
 	VisitVariableExpr(expt *Variable) interface{}
 }
@@ -44,6 +53,15 @@ func (c *Call) Accept(visitor ExprVisitor) interface{} {
 	return visitor.VisitCallExpr(c)
 }
 
+type Get struct {
+	Object Expr
+	Name   Token
+}
+
+func (g *Get) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitGetExpr(g)
+}
+
 type Grouping struct {
 	Expression Expr
 }
@@ -52,7 +70,36 @@ func (g *Grouping) Accept(visitor ExprVisitor) interface{} {
 	return visitor.VisitGroupingExpr(g)
 }
 
+type Index struct {
+	Object  Expr
+	Bracket Token
+	Index   Expr
+}
+
+func (i *Index) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitIndexExpr(i)
+}
+
+type List struct {
+	Bracket  Token
+	Elements []Expr
+}
+
+func (l *List) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitListExpr(l)
+}
+
+type Lambda struct {
+	Keyword  Token
+	Function *Function
+}
+
+func (l *Lambda) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitLambdaExpr(l)
+}
+
 type Literal struct {
+	Token Token
 	Value interface{}
 }
 
@@ -70,6 +117,54 @@ func (l *Logical) Accept(visitor ExprVisitor) interface{} {
 	return visitor.VisitLogicalExpr(l)
 }
 
+type Map struct {
+	Brace  Token
+	Keys   []Expr
+	Values []Expr
+}
+
+func (m *Map) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitMapExpr(m)
+}
+
+type Set struct {
+	Object Expr
+	Name   Token
+	Value  Expr
+}
+
+func (s *Set) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitSetExpr(s)
+}
+
+type SetIndex struct {
+	Object  Expr
+	Bracket Token
+	Index   Expr
+	Value   Expr
+}
+
+func (s *SetIndex) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitSetIndexExpr(s)
+}
+
+type Super struct {
+	Keyword Token
+	Method  Token
+}
+
+func (s *Super) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitSuperExpr(s)
+}
+
+type This struct {
+	Keyword Token
+}
+
+func (t *This) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitThisExpr(t)
+}
+
 type Unary struct {
 	Right    Expr
 	Operator Token
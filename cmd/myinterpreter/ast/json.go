@@ -0,0 +1,271 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// JSONPrinter writes syntax trees as JSON for other tools. Every node has a
+// "kind" naming its type, tokens carry their positions, and variables the
+// resolver found locally carry their "depth".
+type JSONPrinter struct {
+	// Depth reports how many scopes out the variable an expression refers
+	// to is declared, as found by the resolver. It may be nil.
+	Depth func(expr Expr) (int, bool)
+}
+
+// field and object keep the keys of a node in the order they are written,
+// so that "kind" comes first.
+type field struct {
+	key   string
+	value interface{}
+}
+
+type object []field
+
+func (o object) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, field := range o {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, _ := json.Marshal(field.key)
+		value, err := json.Marshal(field.value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// Print returns the program read from the file name as an indented JSON
+// document.
+func (p *JSONPrinter) Print(name string, statements []Stmt) ([]byte, error) {
+	program := object{{"kind", "Program"}, {"file", name}, {"statements", p.stmts(statements)}}
+	return json.MarshalIndent(program, "", "  ")
+}
+
+// PrintExpression returns a lone expression as an indented JSON document.
+func (p *JSONPrinter) PrintExpression(expr Expr) ([]byte, error) {
+	return json.MarshalIndent(p.expr(expr), "", "  ")
+}
+
+func (p *JSONPrinter) stmt(stmt Stmt) interface{} {
+	if stmt == nil {
+		return nil
+	}
+	return stmt.Accept(p)
+}
+
+func (p *JSONPrinter) stmts(statements []Stmt) []interface{} {
+	nodes := []interface{}{}
+	for _, stmt := range statements {
+		nodes = append(nodes, p.stmt(stmt))
+	}
+	return nodes
+}
+
+func (p *JSONPrinter) expr(expr Expr) interface{} {
+	if expr == nil {
+		return nil
+	}
+	return expr.Accept(p)
+}
+
+func (p *JSONPrinter) exprs(exprs []Expr) []interface{} {
+	nodes := []interface{}{}
+	for _, expr := range exprs {
+		nodes = append(nodes, p.expr(expr))
+	}
+	return nodes
+}
+
+// token describes a token and where it is, or is null for the tokens that
+// the parser leaves empty, like the name of an anonymous function.
+func (p *JSONPrinter) token(token Token) interface{} {
+	if token.Type == "" {
+		return nil
+	}
+	return object{
+		{"type", token.Type},
+		{"lexeme", token.Lexeme},
+		{"line", token.Line},
+		{"column", token.Column},
+		{"offset", token.Offset},
+		{"length", token.Length},
+	}
+}
+
+func (p *JSONPrinter) tokens(tokens []Token) []interface{} {
+	nodes := []interface{}{}
+	for _, token := range tokens {
+		nodes = append(nodes, p.token(token))
+	}
+	return nodes
+}
+
+// resolved adds the depth of a variable reference to its node. Globals have
+// none.
+func (p *JSONPrinter) resolved(node object, expr Expr) object {
+	if p.Depth == nil {
+		return node
+	}
+	if depth, ok := p.Depth(expr); ok {
+		node = append(node, field{"depth", depth})
+	}
+	return node
+}
+
+func (p *JSONPrinter) function(function *Function) object {
+	return object{
+		{"kind", "Function"},
+		{"name", p.token(function.Name)},
+		{"params", p.tokens(function.Params)},
+		{"body", p.stmts(function.Body)},
+	}
+}
+
+func (p *JSONPrinter) VisitAssignExpr(expr *Assign) interface{} {
+	return p.resolved(object{{"kind", "Assign"}, {"name", p.token(expr.Name)}, {"value", p.expr(expr.Value)}}, expr)
+}
+
+func (p *JSONPrinter) VisitBinaryExpr(expr *Binary) interface{} {
+	return object{{"kind", "Binary"}, {"operator", p.token(expr.Operator)}, {"left", p.expr(expr.Left)}, {"right", p.expr(expr.Right)}}
+}
+
+func (p *JSONPrinter) VisitCallExpr(expr *Call) interface{} {
+	return object{{"kind", "Call"}, {"callee", p.expr(expr.Callee)}, {"paren", p.token(expr.Paren)}, {"arguments", p.exprs(expr.Arguments)}}
+}
+
+func (p *JSONPrinter) VisitGetExpr(expr *Get) interface{} {
+	return object{{"kind", "Get"}, {"object", p.expr(expr.Object)}, {"name", p.token(expr.Name)}}
+}
+
+func (p *JSONPrinter) VisitGroupingExpr(expr *Grouping) interface{} {
+	return object{{"kind", "Grouping"}, {"expression", p.expr(expr.Expression)}}
+}
+
+func (p *JSONPrinter) VisitIndexExpr(expr *Index) interface{} {
+	return object{{"kind", "Index"}, {"object", p.expr(expr.Object)}, {"bracket", p.token(expr.Bracket)}, {"index", p.expr(expr.Index)}}
+}
+
+func (p *JSONPrinter) VisitLambdaExpr(expr *Lambda) interface{} {
+	return object{{"kind", "Lambda"}, {"keyword", p.token(expr.Keyword)}, {"function", p.function(expr.Function)}}
+}
+
+func (p *JSONPrinter) VisitListExpr(expr *List) interface{} {
+	return object{{"kind", "List"}, {"bracket", p.token(expr.Bracket)}, {"elements", p.exprs(expr.Elements)}}
+}
+
+func (p *JSONPrinter) VisitLiteralExpr(expr *Literal) interface{} {
+	return object{{"kind", "Literal"}, {"token", p.token(expr.Token)}, {"value", expr.Value}}
+}
+
+func (p *JSONPrinter) VisitLogicalExpr(expr *Logical) interface{} {
+	return object{{"kind", "Logical"}, {"operator", p.token(expr.Operator)}, {"left", p.expr(expr.Left)}, {"right", p.expr(expr.Right)}}
+}
+
+func (p *JSONPrinter) VisitMapExpr(expr *Map) interface{} {
+	return object{{"kind", "Map"}, {"brace", p.token(expr.Brace)}, {"keys", p.exprs(expr.Keys)}, {"values", p.exprs(expr.Values)}}
+}
+
+func (p *JSONPrinter) VisitSetExpr(expr *Set) interface{} {
+	return object{{"kind", "Set"}, {"object", p.expr(expr.Object)}, {"name", p.token(expr.Name)}, {"value", p.expr(expr.Value)}}
+}
+
+func (p *JSONPrinter) VisitSetIndexExpr(expr *SetIndex) interface{} {
+	return object{{"kind", "SetIndex"}, {"object", p.expr(expr.Object)}, {"bracket", p.token(expr.Bracket)}, {"index", p.expr(expr.Index)}, {"value", p.expr(expr.Value)}}
+}
+
+func (p *JSONPrinter) VisitSuperExpr(expr *Super) interface{} {
+	return p.resolved(object{{"kind", "Super"}, {"keyword", p.token(expr.Keyword)}, {"method", p.token(expr.Method)}}, expr)
+}
+
+func (p *JSONPrinter) VisitThisExpr(expr *This) interface{} {
+	return p.resolved(object{{"kind", "This"}, {"keyword", p.token(expr.Keyword)}}, expr)
+}
+
+func (p *JSONPrinter) VisitUnaryExpr(expr *Unary) interface{} {
+	return object{{"kind", "Unary"}, {"operator", p.token(expr.Operator)}, {"right", p.expr(expr.Right)}}
+}
+
+func (p *JSONPrinter) VisitVariableExpr(expr *Variable) interface{} {
+	return p.resolved(object{{"kind", "Variable"}, {"name", p.token(expr.Name)}}, expr)
+}
+
+func (p *JSONPrinter) VisitBlockStmt(stmt *Block) interface{} {
+	return object{{"kind", "Block"}, {"statements", p.stmts(stmt.Statements)}}
+}
+
+func (p *JSONPrinter) VisitBreakStmt(stmt *Break) interface{} {
+	return object{{"kind", "Break"}, {"keyword", p.token(stmt.Keyword)}}
+}
+
+func (p *JSONPrinter) VisitClassStmt(stmt *Class) interface{} {
+	var superclass interface{}
+	if stmt.Superclass != nil {
+		superclass = p.expr(stmt.Superclass)
+	}
+
+	methods := []interface{}{}
+	for _, method := range stmt.Methods {
+		methods = append(methods, p.function(method))
+	}
+	return object{{"kind", "Class"}, {"name", p.token(stmt.Name)}, {"superclass", superclass}, {"methods", methods}}
+}
+
+func (p *JSONPrinter) VisitContinueStmt(stmt *Continue) interface{} {
+	return object{{"kind", "Continue"}, {"keyword", p.token(stmt.Keyword)}}
+}
+
+func (p *JSONPrinter) VisitExpressionStmt(stmt *Expression) interface{} {
+	return object{{"kind", "Expression"}, {"expression", p.expr(stmt.Expression)}}
+}
+
+func (p *JSONPrinter) VisitFunctionStmt(stmt *Function) interface{} {
+	return p.function(stmt)
+}
+
+func (p *JSONPrinter) VisitIfStmt(stmt *If) interface{} {
+	return object{{"kind", "If"}, {"condition", p.expr(stmt.Condition)}, {"then", p.stmt(stmt.ThenBranch)}, {"else", p.stmt(stmt.ElseBranch)}}
+}
+
+func (p *JSONPrinter) VisitImportStmt(stmt *Import) interface{} {
+	return object{{"kind", "Import"}, {"keyword", p.token(stmt.Keyword)}, {"path", p.token(stmt.Path)}, {"alias", p.token(stmt.Alias)}, {"names", p.tokens(stmt.Names)}}
+}
+
+func (p *JSONPrinter) VisitPrintStmt(stmt *Print) interface{} {
+	return object{{"kind", "Print"}, {"keyword", p.token(stmt.Keyword)}, {"expression", p.expr(stmt.Expression)}}
+}
+
+func (p *JSONPrinter) VisitReturnStmt(stmt *Return) interface{} {
+	return object{{"kind", "Return"}, {"keyword", p.token(stmt.Keyword)}, {"value", p.expr(stmt.Value)}}
+}
+
+func (p *JSONPrinter) VisitThrowStmt(stmt *Throw) interface{} {
+	return object{{"kind", "Throw"}, {"keyword", p.token(stmt.Keyword)}, {"value", p.expr(stmt.Value)}}
+}
+
+func (p *JSONPrinter) VisitTryStmt(stmt *Try) interface{} {
+	var catch, finally interface{}
+	if stmt.Catch != nil {
+		catch = p.stmt(stmt.Catch)
+	}
+	if stmt.Finally != nil {
+		finally = p.stmt(stmt.Finally)
+	}
+	return object{{"kind", "Try"}, {"keyword", p.token(stmt.Keyword)}, {"body", p.stmt(stmt.Body)}, {"name", p.token(stmt.Name)}, {"catch", catch}, {"finally", finally}}
+}
+
+func (p *JSONPrinter) VisitVarStmt(stmt *Var) interface{} {
+	return object{{"kind", "Var"}, {"name", p.token(stmt.Name)}, {"initializer", p.expr(stmt.Initializer)}}
+}
+
+func (p *JSONPrinter) VisitWhileStmt(stmt *While) interface{} {
+	return object{{"kind", "While"}, {"keyword", p.token(stmt.Keyword)}, {"condition", p.expr(stmt.Condition)}, {"body", p.stmt(stmt.Body)}, {"increment", p.expr(stmt.Increment)}}
+}
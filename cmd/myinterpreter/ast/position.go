@@ -0,0 +1,76 @@
+package ast
+
+// Start returns the token that locates a statement: its keyword, or its name
+// for declarations. Blocks have no position of their own.
+func Start(stmt Stmt) (Token, bool) {
+	switch stmt := stmt.(type) {
+	case *Break:
+		return stmt.Keyword, true
+	case *Class:
+		return stmt.Name, true
+	case *Continue:
+		return stmt.Keyword, true
+	case *Expression:
+		return First(stmt.Expression), true
+	case *Function:
+		return stmt.Name, true
+	case *If:
+		return First(stmt.Condition), true
+	case *Import:
+		return stmt.Keyword, true
+	case *Print:
+		return stmt.Keyword, true
+	case *Return:
+		return stmt.Keyword, true
+	case *Throw:
+		return stmt.Keyword, true
+	case *Try:
+		return stmt.Keyword, true
+	case *Var:
+		return stmt.Name, true
+	case *While:
+		return stmt.Keyword, true
+	}
+	return Token{}, false
+}
+
+// First returns the leftmost token of an expression.
+func First(expr Expr) Token {
+	switch expr := expr.(type) {
+	case *Assign:
+		return expr.Name
+	case *Binary:
+		return First(expr.Left)
+	case *Call:
+		return First(expr.Callee)
+	case *Get:
+		return First(expr.Object)
+	case *Grouping:
+		return First(expr.Expression)
+	case *Index:
+		return First(expr.Object)
+	case *Lambda:
+		return expr.Keyword
+	case *List:
+		return expr.Bracket
+	case *Literal:
+		return expr.Token
+	case *Logical:
+		return First(expr.Left)
+	case *Map:
+		return expr.Brace
+	case *Set:
+		return First(expr.Object)
+	case *SetIndex:
+		return First(expr.Object)
+	case *Super:
+		return expr.Keyword
+	case *This:
+		return expr.Keyword
+	case *Unary:
+		return expr.Operator
+	case *Variable:
+		return expr.Name
+	}
+	return Token{}
+}
@@ -4,9 +4,15 @@ import (
 	"fmt"
 )
 
-type AstPrinter struct{}
+type AstPrinter struct {
+	// program is set while printing whole programs, where call arguments go
+	// inside the parentheses of the call. Lone expressions keep the original
+	// layout, with the arguments after them.
+	program bool
+}
 
 func (p *AstPrinter) Print(statements []Stmt) string {
+	p.program = true
 	var result string
 	for _, stmt := range statements {
 		result += stmt.Accept(p).(string) + "\n"
@@ -15,6 +21,7 @@ func (p *AstPrinter) Print(statements []Stmt) string {
 }
 
 func (p *AstPrinter) PrintExpression(expr Expr) string {
+	p.program = false
 	return expr.Accept(p).(string)
 }
 
@@ -31,6 +38,10 @@ func (p *AstPrinter) VisitVariableExpr(expr *Variable) interface{} {
 }
 
 func (p *AstPrinter) VisitCallExpr(expr *Call) interface{} {
+	if p.program {
+		return p.parenthesize("call", append([]Expr{expr.Callee}, expr.Arguments...)...)
+	}
+
 	var result string
 	result += p.parenthesize("call", expr.Callee)
 	for _, arg := range expr.Arguments {
@@ -39,6 +50,42 @@ func (p *AstPrinter) VisitCallExpr(expr *Call) interface{} {
 	return result
 }
 
+func (p *AstPrinter) VisitGetExpr(expr *Get) interface{} {
+	return p.parenthesize("get "+expr.Name.Lexeme, expr.Object)
+}
+
+func (p *AstPrinter) VisitSetExpr(expr *Set) interface{} {
+	return p.parenthesize("set "+expr.Name.Lexeme, expr.Object, expr.Value)
+}
+
+func (p *AstPrinter) VisitListExpr(expr *List) interface{} {
+	return p.parenthesize("list", expr.Elements...)
+}
+
+func (p *AstPrinter) VisitMapExpr(expr *Map) interface{} {
+	var entries []Expr
+	for i, key := range expr.Keys {
+		entries = append(entries, key, expr.Values[i])
+	}
+	return p.parenthesize("map", entries...)
+}
+
+func (p *AstPrinter) VisitIndexExpr(expr *Index) interface{} {
+	return p.parenthesize("index", expr.Object, expr.Index)
+}
+
+func (p *AstPrinter) VisitSetIndexExpr(expr *SetIndex) interface{} {
+	return p.parenthesize("set-index", expr.Object, expr.Index, expr.Value)
+}
+
+func (p *AstPrinter) VisitSuperExpr(expr *Super) interface{} {
+	return "(super " + expr.Method.Lexeme + ")"
+}
+
+func (p *AstPrinter) VisitThisExpr(expr *This) interface{} {
+	return "this"
+}
+
 func (p *AstPrinter) VisitGroupingExpr(expr *Grouping) interface{} {
 	return p.parenthesize("group", expr.Expression)
 }
@@ -69,9 +116,31 @@ func (p *AstPrinter) VisitExpressionStmt(stmt *Expression) interface{} {
 	return p.parenthesize("expr", stmt.Expression)
 }
 
+func (p *AstPrinter) VisitClassStmt(stmt *Class) interface{} {
+	result := "(class " + stmt.Name.Lexeme
+	if stmt.Superclass != nil {
+		result += " < " + stmt.Superclass.Name.Lexeme
+	}
+
+	for _, method := range stmt.Methods {
+		result += " " + method.Accept(p).(string)
+	}
+
+	result += ")"
+	return result
+}
+
 func (p *AstPrinter) VisitFunctionStmt(stmt *Function) interface{} {
+	return p.function("fun "+stmt.Name.Lexeme, stmt)
+}
+
+func (p *AstPrinter) VisitLambdaExpr(expr *Lambda) interface{} {
+	return p.function("lambda", expr.Function)
+}
+
+func (p *AstPrinter) function(head string, stmt *Function) string {
 	var result string
-	result += "(fun " + stmt.Name.Lexeme + " ("
+	result += "(" + head + " ("
 
 	// Add parameters to the function definition.
 	for i, param := range stmt.Params {
@@ -80,11 +149,11 @@ func (p *AstPrinter) VisitFunctionStmt(stmt *Function) interface{} {
 		}
 		result += param.Lexeme
 	}
-	result += ") "
+	result += ")"
 
 	// Print the function body.
 	for _, bodyStmt := range stmt.Body {
-		result += bodyStmt.Accept(p).(string) + " "
+		result += " " + bodyStmt.Accept(p).(string)
 	}
 	result += ")"
 	return result
@@ -131,7 +200,45 @@ func (p *AstPrinter) VisitIfStmt(stmt *If) interface{} {
 }
 
 func (p *AstPrinter) VisitWhileStmt(stmt *While) interface{} {
-	return p.parenthesize("while", stmt.Condition) + " " + stmt.Body.Accept(p).(string)
+	result := p.parenthesize("while", stmt.Condition) + " " + stmt.Body.Accept(p).(string)
+	if stmt.Increment != nil {
+		result += " " + p.parenthesize("increment", stmt.Increment)
+	}
+	return result
+}
+
+func (p *AstPrinter) VisitImportStmt(stmt *Import) interface{} {
+	result := "(import " + stmt.Path.Lexeme
+	if stmt.Alias.Lexeme != "" {
+		result += " as " + stmt.Alias.Lexeme
+	}
+	for _, name := range stmt.Names {
+		result += " " + name.Lexeme
+	}
+	return result + ")"
+}
+
+func (p *AstPrinter) VisitThrowStmt(stmt *Throw) interface{} {
+	return p.parenthesize("throw", stmt.Value)
+}
+
+func (p *AstPrinter) VisitTryStmt(stmt *Try) interface{} {
+	result := "(try " + stmt.Body.Accept(p).(string)
+	if stmt.Catch != nil {
+		result += " (catch " + stmt.Name.Lexeme + " " + stmt.Catch.Accept(p).(string) + ")"
+	}
+	if stmt.Finally != nil {
+		result += " (finally " + stmt.Finally.Accept(p).(string) + ")"
+	}
+	return result + ")"
+}
+
+func (p *AstPrinter) VisitBreakStmt(stmt *Break) interface{} {
+	return "(break)"
+}
+
+func (p *AstPrinter) VisitContinueStmt(stmt *Continue) interface{} {
+	return "(continue)"
 }
 
 func (p *AstPrinter) VisitAssignExpr(expr *Assign) interface{} {
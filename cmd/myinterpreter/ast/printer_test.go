@@ -0,0 +1,83 @@
+package ast_test
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/distolma/golox/cmd/myinterpreter/ast"
+	logerror "github.com/distolma/golox/cmd/myinterpreter/log_error"
+	"github.com/distolma/golox/cmd/myinterpreter/parser"
+	"github.com/distolma/golox/cmd/myinterpreter/scanner"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// TestPrintGolden prints every program in testdata/printer and compares the
+// result with the .sexpr file beside it.
+func TestPrintGolden(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "printer", "*.lox"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no programs in testdata/printer")
+	}
+
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			source, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			log := &logerror.LogError{Output: io.Discard}
+			statements := parser.NewParser(scanner.NewScanner(string(source), log).ScanTokens(), log).Parse()
+			if log.HadError {
+				t.Fatalf("parsing failed: %v", log.Reports)
+			}
+			printer := ast.AstPrinter{}
+			got := printer.Print(statements)
+
+			golden := strings.TrimSuffix(path, ".lox") + ".sexpr"
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("Print() =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+// TestPrintExpression checks the layout of lone expressions, which keeps
+// call arguments after the call.
+func TestPrintExpression(t *testing.T) {
+	tests := map[string]string{
+		"(1 + 2) * -3": "(* (group (+ 1.0 2.0)) (- 3.0))",
+		`"a" == nil`:   "(== a nil)",
+		"f(1, 2)":      "(call f) 1.0 2.0",
+	}
+
+	for source, want := range tests {
+		log := &logerror.LogError{Output: io.Discard}
+		expression := parser.NewParser(scanner.NewScanner(source, log).ScanTokens(), log).ParseExpression()
+		if log.HadError {
+			t.Fatalf("parsing %q failed: %v", source, log.Reports)
+		}
+
+		printer := ast.AstPrinter{}
+		if got := printer.PrintExpression(expression); got != want {
+			t.Errorf("PrintExpression(%q) = %q, want %q", source, got, want)
+		}
+	}
+}
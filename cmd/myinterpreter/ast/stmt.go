@@ -6,23 +6,57 @@ type Stmt interface {
 
 type StmtVisitor interface {
 	VisitBlockStmt(expt *Block) interface{}
+	VisitBreakStmt(expt *Break) interface{}
+	VisitClassStmt(expt *Class) interface{}
+	VisitContinueStmt(expt *Continue) interface{}
 	VisitExpressionStmt(expt *Expression) interface{}
 	VisitFunctionStmt(expt *Function) interface{}
 	VisitIfStmt(expt *If) interface{}
+	VisitImportStmt(expt *Import) interface{}
 	VisitPrintStmt(expt *Print) interface{}
 	VisitReturnStmt(expt *Return) interface{}
+	VisitThrowStmt(expt *Throw) interface{}
+	VisitTryStmt(expt *Try) interface{}
 	VisitVarStmt(expt *Var) interface{}
 	VisitWhileStmt(expt *While) interface{}
 }
 
 type Block struct {
 	Statements []Stmt
+	Brace      Token
 }
 
 func (b *Block) Accept(visitor StmtVisitor) interface{} {
 	return visitor.VisitBlockStmt(b)
 }
 
+type Break struct {
+	Keyword Token
+}
+
+func (b *Break) Accept(visitor StmtVisitor) interface{} {
+	return visitor.VisitBreakStmt(b)
+}
+
+type Class struct {
+	Name       Token
+	Superclass *Variable
+	Methods    []*Function
+	Brace      Token
+}
+
+func (c *Class) Accept(visitor StmtVisitor) interface{} {
+	return visitor.VisitClassStmt(c)
+}
+
+type Continue struct {
+	Keyword Token
+}
+
+func (c *Continue) Accept(visitor StmtVisitor) interface{} {
+	return visitor.VisitContinueStmt(c)
+}
+
 type Expression struct {
 	Expression Expr
 }
@@ -35,6 +69,7 @@ type Function struct {
 	Name   Token
 	Params []Token
 	Body   []Stmt
+	Brace  Token
 }
 
 func (f *Function) Accept(visitor StmtVisitor) interface{} {
@@ -51,7 +86,19 @@ func (i *If) Accept(visitor StmtVisitor) interface{} {
 	return visitor.VisitIfStmt(i)
 }
 
+type Import struct {
+	Keyword Token
+	Path    Token
+	Alias   Token
+	Names   []Token
+}
+
+func (i *Import) Accept(visitor StmtVisitor) interface{} {
+	return visitor.VisitImportStmt(i)
+}
+
 type Print struct {
+	Keyword    Token
 	Expression Expr
 }
 
@@ -68,6 +115,27 @@ func (r *Return) Accept(visitor StmtVisitor) interface{} {
 	return visitor.VisitReturnStmt(r)
 }
 
+type Throw struct {
+	Keyword Token
+	Value   Expr
+}
+
+func (t *Throw) Accept(visitor StmtVisitor) interface{} {
+	return visitor.VisitThrowStmt(t)
+}
+
+type Try struct {
+	Keyword Token
+	Body    *Block
+	Name    Token
+	Catch   *Block
+	Finally *Block
+}
+
+func (t *Try) Accept(visitor StmtVisitor) interface{} {
+	return visitor.VisitTryStmt(t)
+}
+
 type Var struct {
 	Initializer Expr
 	Name        Token
@@ -78,8 +146,10 @@ func (v *Var) Accept(visitor StmtVisitor) interface{} {
 }
 
 type While struct {
+	Keyword   Token
 	Condition Expr
 	Body      Stmt
+	Increment Expr
 }
 
 func (w *While) Accept(visitor StmtVisitor) interface{} {
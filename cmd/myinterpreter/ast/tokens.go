@@ -8,18 +8,22 @@ type TokenType string
 
 const (
 	// Single-character tokens
-	TLeftParen  TokenType = "LEFT_PAREN"
-	TRightParen TokenType = "RIGHT_PAREN"
-	TLeftBrace  TokenType = "LEFT_BRACE"
-	TRightBrace TokenType = "RIGHT_BRACE"
-	TComma      TokenType = "COMMA"
-	TDot        TokenType = "DOT"
-	TMinus      TokenType = "MINUS"
-	TPlus       TokenType = "PLUS"
-	TSemicolon  TokenType = "SEMICOLON"
-	TSlash      TokenType = "SLASH"
-	TStar       TokenType = "STAR"
+	TLeftParen    TokenType = "LEFT_PAREN"
+	TRightParen   TokenType = "RIGHT_PAREN"
+	TLeftBrace    TokenType = "LEFT_BRACE"
+	TRightBrace   TokenType = "RIGHT_BRACE"
+	TLeftBracket  TokenType = "LEFT_BRACKET"
+	TRightBracket TokenType = "RIGHT_BRACKET"
+	TComma        TokenType = "COMMA"
+	TColon        TokenType = "COLON"
+	TDot          TokenType = "DOT"
+	TMinus        TokenType = "MINUS"
+	TPlus         TokenType = "PLUS"
+	TSemicolon    TokenType = "SEMICOLON"
+	TSlash        TokenType = "SLASH"
+	TStar         TokenType = "STAR"
 	// One or two character tokens
+	TArrow        TokenType = "ARROW"
 	TBang         TokenType = "BANG"
 	TBangEqual    TokenType = "BANG_EQUAL"
 	TEqual        TokenType = "EQUAL"
@@ -33,31 +37,75 @@ const (
 	TString     TokenType = "STRING"
 	TNumber     TokenType = "NUMBER"
 	// Keywords
-	TAnd    TokenType = "AND"
-	TClass  TokenType = "CLASS"
-	TElse   TokenType = "ELSE"
-	TFalse  TokenType = "FALSE"
-	TFun    TokenType = "FUN"
-	TFor    TokenType = "FOR"
-	TIf     TokenType = "IF"
-	TNil    TokenType = "NIL"
-	TOr     TokenType = "OR"
-	TPrint  TokenType = "PRINT"
-	TReturn TokenType = "RETURN"
-	TSuper  TokenType = "SUPER"
-	TThis   TokenType = "THIS"
-	TTrue   TokenType = "TRUE"
-	TVar    TokenType = "VAR"
-	TWhile  TokenType = "WHILE"
+	TAnd      TokenType = "AND"
+	TBreak    TokenType = "BREAK"
+	TCatch    TokenType = "CATCH"
+	TClass    TokenType = "CLASS"
+	TContinue TokenType = "CONTINUE"
+	TElse     TokenType = "ELSE"
+	TFalse    TokenType = "FALSE"
+	TFinally  TokenType = "FINALLY"
+	TFun      TokenType = "FUN"
+	TFor      TokenType = "FOR"
+	TIf       TokenType = "IF"
+	TImport   TokenType = "IMPORT"
+	TNil      TokenType = "NIL"
+	TOr       TokenType = "OR"
+	TPrint    TokenType = "PRINT"
+	TReturn   TokenType = "RETURN"
+	TSuper    TokenType = "SUPER"
+	TThis     TokenType = "THIS"
+	TThrow    TokenType = "THROW"
+	TTrue     TokenType = "TRUE"
+	TTry      TokenType = "TRY"
+	TVar      TokenType = "VAR"
+	TWhile    TokenType = "WHILE"
 
 	EOF TokenType = "EOF"
 )
 
+// Source is a piece of Lox code and the name of the file it came from, which
+// is empty for code that didn't come from a file.
+type Source struct {
+	Name string
+	Text string
+}
+
 type Token struct {
 	Literal interface{}
 	Lexeme  string
 	Type    TokenType
 	Line    int
+	// Column is the 1-based position of the token's first character within
+	// its line, counted in runes. It is zero for tokens made up by the
+	// compiler.
+	Column int
+	// Offset and Length locate the lexeme in Source.Text, in bytes.
+	Offset int
+	Length int
+	Source *Source
+	// Trivia is the comments and blank lines between the previous token and
+	// this one.
+	Trivia []Trivia
+}
+
+type TriviaKind int
+
+const (
+	TriviaComment TriviaKind = iota
+	TriviaBlankLine
+)
+
+// Trivia is source text that doesn't change the meaning of the program but
+// is kept for tools that rewrite it.
+type Trivia struct {
+	Kind TriviaKind
+	// Text is the comment including its '//'; it is empty for blank lines.
+	Text   string
+	Line   int
+	Offset int
+	// Trailing is set on a comment that follows a token on the same line.
+	Trailing bool
 }
 
 func (t *Token) String() string {
@@ -0,0 +1,22 @@
+package ast
+
+import "testing"
+
+func TestTokenString(t *testing.T) {
+	tests := []struct {
+		token Token
+		want  string
+	}{
+		{Token{Type: TIdentifier, Lexeme: "foo"}, "IDENTIFIER foo null"},
+		{Token{Type: TNumber, Lexeme: "42", Literal: 42.0}, "NUMBER 42 42.0"},
+		{Token{Type: TNumber, Lexeme: "1.50", Literal: 1.5}, "NUMBER 1.50 1.5"},
+		{Token{Type: TString, Lexeme: `"hi"`, Literal: "hi"}, `STRING "hi" hi`},
+		{Token{Type: TString, Lexeme: "\"a\nb\"", Literal: "a\nb"}, "STRING \"a\nb\" a\nb"},
+	}
+
+	for _, test := range tests {
+		if got := test.token.String(); got != test.want {
+			t.Errorf("String() = %q, want %q", got, test.want)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package collection
+
+import (
+	"errors"
+
+	"github.com/distolma/golox/cmd/myinterpreter/limits"
+)
+
+var errNotIndexable = errors.New("Only lists and maps can be indexed.")
+
+// Index reads object[index] for a list or map.
+func Index(object interface{}, index interface{}) (interface{}, error) {
+	switch object := object.(type) {
+	case *List:
+		return object.Get(index)
+	case *Map:
+		return object.Get(index)
+	}
+	return nil, errNotIndexable
+}
+
+// SetIndex stores value in object[index] for a list or map, charging meter
+// for the entry when it adds a key to a map.
+func SetIndex(meter *limits.Meter, object interface{}, index interface{}, value interface{}) error {
+	switch object := object.(type) {
+	case *List:
+		return object.Set(index, value)
+	case *Map:
+		if !object.Has(index) {
+			if violation := meter.Allocate(limits.SizeEntry); violation != nil {
+				return violation
+			}
+		}
+		return object.Set(index, value)
+	}
+	return errNotIndexable
+}
@@ -0,0 +1,96 @@
+package collection
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// List is the Lox list type shared by both backends. Lists are mutable and
+// compared by identity.
+type List struct {
+	Elements []interface{}
+}
+
+func NewList(elements []interface{}) *List {
+	return &List{Elements: elements}
+}
+
+// Get returns the element at index; negative indices count from the end.
+func (l *List) Get(index interface{}) (interface{}, error) {
+	i, err := l.position(index)
+	if err != nil {
+		return nil, err
+	}
+	return l.Elements[i], nil
+}
+
+func (l *List) Set(index interface{}, value interface{}) error {
+	i, err := l.position(index)
+	if err != nil {
+		return err
+	}
+	l.Elements[i] = value
+	return nil
+}
+
+func (l *List) position(index interface{}) (int, error) {
+	number, ok := index.(float64)
+	if !ok || number != math.Trunc(number) {
+		return 0, errors.New("List index must be an integer.")
+	}
+
+	i := int(number)
+	if i < 0 {
+		i += len(l.Elements)
+	}
+	if i < 0 || i >= len(l.Elements) {
+		return 0, errors.New("List index out of range.")
+	}
+	return i, nil
+}
+
+func (l *List) String() string {
+	return format(l, make(map[interface{}]bool))
+}
+
+// escaper writes strings inside collections back as they would be written in
+// source.
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\r", `\r`)
+
+// format prints value as it appears inside a collection, with strings quoted
+// and collections that contain themselves elided.
+func format(value interface{}, seen map[interface{}]bool) string {
+	switch value := value.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return `"` + escaper.Replace(value) + `"`
+	case *List:
+		if seen[value] {
+			return "[...]"
+		}
+		seen[value] = true
+		defer delete(seen, value)
+
+		elements := make([]string, len(value.Elements))
+		for i, element := range value.Elements {
+			elements[i] = format(element, seen)
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+	case *Map:
+		if seen[value] {
+			return "{...}"
+		}
+		seen[value] = true
+		defer delete(seen, value)
+
+		entries := make([]string, len(value.keys))
+		for i, key := range value.keys {
+			entries[i] = format(key, seen) + ": " + format(value.entries[key], seen)
+		}
+		return "{" + strings.Join(entries, ", ") + "}"
+	}
+	return fmt.Sprint(value)
+}
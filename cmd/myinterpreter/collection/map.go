@@ -0,0 +1,92 @@
+package collection
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Map is the Lox map type shared by both backends. Keys are numbers, strings,
+// booleans or nil and are matched like ==, which Go map keys of type
+// interface{} already do. Entries keep their insertion order.
+type Map struct {
+	entries map[interface{}]interface{}
+	keys    []interface{}
+}
+
+func NewMap() *Map {
+	return &Map{entries: make(map[interface{}]interface{})}
+}
+
+func (m *Map) Len() int {
+	return len(m.keys)
+}
+
+// Keys returns the keys in insertion order.
+func (m *Map) Keys() []interface{} {
+	return append([]interface{}(nil), m.keys...)
+}
+
+func (m *Map) Values() []interface{} {
+	values := make([]interface{}, len(m.keys))
+	for i, key := range m.keys {
+		values[i] = m.entries[key]
+	}
+	return values
+}
+
+func (m *Map) Has(key interface{}) bool {
+	_, ok := m.entries[key]
+	return ok
+}
+
+func (m *Map) Get(key interface{}) (interface{}, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+
+	value, ok := m.entries[key]
+	if !ok {
+		return nil, fmt.Errorf("Undefined key %s.", format(key, nil))
+	}
+	return value, nil
+}
+
+func (m *Map) Set(key interface{}, value interface{}) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+
+	if _, ok := m.entries[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.entries[key] = value
+	return nil
+}
+
+// Delete removes key and reports whether it was present.
+func (m *Map) Delete(key interface{}) bool {
+	if !m.Has(key) {
+		return false
+	}
+
+	delete(m.entries, key)
+	for i, existing := range m.keys {
+		if existing == key {
+			m.keys = append(m.keys[:i], m.keys[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+func (m *Map) String() string {
+	return format(m, make(map[interface{}]bool))
+}
+
+func checkKey(key interface{}) error {
+	switch key.(type) {
+	case nil, bool, float64, string:
+		return nil
+	}
+	return errors.New("Map keys must be numbers, strings, booleans or nil.")
+}
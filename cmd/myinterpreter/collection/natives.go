@@ -0,0 +1,147 @@
+package collection
+
+import (
+	"errors"
+	"math"
+	"unicode/utf8"
+
+	"github.com/distolma/golox/cmd/myinterpreter/limits"
+)
+
+// Native is a built-in function that both backends define as a global. The
+// meter of the run is charged for the memory it allocates; a
+// *limits.Violation is returned when that exceeds the limit.
+type Native struct {
+	Name     string
+	Arity    int
+	Function func(meter *limits.Meter, arguments []interface{}) (interface{}, error)
+}
+
+var Natives = []Native{
+	{Name: "len", Arity: 1, Function: length},
+	{Name: "push", Arity: 2, Function: push},
+	{Name: "pop", Arity: 1, Function: pop},
+	{Name: "slice", Arity: 3, Function: slice},
+	{Name: "keys", Arity: 1, Function: keys},
+	{Name: "values", Arity: 1, Function: values},
+	{Name: "has", Arity: 2, Function: has},
+	{Name: "delete", Arity: 2, Function: remove},
+}
+
+func length(meter *limits.Meter, arguments []interface{}) (interface{}, error) {
+	switch value := arguments[0].(type) {
+	case *List:
+		return float64(len(value.Elements)), nil
+	case *Map:
+		return float64(value.Len()), nil
+	case string:
+		return float64(utf8.RuneCountInString(value)), nil
+	}
+	return nil, errors.New("Can only take the length of lists, maps and strings.")
+}
+
+// push appends a value and returns the new length of the list.
+func push(meter *limits.Meter, arguments []interface{}) (interface{}, error) {
+	list, ok := arguments[0].(*List)
+	if !ok {
+		return nil, errors.New("Can only push onto lists.")
+	}
+
+	if violation := meter.Allocate(limits.SizeElement); violation != nil {
+		return nil, violation
+	}
+
+	list.Elements = append(list.Elements, arguments[1])
+	return float64(len(list.Elements)), nil
+}
+
+func pop(meter *limits.Meter, arguments []interface{}) (interface{}, error) {
+	list, ok := arguments[0].(*List)
+	if !ok {
+		return nil, errors.New("Can only pop from lists.")
+	}
+	if len(list.Elements) == 0 {
+		return nil, errors.New("Can't pop from an empty list.")
+	}
+
+	last := list.Elements[len(list.Elements)-1]
+	list.Elements[len(list.Elements)-1] = nil
+	list.Elements = list.Elements[:len(list.Elements)-1]
+	return last, nil
+}
+
+// slice copies the elements from start up to but not including end. Negative
+// bounds count from the end and bounds past either end are clamped.
+func slice(meter *limits.Meter, arguments []interface{}) (interface{}, error) {
+	list, ok := arguments[0].(*List)
+	if !ok {
+		return nil, errors.New("Can only slice lists.")
+	}
+
+	start, startOk := bound(arguments[1], len(list.Elements))
+	end, endOk := bound(arguments[2], len(list.Elements))
+	if !startOk || !endOk {
+		return nil, errors.New("Slice bounds must be integers.")
+	}
+
+	if violation := meter.Allocate(limits.SizeList + max(end-start, 0)*limits.SizeElement); violation != nil {
+		return nil, violation
+	}
+
+	elements := make([]interface{}, 0, max(end-start, 0))
+	if start < end {
+		elements = append(elements, list.Elements[start:end]...)
+	}
+	return NewList(elements), nil
+}
+
+func bound(value interface{}, length int) (int, bool) {
+	number, ok := value.(float64)
+	if !ok || number != math.Trunc(number) {
+		return 0, false
+	}
+
+	if number < 0 {
+		number += float64(length)
+	}
+	return int(max(0, min(number, float64(length)))), true
+}
+
+func keys(meter *limits.Meter, arguments []interface{}) (interface{}, error) {
+	m, ok := arguments[0].(*Map)
+	if !ok {
+		return nil, errors.New("Can only get the keys of maps.")
+	}
+	if violation := meter.Allocate(limits.SizeList + m.Len()*limits.SizeElement); violation != nil {
+		return nil, violation
+	}
+	return NewList(m.Keys()), nil
+}
+
+func values(meter *limits.Meter, arguments []interface{}) (interface{}, error) {
+	m, ok := arguments[0].(*Map)
+	if !ok {
+		return nil, errors.New("Can only get the values of maps.")
+	}
+	if violation := meter.Allocate(limits.SizeList + m.Len()*limits.SizeElement); violation != nil {
+		return nil, violation
+	}
+	return NewList(m.Values()), nil
+}
+
+func has(meter *limits.Meter, arguments []interface{}) (interface{}, error) {
+	m, ok := arguments[0].(*Map)
+	if !ok {
+		return nil, errors.New("Can only look up keys in maps.")
+	}
+	return m.Has(arguments[1]), nil
+}
+
+// remove deletes a key and reports whether it was present.
+func remove(meter *limits.Meter, arguments []interface{}) (interface{}, error) {
+	m, ok := arguments[0].(*Map)
+	if !ok {
+		return nil, errors.New("Can only delete keys from maps.")
+	}
+	return m.Delete(arguments[1]), nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/distolma/golox/cmd/myinterpreter/compiler"
+	"github.com/distolma/golox/cmd/myinterpreter/parser"
+	"github.com/distolma/golox/cmd/myinterpreter/resolver"
+	"github.com/distolma/golox/cmd/myinterpreter/scanner"
+)
+
+const CompiledExt = ".loxc"
+
+// compiledPath returns the default location of the compiled form of a
+// source file: the same path with a .loxc extension.
+func compiledPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + CompiledExt
+}
+
+// compileSource runs the front end and the bytecode compiler over source,
+// read from the file name. It returns nil if any errors were reported.
+func (l *Lox) compileSource(name string, source string) *compiler.Function {
+	scanner := scanner.NewFileScanner(name, source, l.log)
+	tokens := scanner.ScanTokens()
+
+	parser := parser.NewParser(tokens, l.log)
+	statements := parser.Parse()
+
+	if l.log.HadError {
+		return nil
+	}
+
+	resolver := resolver.NewResolver(l.interpreter, l.log)
+	resolver.ResolveStmts(statements)
+
+	if l.log.HadError {
+		return nil
+	}
+
+	function := compiler.NewCompiler(l.log).Compile(statements)
+	if l.log.HadError {
+		return nil
+	}
+
+	return function
+}
+
+func (l *Lox) compileFile(path string, output string) {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
+		os.Exit(ExitError)
+	}
+	source := string(file)
+
+	function := l.compileSource(path, source)
+	if function == nil {
+		l.exitWith(ExitCodeSyntaxError)
+	}
+
+	if output == "" {
+		output = compiledPath(path)
+	}
+
+	if err := writeArtifact(output, path, source, function); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing file: %v\n", err)
+		os.Exit(ExitError)
+	}
+}
+
+// runCompiled executes a .loxc file. If the source it was compiled from is
+// still around and has changed, the source is recompiled and the file is
+// refreshed first.
+func (l *Lox) runCompiled(path string) {
+	artifact := l.readArtifact(path)
+	function := artifact.Function
+
+	if artifact.SourcePath != "" {
+		sourcePath := artifact.SourcePath
+		if !filepath.IsAbs(sourcePath) {
+			sourcePath = filepath.Join(filepath.Dir(path), sourcePath)
+		}
+
+		if file, err := os.ReadFile(sourcePath); err == nil && compiler.HashSource(string(file)) != artifact.SourceHash {
+			function = l.compileSource(sourcePath, string(file))
+			if function == nil {
+				l.exitWith(ExitCodeSyntaxError)
+			}
+
+			if err := writeArtifact(path, sourcePath, string(file), function); err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing file: %v\n", err)
+			}
+		}
+	}
+
+	l.vm.Interpret(l.ctx, function)
+	l.exit()
+}
+
+// compileCached returns the function compiled from source, read from path.
+// It comes from the .loxc file beside path when that is up to date;
+// otherwise source is compiled and the file is written or refreshed. It
+// returns nil if source has errors.
+func (l *Lox) compileCached(path string, source string) *compiler.Function {
+	output := compiledPath(path)
+	if artifact := l.loadCached(output, source); artifact != nil {
+		return artifact.Function
+	}
+
+	function := l.compileSource(path, source)
+	if function == nil {
+		return nil
+	}
+
+	if err := writeArtifact(output, path, source, function); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing file: %v\n", err)
+	}
+	return function
+}
+
+// loadCached returns the artifact at path if it was compiled from source,
+// or nil if it is missing, unreadable or stale.
+func (l *Lox) loadCached(path string, source string) *compiler.Artifact {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	artifact, err := compiler.Decode(bytes.NewReader(file))
+	if err != nil || artifact.SourceHash != compiler.HashSource(source) {
+		return nil
+	}
+
+	return artifact
+}
+
+func (l *Lox) readArtifact(path string) *compiler.Artifact {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
+		os.Exit(ExitError)
+	}
+
+	artifact, err := compiler.Decode(bytes.NewReader(file))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading %s: %v\n", path, err)
+		if errors.Is(err, compiler.ErrInvalidFormat) {
+			l.exitWith(ExitCodeSyntaxError)
+		}
+		os.Exit(ExitError)
+	}
+
+	return artifact
+}
+
+func writeArtifact(path string, sourcePath string, source string, function *compiler.Function) error {
+	// Record the source relative to the artifact so the pair can be moved
+	// together.
+	recorded, err := filepath.Rel(filepath.Dir(path), sourcePath)
+	if err != nil {
+		recorded, _ = filepath.Abs(sourcePath)
+	}
+
+	var buffer bytes.Buffer
+	err = compiler.Encode(&buffer, &compiler.Artifact{
+		SourceHash: compiler.HashSource(source),
+		SourcePath: recorded,
+		Function:   function,
+	})
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, buffer.Bytes(), 0o644)
+}
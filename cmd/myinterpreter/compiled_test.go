@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/distolma/golox/cmd/myinterpreter/compiler"
+)
+
+// readHash returns the source hash recorded in the .loxc file at path.
+func readHash(t *testing.T, path string) [32]byte {
+	t.Helper()
+
+	file, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	artifact, err := compiler.Decode(bytes.NewReader(file))
+	if err != nil {
+		t.Fatalf("Decode(%s) = %v", path, err)
+	}
+	return artifact.SourceHash
+}
+
+// runCached compiles source through the cache and returns what it prints.
+func runCached(t *testing.T, path string, source string) string {
+	t.Helper()
+
+	l := NewLox(BackendVM)
+	var stdout bytes.Buffer
+	l.vm.SetStdout(&stdout)
+
+	function := l.compileCached(path, source)
+	if function == nil {
+		t.Fatalf("compiling %q failed: %v", source, l.log.Reports)
+	}
+	l.vm.Interpret(context.Background(), function)
+	return stdout.String()
+}
+
+func TestCompileCachedWritesArtifact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "program.lox")
+	source := "print 1;"
+
+	if got := runCached(t, path, source); got != "1\n" {
+		t.Errorf("output = %q, want %q", got, "1\n")
+	}
+	if got, want := readHash(t, compiledPath(path)), compiler.HashSource(source); got != want {
+		t.Errorf("cached hash = %x, want %x", got, want)
+	}
+}
+
+func TestCompileCachedReplacesStaleArtifact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "program.lox")
+	runCached(t, path, "print 1;")
+
+	source := "print 2;"
+	if got := runCached(t, path, source); got != "2\n" {
+		t.Errorf("output = %q, want %q", got, "2\n")
+	}
+	if got, want := readHash(t, compiledPath(path)), compiler.HashSource(source); got != want {
+		t.Errorf("cached hash = %x, want %x", got, want)
+	}
+}
+
+func TestCompileCachedSkipsInvalidSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "program.lox")
+
+	l := NewLox(BackendVM)
+	l.log.Output = &bytes.Buffer{}
+	if function := l.compileCached(path, "print ;"); function != nil {
+		t.Error("compileCached() returned a function for invalid source")
+	}
+	if _, err := os.Stat(compiledPath(path)); !os.IsNotExist(err) {
+		t.Errorf("Stat(%s) = %v, want no file", compiledPath(path), err)
+	}
+}
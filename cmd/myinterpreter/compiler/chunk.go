@@ -0,0 +1,97 @@
+package compiler
+
+import "github.com/distolma/golox/cmd/myinterpreter/ast"
+
+type OpCode byte
+
+const (
+	OpConstant OpCode = iota
+	OpNil
+	OpTrue
+	OpFalse
+	OpPop
+	OpGetLocal
+	OpSetLocal
+	OpGetGlobal
+	OpDefineGlobal
+	OpSetGlobal
+	OpGetUpvalue
+	OpSetUpvalue
+	OpGetProperty
+	OpSetProperty
+	OpGetSuper
+	OpEqual
+	OpNotEqual
+	OpGreater
+	OpGreaterEqual
+	OpLess
+	OpLessEqual
+	OpAdd
+	OpSubtract
+	OpMultiply
+	OpDivide
+	OpNot
+	OpNegate
+	OpPrint
+	OpJump
+	OpJumpIfFalse
+	OpLoop
+	OpCall
+	OpInvoke
+	OpSuperInvoke
+	OpClosure
+	OpCloseUpvalue
+	OpReturn
+	OpClass
+	OpInherit
+	OpMethod
+	OpList
+	OpGetIndex
+	OpSetIndex
+	OpMap
+	OpTry
+	OpEndTry
+	OpThrow
+	OpImport
+)
+
+// Chunk is a compiled sequence of instructions together with the constants
+// they reference and the source line of every byte of code.
+type Chunk struct {
+	Code      []byte
+	Constants []interface{}
+	Lines     []int
+	// Spans locate the token behind every byte of code in Source.
+	Spans  []Span
+	Source *ast.Source
+}
+
+// Span is the position of a token within its source.
+type Span struct {
+	Column int
+	Offset int
+	Length int
+}
+
+func (c *Chunk) Write(b byte, token ast.Token) {
+	c.Code = append(c.Code, b)
+	c.Lines = append(c.Lines, token.Line)
+	c.Spans = append(c.Spans, Span{Column: token.Column, Offset: token.Offset, Length: token.Length})
+	if c.Source == nil {
+		c.Source = token.Source
+	}
+}
+
+func (c *Chunk) AddConstant(value interface{}) int {
+	// Identifier names are referenced over and over, so reuse their slot.
+	if _, ok := value.(string); ok {
+		for i, constant := range c.Constants {
+			if constant == value {
+				return i
+			}
+		}
+	}
+
+	c.Constants = append(c.Constants, value)
+	return len(c.Constants) - 1
+}
@@ -0,0 +1,910 @@
+package compiler
+
+import (
+	"math"
+
+	"github.com/distolma/golox/cmd/myinterpreter/ast"
+	logerror "github.com/distolma/golox/cmd/myinterpreter/log_error"
+)
+
+const (
+	FunctionTypeScript = iota
+	FunctionTypeFunction
+	FunctionTypeInitializer
+	FunctionTypeMethod
+)
+
+const (
+	maxLocals    = math.MaxUint8 + 1
+	maxUpvalues  = math.MaxUint8 + 1
+	maxConstants = math.MaxUint16 + 1
+	maxJump      = math.MaxUint16
+)
+
+type local struct {
+	name       string
+	depth      int
+	isCaptured bool
+}
+
+type upvalue struct {
+	index   byte
+	isLocal bool
+}
+
+type functionState struct {
+	enclosing    *functionState
+	function     *Function
+	functionType int
+	locals       []local
+	upvalues     []upvalue
+	scopeDepth   int
+	loop         *loopState
+	tries        []tryState
+}
+
+// tryState is a try statement whose handler is installed at the code being
+// compiled. Jumps out of it must remove the handler and run finally.
+type tryState struct {
+	finally *ast.Block
+}
+
+// loopState collects the jumps of break and continue statements until the
+// loop's increment and exit are known.
+type loopState struct {
+	enclosing     *loopState
+	scopeDepth    int
+	tries         int
+	breakJumps    []int
+	continueJumps []int
+}
+
+type classState struct {
+	enclosing     *classState
+	hasSuperclass bool
+}
+
+// Compiler lowers resolved statements into bytecode. It expects the program
+// to have passed the resolver, so it does not repeat its static checks.
+type Compiler struct {
+	log          *logerror.LogError
+	current      *functionState
+	currentClass *classState
+	// token is where the code being emitted comes from.
+	token ast.Token
+}
+
+func NewCompiler(log *logerror.LogError) *Compiler {
+	return &Compiler{log: log, token: ast.Token{Line: 1}}
+}
+
+func (c *Compiler) Compile(statements []ast.Stmt) *Function {
+	c.log.Phase = logerror.PhaseCompile
+	c.beginFunction(FunctionTypeScript, "")
+
+	for _, statement := range statements {
+		c.compileStmt(statement)
+	}
+
+	function, _ := c.endFunction()
+	return function
+}
+
+// CompileEval compiles statements like Compile, except that when the last
+// statement is an expression statement its value is returned from the script.
+func (c *Compiler) CompileEval(statements []ast.Stmt) *Function {
+	c.log.Phase = logerror.PhaseCompile
+	c.beginFunction(FunctionTypeScript, "")
+
+	for index, statement := range statements {
+		if expression, ok := statement.(*ast.Expression); ok && index == len(statements)-1 {
+			c.compileExpr(expression.Expression)
+			c.emitOp(OpReturn)
+			break
+		}
+		c.compileStmt(statement)
+	}
+
+	function, _ := c.endFunction()
+	return function
+}
+
+func (c *Compiler) compileStmt(stmt ast.Stmt) {
+	stmt.Accept(c)
+}
+
+func (c *Compiler) compileExpr(expr ast.Expr) {
+	expr.Accept(c)
+}
+
+func (c *Compiler) chunk() *Chunk {
+	return &c.current.function.Chunk
+}
+
+func (c *Compiler) beginFunction(functionType int, name string) {
+	state := &functionState{
+		enclosing:    c.current,
+		function:     &Function{Name: name},
+		functionType: functionType,
+	}
+
+	// Slot zero holds the callee, or the receiver for methods.
+	slot := local{depth: 0}
+	if functionType == FunctionTypeMethod || functionType == FunctionTypeInitializer {
+		slot.name = "this"
+	}
+	state.locals = append(state.locals, slot)
+
+	c.current = state
+}
+
+func (c *Compiler) endFunction() (*Function, []upvalue) {
+	c.emitReturn()
+
+	state := c.current
+	state.function.UpvalueCount = len(state.upvalues)
+	c.current = state.enclosing
+
+	return state.function, state.upvalues
+}
+
+func (c *Compiler) function(declaration *ast.Function, functionType int) {
+	// An empty name is reserved for the script, so anonymous functions get
+	// one of their own.
+	name := declaration.Name.Lexeme
+	if name == "" {
+		name = "anonymous"
+	}
+
+	c.beginFunction(functionType, name)
+	c.beginScope()
+
+	for _, param := range declaration.Params {
+		c.current.function.Arity++
+		c.declareVariable(param)
+		c.markInitialized()
+	}
+
+	for _, statement := range declaration.Body {
+		c.compileStmt(statement)
+	}
+
+	function, upvalues := c.endFunction()
+
+	c.token = declaration.Name
+	c.emitOpShort(OpClosure, c.makeConstant(function))
+	for _, upvalue := range upvalues {
+		isLocal := byte(0)
+		if upvalue.isLocal {
+			isLocal = 1
+		}
+		c.emitByte(isLocal)
+		c.emitByte(upvalue.index)
+	}
+}
+
+func (c *Compiler) beginScope() {
+	c.current.scopeDepth++
+}
+
+func (c *Compiler) endScope() {
+	state := c.current
+	state.scopeDepth--
+
+	for len(state.locals) > 0 && state.locals[len(state.locals)-1].depth > state.scopeDepth {
+		if state.locals[len(state.locals)-1].isCaptured {
+			c.emitOp(OpCloseUpvalue)
+		} else {
+			c.emitOp(OpPop)
+		}
+		state.locals = state.locals[:len(state.locals)-1]
+	}
+}
+
+// discardLocals emits the code leaving every scope deeper than depth without
+// forgetting their locals, for jumps out of the middle of a block.
+func (c *Compiler) discardLocals(depth int) {
+	locals := c.current.locals
+	for i := len(locals) - 1; i >= 0 && locals[i].depth > depth; i-- {
+		if locals[i].isCaptured {
+			c.emitOp(OpCloseUpvalue)
+		} else {
+			c.emitOp(OpPop)
+		}
+	}
+}
+
+// declareVariable records a new local in the current scope, or returns the
+// constant holding the name when declaring a global.
+func (c *Compiler) declareVariable(name ast.Token) int {
+	c.token = name
+
+	if c.current.scopeDepth == 0 {
+		return c.identifierConstant(name.Lexeme)
+	}
+
+	c.addLocal(name, name.Lexeme)
+	return 0
+}
+
+func (c *Compiler) defineVariable(global int) {
+	if c.current.scopeDepth > 0 {
+		c.markInitialized()
+		return
+	}
+
+	c.emitOpShort(OpDefineGlobal, global)
+}
+
+func (c *Compiler) addLocal(token ast.Token, name string) {
+	if len(c.current.locals) == maxLocals {
+		c.log.TokenError(token, "Too many local variables in function.")
+		return
+	}
+
+	c.current.locals = append(c.current.locals, local{name: name, depth: -1})
+}
+
+func (c *Compiler) markInitialized() {
+	if c.current.scopeDepth == 0 {
+		return
+	}
+	c.current.locals[len(c.current.locals)-1].depth = c.current.scopeDepth
+}
+
+func (c *Compiler) resolveLocal(state *functionState, name string) int {
+	for i := len(state.locals) - 1; i >= 0; i-- {
+		if state.locals[i].name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (c *Compiler) resolveUpvalue(state *functionState, name ast.Token) int {
+	if state.enclosing == nil {
+		return -1
+	}
+
+	if local := c.resolveLocal(state.enclosing, name.Lexeme); local != -1 {
+		state.enclosing.locals[local].isCaptured = true
+		return c.addUpvalue(state, name, byte(local), true)
+	}
+
+	if upvalue := c.resolveUpvalue(state.enclosing, name); upvalue != -1 {
+		return c.addUpvalue(state, name, byte(upvalue), false)
+	}
+
+	return -1
+}
+
+func (c *Compiler) addUpvalue(state *functionState, name ast.Token, index byte, isLocal bool) int {
+	for i, upvalue := range state.upvalues {
+		if upvalue.index == index && upvalue.isLocal == isLocal {
+			return i
+		}
+	}
+
+	if len(state.upvalues) == maxUpvalues {
+		c.log.TokenError(name, "Too many closure variables in function.")
+		return 0
+	}
+
+	state.upvalues = append(state.upvalues, upvalue{index: index, isLocal: isLocal})
+	return len(state.upvalues) - 1
+}
+
+func (c *Compiler) getVariable(name ast.Token) {
+	c.token = name
+
+	if arg := c.resolveLocal(c.current, name.Lexeme); arg != -1 {
+		c.emitOpByte(OpGetLocal, byte(arg))
+	} else if arg := c.resolveUpvalue(c.current, name); arg != -1 {
+		c.emitOpByte(OpGetUpvalue, byte(arg))
+	} else {
+		c.emitOpShort(OpGetGlobal, c.identifierConstant(name.Lexeme))
+	}
+}
+
+func (c *Compiler) setVariable(name ast.Token) {
+	c.token = name
+
+	if arg := c.resolveLocal(c.current, name.Lexeme); arg != -1 {
+		c.emitOpByte(OpSetLocal, byte(arg))
+	} else if arg := c.resolveUpvalue(c.current, name); arg != -1 {
+		c.emitOpByte(OpSetUpvalue, byte(arg))
+	} else {
+		c.emitOpShort(OpSetGlobal, c.identifierConstant(name.Lexeme))
+	}
+}
+
+func (c *Compiler) identifierConstant(name string) int {
+	return c.makeConstant(name)
+}
+
+func (c *Compiler) makeConstant(value interface{}) int {
+	constant := c.chunk().AddConstant(value)
+	if constant >= maxConstants {
+		c.log.ErrorAt(c.token, "Too many constants in one chunk.")
+		return 0
+	}
+	return constant
+}
+
+func (c *Compiler) emitByte(b byte) {
+	c.chunk().Write(b, c.token)
+}
+
+func (c *Compiler) emitOp(op OpCode) {
+	c.emitByte(byte(op))
+}
+
+func (c *Compiler) emitOpByte(op OpCode, operand byte) {
+	c.emitOp(op)
+	c.emitByte(operand)
+}
+
+func (c *Compiler) emitOpShort(op OpCode, operand int) {
+	c.emitOp(op)
+	c.emitByte(byte(operand >> 8))
+	c.emitByte(byte(operand))
+}
+
+func (c *Compiler) emitJump(op OpCode) int {
+	c.emitOpShort(op, 0xffff)
+	return len(c.chunk().Code) - 2
+}
+
+func (c *Compiler) patchJump(offset int) {
+	jump := len(c.chunk().Code) - offset - 2
+	if jump > maxJump {
+		c.log.ErrorAt(c.token, "Too much code to jump over.")
+	}
+
+	c.chunk().Code[offset] = byte(jump >> 8)
+	c.chunk().Code[offset+1] = byte(jump)
+}
+
+func (c *Compiler) emitLoop(loopStart int) {
+	c.emitOp(OpLoop)
+
+	offset := len(c.chunk().Code) - loopStart + 2
+	if offset > maxJump {
+		c.log.ErrorAt(c.token, "Loop body too large.")
+	}
+
+	c.emitByte(byte(offset >> 8))
+	c.emitByte(byte(offset))
+}
+
+func (c *Compiler) emitReturn() {
+	if c.current.functionType == FunctionTypeInitializer {
+		c.emitOpByte(OpGetLocal, 0)
+	} else {
+		c.emitOp(OpNil)
+	}
+	c.emitOp(OpReturn)
+}
+
+func (c *Compiler) VisitBlockStmt(stmt *ast.Block) interface{} {
+	c.beginScope()
+	for _, statement := range stmt.Statements {
+		c.compileStmt(statement)
+	}
+	c.endScope()
+	return nil
+}
+
+func (c *Compiler) VisitClassStmt(stmt *ast.Class) interface{} {
+	nameConstant := c.identifierConstant(stmt.Name.Lexeme)
+	c.declareVariable(stmt.Name)
+
+	c.emitOpShort(OpClass, nameConstant)
+	c.defineVariable(nameConstant)
+
+	class := &classState{enclosing: c.currentClass}
+	c.currentClass = class
+
+	if stmt.Superclass != nil {
+		c.getVariable(stmt.Superclass.Name)
+
+		c.beginScope()
+		c.addLocal(stmt.Superclass.Name, "super")
+		c.defineVariable(0)
+
+		c.getVariable(stmt.Name)
+		c.token = stmt.Superclass.Name
+		c.emitOp(OpInherit)
+		class.hasSuperclass = true
+	}
+
+	c.getVariable(stmt.Name)
+	for _, method := range stmt.Methods {
+		functionType := FunctionTypeMethod
+		if method.Name.Lexeme == "init" {
+			functionType = FunctionTypeInitializer
+		}
+
+		c.function(method, functionType)
+		c.emitOpShort(OpMethod, c.identifierConstant(method.Name.Lexeme))
+	}
+	c.emitOp(OpPop)
+
+	if class.hasSuperclass {
+		c.endScope()
+	}
+
+	c.currentClass = class.enclosing
+	return nil
+}
+
+func (c *Compiler) VisitExpressionStmt(stmt *ast.Expression) interface{} {
+	c.compileExpr(stmt.Expression)
+	c.emitOp(OpPop)
+	return nil
+}
+
+func (c *Compiler) VisitFunctionStmt(stmt *ast.Function) interface{} {
+	global := c.declareVariable(stmt.Name)
+	c.markInitialized()
+
+	c.function(stmt, FunctionTypeFunction)
+	c.defineVariable(global)
+	return nil
+}
+
+func (c *Compiler) VisitIfStmt(stmt *ast.If) interface{} {
+	c.compileExpr(stmt.Condition)
+
+	thenJump := c.emitJump(OpJumpIfFalse)
+	c.emitOp(OpPop)
+	c.compileStmt(stmt.ThenBranch)
+
+	elseJump := c.emitJump(OpJump)
+
+	c.patchJump(thenJump)
+	c.emitOp(OpPop)
+
+	if stmt.ElseBranch != nil {
+		c.compileStmt(stmt.ElseBranch)
+	}
+	c.patchJump(elseJump)
+	return nil
+}
+
+func (c *Compiler) VisitPrintStmt(stmt *ast.Print) interface{} {
+	c.compileExpr(stmt.Expression)
+	c.emitOp(OpPrint)
+	return nil
+}
+
+func (c *Compiler) VisitReturnStmt(stmt *ast.Return) interface{} {
+	c.token = stmt.Keyword
+
+	if len(c.current.tries) > 0 {
+		c.returnFromTry(stmt)
+		return nil
+	}
+
+	if stmt.Value == nil {
+		c.emitReturn()
+		return nil
+	}
+
+	c.compileExpr(stmt.Value)
+	c.emitOp(OpReturn)
+	return nil
+}
+
+// returnFromTry keeps the return value in a hidden local while the enclosing
+// finally clauses run.
+func (c *Compiler) returnFromTry(stmt *ast.Return) {
+	state := c.current
+
+	if stmt.Value != nil {
+		c.compileExpr(stmt.Value)
+	} else if state.functionType == FunctionTypeInitializer {
+		c.emitOpByte(OpGetLocal, 0)
+	} else {
+		c.emitOp(OpNil)
+	}
+
+	c.beginScope()
+	slot := len(state.locals)
+	c.addLocal(stmt.Keyword, "")
+	c.markInitialized()
+
+	c.unwindTries(0)
+
+	c.token = stmt.Keyword
+	c.emitOpByte(OpGetLocal, byte(slot))
+	c.emitOp(OpReturn)
+
+	// Nothing after the return runs, so the local is dropped without code.
+	state.locals = state.locals[:slot]
+	state.scopeDepth--
+}
+
+// unwindTries emits the code leaving every try statement entered after the
+// first depth ones, innermost first: each handler is removed and then its
+// finally clause runs.
+func (c *Compiler) unwindTries(depth int) {
+	state := c.current
+	tries := state.tries
+
+	for i := len(tries) - 1; i >= depth; i-- {
+		c.emitOp(OpEndTry)
+		if tries[i].finally != nil {
+			// The clause runs outside its own handler, so jumps out of it
+			// don't run it again.
+			state.tries = tries[:i]
+			c.compileStmt(tries[i].finally)
+		}
+	}
+
+	state.tries = tries
+}
+
+// VisitImportStmt pushes the module once for an alias and once for each
+// imported name, whose value is read off it as a property.
+func (c *Compiler) VisitImportStmt(stmt *ast.Import) interface{} {
+	path := c.makeConstant(stmt.Path.Literal)
+
+	if stmt.Alias.Lexeme != "" {
+		global := c.declareVariable(stmt.Alias)
+		c.token = stmt.Path
+		c.emitOpShort(OpImport, path)
+		c.defineVariable(global)
+	}
+	for _, name := range stmt.Names {
+		global := c.declareVariable(name)
+		c.token = stmt.Path
+		c.emitOpShort(OpImport, path)
+		c.token = name
+		c.emitOpShort(OpGetProperty, c.identifierConstant(name.Lexeme))
+		c.defineVariable(global)
+	}
+	return nil
+}
+
+func (c *Compiler) VisitLambdaExpr(expr *ast.Lambda) interface{} {
+	c.function(expr.Function, FunctionTypeFunction)
+	return nil
+}
+
+func (c *Compiler) VisitThrowStmt(stmt *ast.Throw) interface{} {
+	c.compileExpr(stmt.Value)
+
+	c.token = stmt.Keyword
+	c.emitOp(OpThrow)
+	return nil
+}
+
+func (c *Compiler) VisitTryStmt(stmt *ast.Try) interface{} {
+	state := c.current
+	c.token = stmt.Keyword
+
+	var finallyHandler int
+	if stmt.Finally != nil {
+		finallyHandler = c.emitJump(OpTry)
+		state.tries = append(state.tries, tryState{finally: stmt.Finally})
+	}
+
+	if stmt.Catch != nil {
+		catchHandler := c.emitJump(OpTry)
+		state.tries = append(state.tries, tryState{})
+		c.compileStmt(stmt.Body)
+		state.tries = state.tries[:len(state.tries)-1]
+		c.emitOp(OpEndTry)
+		doneJump := c.emitJump(OpJump)
+
+		// The VM pushes the error object, which becomes the catch variable.
+		c.patchJump(catchHandler)
+		c.beginScope()
+		c.addLocal(stmt.Name, stmt.Name.Lexeme)
+		c.markInitialized()
+		for _, statement := range stmt.Catch.Statements {
+			c.compileStmt(statement)
+		}
+		c.endScope()
+
+		c.patchJump(doneJump)
+	} else {
+		c.compileStmt(stmt.Body)
+	}
+
+	if stmt.Finally != nil {
+		state.tries = state.tries[:len(state.tries)-1]
+		c.token = stmt.Keyword
+		c.emitOp(OpEndTry)
+		c.compileStmt(stmt.Finally)
+		doneJump := c.emitJump(OpJump)
+
+		// An error escaping the body or catch clause runs the finally clause
+		// and is then thrown again.
+		c.patchJump(finallyHandler)
+		c.beginScope()
+		slot := len(state.locals)
+		c.addLocal(stmt.Keyword, "")
+		c.markInitialized()
+		c.compileStmt(stmt.Finally)
+		c.emitOpByte(OpGetLocal, byte(slot))
+		c.emitOp(OpThrow)
+		c.endScope()
+
+		c.patchJump(doneJump)
+	}
+	return nil
+}
+
+func (c *Compiler) VisitVarStmt(stmt *ast.Var) interface{} {
+	global := c.declareVariable(stmt.Name)
+
+	if stmt.Initializer != nil {
+		c.compileExpr(stmt.Initializer)
+	} else {
+		c.emitOp(OpNil)
+	}
+
+	c.defineVariable(global)
+	return nil
+}
+
+func (c *Compiler) VisitWhileStmt(stmt *ast.While) interface{} {
+	loopStart := len(c.chunk().Code)
+	c.compileExpr(stmt.Condition)
+
+	exitJump := c.emitJump(OpJumpIfFalse)
+	c.emitOp(OpPop)
+
+	loop := &loopState{enclosing: c.current.loop, scopeDepth: c.current.scopeDepth, tries: len(c.current.tries)}
+	c.current.loop = loop
+	c.compileStmt(stmt.Body)
+	c.current.loop = loop.enclosing
+
+	for _, jump := range loop.continueJumps {
+		c.patchJump(jump)
+	}
+	if stmt.Increment != nil {
+		c.compileExpr(stmt.Increment)
+		c.emitOp(OpPop)
+	}
+
+	c.token = stmt.Keyword
+	c.emitLoop(loopStart)
+
+	c.patchJump(exitJump)
+	c.emitOp(OpPop)
+
+	for _, jump := range loop.breakJumps {
+		c.patchJump(jump)
+	}
+	return nil
+}
+
+func (c *Compiler) VisitBreakStmt(stmt *ast.Break) interface{} {
+	c.token = stmt.Keyword
+	loop := c.current.loop
+	c.unwindTries(loop.tries)
+	c.discardLocals(loop.scopeDepth)
+	loop.breakJumps = append(loop.breakJumps, c.emitJump(OpJump))
+	return nil
+}
+
+func (c *Compiler) VisitContinueStmt(stmt *ast.Continue) interface{} {
+	c.token = stmt.Keyword
+	loop := c.current.loop
+	c.unwindTries(loop.tries)
+	c.discardLocals(loop.scopeDepth)
+	loop.continueJumps = append(loop.continueJumps, c.emitJump(OpJump))
+	return nil
+}
+
+func (c *Compiler) VisitAssignExpr(expr *ast.Assign) interface{} {
+	c.compileExpr(expr.Value)
+	c.setVariable(expr.Name)
+	return nil
+}
+
+func (c *Compiler) VisitBinaryExpr(expr *ast.Binary) interface{} {
+	c.compileExpr(expr.Left)
+	c.compileExpr(expr.Right)
+
+	c.token = expr.Operator
+	switch expr.Operator.Type {
+	case ast.TBangEqual:
+		c.emitOp(OpNotEqual)
+	case ast.TEqualEqual:
+		c.emitOp(OpEqual)
+	case ast.TGreater:
+		c.emitOp(OpGreater)
+	case ast.TGreaterEqual:
+		c.emitOp(OpGreaterEqual)
+	case ast.TLess:
+		c.emitOp(OpLess)
+	case ast.TLessEqual:
+		c.emitOp(OpLessEqual)
+	case ast.TPlus:
+		c.emitOp(OpAdd)
+	case ast.TMinus:
+		c.emitOp(OpSubtract)
+	case ast.TStar:
+		c.emitOp(OpMultiply)
+	case ast.TSlash:
+		c.emitOp(OpDivide)
+	}
+	return nil
+}
+
+func (c *Compiler) VisitCallExpr(expr *ast.Call) interface{} {
+	switch callee := expr.Callee.(type) {
+	case *ast.Get:
+		c.compileExpr(callee.Object)
+		c.compileArguments(expr.Arguments)
+
+		c.token = expr.Paren
+		c.emitOpShort(OpInvoke, c.identifierConstant(callee.Name.Lexeme))
+		c.emitByte(byte(len(expr.Arguments)))
+	case *ast.Super:
+		this := callee.Keyword
+		this.Lexeme = "this"
+		c.getVariable(this)
+		c.compileArguments(expr.Arguments)
+		c.getVariable(callee.Keyword)
+
+		c.token = expr.Paren
+		c.emitOpShort(OpSuperInvoke, c.identifierConstant(callee.Method.Lexeme))
+		c.emitByte(byte(len(expr.Arguments)))
+	default:
+		c.compileExpr(expr.Callee)
+		c.compileArguments(expr.Arguments)
+
+		c.token = expr.Paren
+		c.emitOpByte(OpCall, byte(len(expr.Arguments)))
+	}
+	return nil
+}
+
+func (c *Compiler) compileArguments(arguments []ast.Expr) {
+	for _, argument := range arguments {
+		c.compileExpr(argument)
+	}
+}
+
+func (c *Compiler) VisitListExpr(expr *ast.List) interface{} {
+	for _, element := range expr.Elements {
+		c.compileExpr(element)
+	}
+
+	c.token = expr.Bracket
+	if len(expr.Elements) > math.MaxUint16 {
+		c.log.ErrorAt(c.token, "Too many elements in list literal.")
+	}
+	c.emitOpShort(OpList, len(expr.Elements))
+	return nil
+}
+
+func (c *Compiler) VisitMapExpr(expr *ast.Map) interface{} {
+	for i, key := range expr.Keys {
+		c.compileExpr(key)
+		c.compileExpr(expr.Values[i])
+	}
+
+	c.token = expr.Brace
+	if len(expr.Keys) > math.MaxUint16 {
+		c.log.ErrorAt(c.token, "Too many entries in map literal.")
+	}
+	c.emitOpShort(OpMap, len(expr.Keys))
+	return nil
+}
+
+func (c *Compiler) VisitIndexExpr(expr *ast.Index) interface{} {
+	c.compileExpr(expr.Object)
+	c.compileExpr(expr.Index)
+
+	c.token = expr.Bracket
+	c.emitOp(OpGetIndex)
+	return nil
+}
+
+func (c *Compiler) VisitSetIndexExpr(expr *ast.SetIndex) interface{} {
+	c.compileExpr(expr.Object)
+	c.compileExpr(expr.Index)
+	c.compileExpr(expr.Value)
+
+	c.token = expr.Bracket
+	c.emitOp(OpSetIndex)
+	return nil
+}
+
+func (c *Compiler) VisitGetExpr(expr *ast.Get) interface{} {
+	c.compileExpr(expr.Object)
+
+	c.token = expr.Name
+	c.emitOpShort(OpGetProperty, c.identifierConstant(expr.Name.Lexeme))
+	return nil
+}
+
+func (c *Compiler) VisitGroupingExpr(expr *ast.Grouping) interface{} {
+	c.compileExpr(expr.Expression)
+	return nil
+}
+
+func (c *Compiler) VisitLiteralExpr(expr *ast.Literal) interface{} {
+	switch expr.Value {
+	case nil:
+		c.emitOp(OpNil)
+	case true:
+		c.emitOp(OpTrue)
+	case false:
+		c.emitOp(OpFalse)
+	default:
+		c.emitOpShort(OpConstant, c.makeConstant(expr.Value))
+	}
+	return nil
+}
+
+func (c *Compiler) VisitLogicalExpr(expr *ast.Logical) interface{} {
+	c.compileExpr(expr.Left)
+
+	if expr.Operator.Type == ast.TOr {
+		elseJump := c.emitJump(OpJumpIfFalse)
+		endJump := c.emitJump(OpJump)
+
+		c.patchJump(elseJump)
+		c.emitOp(OpPop)
+
+		c.compileExpr(expr.Right)
+		c.patchJump(endJump)
+		return nil
+	}
+
+	endJump := c.emitJump(OpJumpIfFalse)
+	c.emitOp(OpPop)
+	c.compileExpr(expr.Right)
+	c.patchJump(endJump)
+	return nil
+}
+
+func (c *Compiler) VisitSetExpr(expr *ast.Set) interface{} {
+	c.compileExpr(expr.Object)
+	c.compileExpr(expr.Value)
+
+	c.token = expr.Name
+	c.emitOpShort(OpSetProperty, c.identifierConstant(expr.Name.Lexeme))
+	return nil
+}
+
+func (c *Compiler) VisitSuperExpr(expr *ast.Super) interface{} {
+	this := expr.Keyword
+	this.Lexeme = "this"
+	c.getVariable(this)
+	c.getVariable(expr.Keyword)
+
+	c.token = expr.Method
+	c.emitOpShort(OpGetSuper, c.identifierConstant(expr.Method.Lexeme))
+	return nil
+}
+
+func (c *Compiler) VisitThisExpr(expr *ast.This) interface{} {
+	c.getVariable(expr.Keyword)
+	return nil
+}
+
+func (c *Compiler) VisitUnaryExpr(expr *ast.Unary) interface{} {
+	c.compileExpr(expr.Right)
+
+	c.token = expr.Operator
+	switch expr.Operator.Type {
+	case ast.TBang:
+		c.emitOp(OpNot)
+	case ast.TMinus:
+		c.emitOp(OpNegate)
+	}
+	return nil
+}
+
+func (c *Compiler) VisitVariableExpr(expr *ast.Variable) interface{} {
+	c.getVariable(expr.Name)
+	return nil
+}
@@ -0,0 +1,163 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+)
+
+var opNames = map[OpCode]string{
+	OpConstant:     "OP_CONSTANT",
+	OpNil:          "OP_NIL",
+	OpTrue:         "OP_TRUE",
+	OpFalse:        "OP_FALSE",
+	OpPop:          "OP_POP",
+	OpGetLocal:     "OP_GET_LOCAL",
+	OpSetLocal:     "OP_SET_LOCAL",
+	OpGetGlobal:    "OP_GET_GLOBAL",
+	OpDefineGlobal: "OP_DEFINE_GLOBAL",
+	OpSetGlobal:    "OP_SET_GLOBAL",
+	OpGetUpvalue:   "OP_GET_UPVALUE",
+	OpSetUpvalue:   "OP_SET_UPVALUE",
+	OpGetProperty:  "OP_GET_PROPERTY",
+	OpSetProperty:  "OP_SET_PROPERTY",
+	OpGetSuper:     "OP_GET_SUPER",
+	OpEqual:        "OP_EQUAL",
+	OpNotEqual:     "OP_NOT_EQUAL",
+	OpGreater:      "OP_GREATER",
+	OpGreaterEqual: "OP_GREATER_EQUAL",
+	OpLess:         "OP_LESS",
+	OpLessEqual:    "OP_LESS_EQUAL",
+	OpAdd:          "OP_ADD",
+	OpSubtract:     "OP_SUBTRACT",
+	OpMultiply:     "OP_MULTIPLY",
+	OpDivide:       "OP_DIVIDE",
+	OpNot:          "OP_NOT",
+	OpNegate:       "OP_NEGATE",
+	OpPrint:        "OP_PRINT",
+	OpJump:         "OP_JUMP",
+	OpJumpIfFalse:  "OP_JUMP_IF_FALSE",
+	OpLoop:         "OP_LOOP",
+	OpCall:         "OP_CALL",
+	OpInvoke:       "OP_INVOKE",
+	OpSuperInvoke:  "OP_SUPER_INVOKE",
+	OpClosure:      "OP_CLOSURE",
+	OpCloseUpvalue: "OP_CLOSE_UPVALUE",
+	OpReturn:       "OP_RETURN",
+	OpClass:        "OP_CLASS",
+	OpInherit:      "OP_INHERIT",
+	OpMethod:       "OP_METHOD",
+	OpList:         "OP_LIST",
+	OpGetIndex:     "OP_GET_INDEX",
+	OpSetIndex:     "OP_SET_INDEX",
+	OpMap:          "OP_MAP",
+	OpTry:          "OP_TRY",
+	OpEndTry:       "OP_END_TRY",
+	OpThrow:        "OP_THROW",
+	OpImport:       "OP_IMPORT",
+}
+
+func (op OpCode) String() string {
+	if name, ok := opNames[op]; ok {
+		return name
+	}
+	return fmt.Sprintf("OP_UNKNOWN(%d)", byte(op))
+}
+
+// Disassemble renders the chunk of function followed by the chunks of every
+// function nested in its constant pool.
+func Disassemble(function *Function) string {
+	var result strings.Builder
+
+	functions := []*Function{function}
+	for len(functions) > 0 {
+		current := functions[0]
+		functions = functions[1:]
+
+		if result.Len() > 0 {
+			result.WriteString("\n")
+		}
+		result.WriteString(DisassembleChunk(&current.Chunk, current.String()))
+
+		for _, constant := range current.Chunk.Constants {
+			if nested, ok := constant.(*Function); ok {
+				functions = append(functions, nested)
+			}
+		}
+	}
+
+	return result.String()
+}
+
+func DisassembleChunk(chunk *Chunk, name string) string {
+	var result strings.Builder
+	fmt.Fprintf(&result, "== %s ==\n", name)
+
+	for offset := 0; offset < len(chunk.Code); {
+		var line string
+		line, offset = DisassembleInstruction(chunk, offset)
+		result.WriteString(line + "\n")
+	}
+
+	return result.String()
+}
+
+// DisassembleInstruction renders the instruction at offset and returns the
+// offset of the next one.
+func DisassembleInstruction(chunk *Chunk, offset int) (string, int) {
+	prefix := fmt.Sprintf("%04d ", offset)
+	if offset > 0 && chunk.Lines[offset] == chunk.Lines[offset-1] {
+		prefix += "   | "
+	} else {
+		prefix += fmt.Sprintf("%4d ", chunk.Lines[offset])
+	}
+
+	op := OpCode(chunk.Code[offset])
+	switch op {
+	case OpConstant, OpGetGlobal, OpDefineGlobal, OpSetGlobal, OpGetProperty, OpSetProperty,
+		OpGetSuper, OpClass, OpMethod, OpImport:
+		constant := chunk.readShort(offset + 1)
+		return prefix + fmt.Sprintf("%-16s %4d '%s'", op, constant, formatConstant(chunk.Constants[constant])), offset + 3
+	case OpGetLocal, OpSetLocal, OpGetUpvalue, OpSetUpvalue, OpCall:
+		return prefix + fmt.Sprintf("%-16s %4d", op, chunk.Code[offset+1]), offset + 2
+	case OpList, OpMap:
+		return prefix + fmt.Sprintf("%-16s %4d", op, chunk.readShort(offset+1)), offset + 3
+	case OpJump, OpJumpIfFalse, OpTry:
+		jump := chunk.readShort(offset + 1)
+		return prefix + fmt.Sprintf("%-16s %4d -> %d", op, offset, offset+3+jump), offset + 3
+	case OpLoop:
+		jump := chunk.readShort(offset + 1)
+		return prefix + fmt.Sprintf("%-16s %4d -> %d", op, offset, offset+3-jump), offset + 3
+	case OpInvoke, OpSuperInvoke:
+		constant := chunk.readShort(offset + 1)
+		argCount := chunk.Code[offset+3]
+		return prefix + fmt.Sprintf("%-16s (%d args) %4d '%s'", op, argCount, constant, formatConstant(chunk.Constants[constant])), offset + 4
+	case OpClosure:
+		constant := chunk.readShort(offset + 1)
+		function := chunk.Constants[constant].(*Function)
+		line := prefix + fmt.Sprintf("%-16s %4d %s", op, constant, function)
+
+		offset += 3
+		for range function.UpvalueCount {
+			kind := "upvalue"
+			if chunk.Code[offset] == 1 {
+				kind = "local"
+			}
+			line += fmt.Sprintf("\n%04d    |                     %s %d", offset, kind, chunk.Code[offset+1])
+			offset += 2
+		}
+		return line, offset
+	default:
+		return prefix + op.String(), offset + 1
+	}
+}
+
+func (c *Chunk) readShort(offset int) int {
+	return int(c.Code[offset])<<8 | int(c.Code[offset+1])
+}
+
+func formatConstant(value interface{}) string {
+	if value == nil {
+		return "nil"
+	}
+	return fmt.Sprint(value)
+}
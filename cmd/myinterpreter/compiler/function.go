@@ -0,0 +1,19 @@
+package compiler
+
+import "fmt"
+
+// Function is the compiled prototype of a Lox function. The top-level script
+// is compiled into a Function with an empty name.
+type Function struct {
+	Name         string
+	Arity        int
+	UpvalueCount int
+	Chunk        Chunk
+}
+
+func (f *Function) String() string {
+	if f.Name == "" {
+		return "<script>"
+	}
+	return fmt.Sprintf("<fn %s>", f.Name)
+}
@@ -0,0 +1,311 @@
+package compiler
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/distolma/golox/cmd/myinterpreter/ast"
+)
+
+// A compiled script file starts with Magic and FormatVersion, followed by the
+// SHA-256 of the source it was compiled from, the source path, the name and
+// text of the source for error messages, and the top-level function.
+// Functions nested in a constant pool are written inline.
+const (
+	Magic         = "LOXC"
+	FormatVersion = 2
+)
+
+const (
+	constantNumber byte = iota
+	constantString
+	constantFunction
+)
+
+var ErrInvalidFormat = errors.New("not a compiled Lox script")
+
+type Artifact struct {
+	SourceHash [sha256.Size]byte
+	SourcePath string
+	Function   *Function
+}
+
+func HashSource(source string) [sha256.Size]byte {
+	return sha256.Sum256([]byte(source))
+}
+
+func Encode(w io.Writer, artifact *Artifact) error {
+	writer := bufio.NewWriter(w)
+	encoder := &encoder{w: writer}
+
+	encoder.bytes([]byte(Magic))
+	encoder.uvarint(FormatVersion)
+	encoder.bytes(artifact.SourceHash[:])
+	encoder.string(artifact.SourcePath)
+
+	source := artifact.Function.Chunk.Source
+	if source == nil {
+		source = &ast.Source{}
+	}
+	encoder.string(source.Name)
+	encoder.string(source.Text)
+
+	encoder.function(artifact.Function)
+
+	if encoder.err != nil {
+		return encoder.err
+	}
+	return writer.Flush()
+}
+
+func Decode(r io.Reader) (*Artifact, error) {
+	decoder := &decoder{r: bufio.NewReader(r)}
+
+	magic := decoder.bytes(len(Magic))
+	if decoder.err != nil || !bytes.Equal(magic, []byte(Magic)) {
+		return nil, ErrInvalidFormat
+	}
+
+	if version := decoder.uvarint(); version != FormatVersion {
+		return nil, fmt.Errorf("unsupported compiled script version %d", version)
+	}
+
+	artifact := &Artifact{}
+	copy(artifact.SourceHash[:], decoder.bytes(sha256.Size))
+	artifact.SourcePath = decoder.string()
+	decoder.source = &ast.Source{Name: decoder.string(), Text: decoder.string()}
+	artifact.Function = decoder.function()
+
+	if decoder.err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFormat, decoder.err)
+	}
+	if err := verify(artifact.Function); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFormat, err)
+	}
+	return artifact, nil
+}
+
+type encoder struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (e *encoder) bytes(b []byte) {
+	if e.err != nil {
+		return
+	}
+	_, e.err = e.w.Write(b)
+}
+
+func (e *encoder) uvarint(value uint64) {
+	e.bytes(binary.AppendUvarint(nil, value))
+}
+
+func (e *encoder) string(s string) {
+	e.uvarint(uint64(len(s)))
+	e.bytes([]byte(s))
+}
+
+func (e *encoder) function(function *Function) {
+	e.string(function.Name)
+	e.uvarint(uint64(function.Arity))
+	e.uvarint(uint64(function.UpvalueCount))
+
+	chunk := &function.Chunk
+	e.uvarint(uint64(len(chunk.Code)))
+	e.bytes(chunk.Code)
+
+	// Lines are run-length encoded as (count, line) pairs.
+	var runs [][2]int
+	for _, line := range chunk.Lines {
+		if len(runs) > 0 && runs[len(runs)-1][1] == line {
+			runs[len(runs)-1][0]++
+		} else {
+			runs = append(runs, [2]int{1, line})
+		}
+	}
+	e.uvarint(uint64(len(runs)))
+	for _, run := range runs {
+		e.uvarint(uint64(run[0]))
+		e.uvarint(uint64(run[1]))
+	}
+
+	// Spans are run-length encoded the same way, as the bytes of an
+	// instruction share one.
+	var spans []Span
+	var counts []int
+	for _, span := range chunk.Spans {
+		if len(spans) > 0 && spans[len(spans)-1] == span {
+			counts[len(counts)-1]++
+		} else {
+			spans = append(spans, span)
+			counts = append(counts, 1)
+		}
+	}
+	e.uvarint(uint64(len(spans)))
+	for i, span := range spans {
+		e.uvarint(uint64(counts[i]))
+		e.uvarint(uint64(span.Column))
+		e.uvarint(uint64(span.Offset))
+		e.uvarint(uint64(span.Length))
+	}
+
+	e.uvarint(uint64(len(chunk.Constants)))
+	for _, constant := range chunk.Constants {
+		switch value := constant.(type) {
+		case float64:
+			e.bytes([]byte{constantNumber})
+			e.bytes(binary.BigEndian.AppendUint64(nil, math.Float64bits(value)))
+		case string:
+			e.bytes([]byte{constantString})
+			e.string(value)
+		case *Function:
+			e.bytes([]byte{constantFunction})
+			e.function(value)
+		default:
+			if e.err == nil {
+				e.err = fmt.Errorf("cannot encode constant of type %T", constant)
+			}
+		}
+	}
+}
+
+type decoder struct {
+	r   *bufio.Reader
+	err error
+	// source is shared by every chunk read.
+	source *ast.Source
+}
+
+func (d *decoder) bytes(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+
+	// Grow the buffer as data arrives rather than trusting n up front.
+	var buffer bytes.Buffer
+	_, d.err = io.CopyN(&buffer, d.r, int64(n))
+	return buffer.Bytes()
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+
+	var b byte
+	b, d.err = d.r.ReadByte()
+	return b
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+
+	var value uint64
+	value, d.err = binary.ReadUvarint(d.r)
+	return value
+}
+
+// length reads a count that must not exceed what a well-formed file could
+// contain, so a corrupt file cannot trigger a huge allocation.
+func (d *decoder) length() int {
+	n := d.uvarint()
+	if n > math.MaxInt32 {
+		if d.err == nil {
+			d.err = fmt.Errorf("length %d out of range", n)
+		}
+		return 0
+	}
+	return int(n)
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes(d.length()))
+}
+
+func (d *decoder) function() *Function {
+	function := &Function{
+		Name:         d.string(),
+		Arity:        d.length(),
+		UpvalueCount: d.length(),
+	}
+
+	chunk := &function.Chunk
+	chunk.Code = d.bytes(d.length())
+
+	runs := d.length()
+	for range runs {
+		count := d.length()
+		line := d.length()
+		if count == 0 || len(chunk.Lines)+count > len(chunk.Code) {
+			if d.err == nil {
+				d.err = errors.New("line table does not match code")
+			}
+			return function
+		}
+		for range count {
+			chunk.Lines = append(chunk.Lines, line)
+		}
+		if d.err != nil {
+			return function
+		}
+	}
+	if d.err == nil && len(chunk.Lines) != len(chunk.Code) {
+		d.err = errors.New("line table does not match code")
+	}
+
+	runs = d.length()
+	for range runs {
+		count := d.length()
+		span := Span{Column: d.length(), Offset: d.length(), Length: d.length()}
+		if count == 0 || len(chunk.Spans)+count > len(chunk.Code) || span.Offset+span.Length > len(d.source.Text) {
+			if d.err == nil {
+				d.err = errors.New("span table does not match code")
+			}
+			return function
+		}
+		for range count {
+			chunk.Spans = append(chunk.Spans, span)
+		}
+	}
+	// Chunks compiled without a source have no spans at all.
+	if d.err == nil && len(chunk.Spans) != len(chunk.Code) && len(chunk.Spans) != 0 {
+		d.err = errors.New("span table does not match code")
+	}
+	if len(chunk.Spans) > 0 {
+		chunk.Source = d.source
+	}
+
+	constants := d.length()
+	for range constants {
+		if d.err != nil {
+			return function
+		}
+
+		switch tag := d.byte(); tag {
+		case constantNumber:
+			b := d.bytes(8)
+			if d.err == nil {
+				chunk.Constants = append(chunk.Constants, math.Float64frombits(binary.BigEndian.Uint64(b)))
+			}
+		case constantString:
+			chunk.Constants = append(chunk.Constants, d.string())
+		case constantFunction:
+			chunk.Constants = append(chunk.Constants, d.function())
+		default:
+			if d.err == nil {
+				d.err = fmt.Errorf("unknown constant tag %d", tag)
+			}
+		}
+	}
+
+	return function
+}
@@ -0,0 +1,176 @@
+package compiler_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/distolma/golox/cmd/myinterpreter/compiler"
+	"github.com/distolma/golox/cmd/myinterpreter/interpreter"
+	"github.com/distolma/golox/cmd/myinterpreter/limits"
+	logerror "github.com/distolma/golox/cmd/myinterpreter/log_error"
+	"github.com/distolma/golox/cmd/myinterpreter/parser"
+	"github.com/distolma/golox/cmd/myinterpreter/resolver"
+	"github.com/distolma/golox/cmd/myinterpreter/scanner"
+	"github.com/distolma/golox/cmd/myinterpreter/vm"
+)
+
+const program = `
+class Counter {
+  init(start) { this.count = start; }
+  next() { this.count = this.count + 1; return this.count; }
+}
+
+class Stepper < Counter {
+  next() { return super.next() + 1; }
+}
+
+fun makeAdder(n) {
+  fun add(x) { return x + n; }
+  return add;
+}
+
+var stepper = Stepper(0);
+var items = [1, 2, 3];
+var totals = {"a": 1};
+for (var i = 0; i < 3; i = i + 1) {
+  items[i] = makeAdder(i)(stepper.next());
+}
+try {
+  throw "oops";
+} catch (e) {
+  totals["b"] = e;
+}
+print items;
+print totals["b"];
+`
+
+func compile(t *testing.T, source string) *compiler.Function {
+	t.Helper()
+
+	log := &logerror.LogError{Output: io.Discard}
+	statements := parser.NewParser(scanner.NewFileScanner("program.lox", source, log).ScanTokens(), log).Parse()
+	if !log.HadError {
+		resolver.NewResolver(interpreter.NewInterpreter(log), log).ResolveStmts(statements)
+	}
+	var function *compiler.Function
+	if !log.HadError {
+		function = compiler.NewCompiler(log).Compile(statements)
+	}
+	if log.HadError {
+		t.Fatalf("compiling failed: %v", log.Reports)
+	}
+	return function
+}
+
+func encode(t *testing.T, artifact *compiler.Artifact) []byte {
+	t.Helper()
+
+	var buffer bytes.Buffer
+	if err := compiler.Encode(&buffer, artifact); err != nil {
+		t.Fatalf("Encode() = %v", err)
+	}
+	return buffer.Bytes()
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	artifact := &compiler.Artifact{
+		SourceHash: compiler.HashSource(program),
+		SourcePath: "program.lox",
+		Function:   compile(t, program),
+	}
+
+	decoded, err := compiler.Decode(bytes.NewReader(encode(t, artifact)))
+	if err != nil {
+		t.Fatalf("Decode() = %v", err)
+	}
+	if decoded.SourceHash != artifact.SourceHash || decoded.SourcePath != artifact.SourcePath {
+		t.Errorf("Decode() header = %x %q, want %x %q", decoded.SourceHash, decoded.SourcePath, artifact.SourceHash, artifact.SourcePath)
+	}
+	if got, want := compiler.Disassemble(decoded.Function), compiler.Disassemble(artifact.Function); got != want {
+		t.Errorf("Decode() code =\n%s\nwant\n%s", got, want)
+	}
+
+	chunk, want := decoded.Function.Chunk, artifact.Function.Chunk
+	if !slices.Equal(chunk.Spans, want.Spans) {
+		t.Errorf("Decode() spans = %v, want %v", chunk.Spans, want.Spans)
+	}
+	if chunk.Source == nil || *chunk.Source != *want.Source {
+		t.Errorf("Decode() source = %v, want %v", chunk.Source, want.Source)
+	}
+}
+
+func TestDecodeRejectsOtherVersions(t *testing.T) {
+	data := encode(t, &compiler.Artifact{Function: compile(t, program)})
+	data[len(compiler.Magic)] = compiler.FormatVersion - 1
+
+	if _, err := compiler.Decode(bytes.NewReader(data)); err == nil {
+		t.Errorf("Decode() of version %d succeeded", compiler.FormatVersion-1)
+	}
+}
+
+func TestDecodeRejectsInvalidCode(t *testing.T) {
+	tests := map[string]compiler.Chunk{
+		"unknown opcode":      {Code: []byte{255}},
+		"missing operand":     {Code: []byte{byte(compiler.OpConstant), 0}},
+		"constant range":      {Code: []byte{byte(compiler.OpConstant), 0, 1, byte(compiler.OpReturn)}, Constants: []interface{}{1.0}},
+		"name not a string":   {Code: []byte{byte(compiler.OpGetGlobal), 0, 0, byte(compiler.OpReturn)}, Constants: []interface{}{1.0}},
+		"upvalue range":       {Code: []byte{byte(compiler.OpGetUpvalue), 0, byte(compiler.OpReturn)}},
+		"jump into operand":   {Code: []byte{byte(compiler.OpJump), 0, 1, byte(compiler.OpConstant), 0, 0, byte(compiler.OpReturn)}, Constants: []interface{}{1.0}},
+		"jump past end":       {Code: []byte{byte(compiler.OpJump), 0, 9, byte(compiler.OpNil), byte(compiler.OpReturn)}},
+		"runs off end":        {Code: []byte{byte(compiler.OpNil)}},
+		"stack underflow":     {Code: []byte{byte(compiler.OpPop), byte(compiler.OpPop), byte(compiler.OpReturn)}},
+		"local out of stack":  {Code: []byte{byte(compiler.OpGetLocal), 3, byte(compiler.OpReturn)}},
+		"list out of stack":   {Code: []byte{byte(compiler.OpList), 0, 5, byte(compiler.OpReturn)}},
+		"call out of stack":   {Code: []byte{byte(compiler.OpCall), 4, byte(compiler.OpReturn)}},
+		"uneven stack height": {Code: []byte{byte(compiler.OpNil), byte(compiler.OpJumpIfFalse), 0, 1, byte(compiler.OpNil), byte(compiler.OpReturn)}},
+	}
+
+	for name, chunk := range tests {
+		t.Run(name, func(t *testing.T) {
+			chunk.Lines = make([]int, len(chunk.Code))
+			data := encode(t, &compiler.Artifact{Function: &compiler.Function{Chunk: chunk}})
+			if _, err := compiler.Decode(bytes.NewReader(data)); !errors.Is(err, compiler.ErrInvalidFormat) {
+				t.Errorf("Decode() = %v, want %v", err, compiler.ErrInvalidFormat)
+			}
+		})
+	}
+}
+
+// TestDecodeCorruptFiles damages a compiled file one byte at a time. Decode
+// must either reject the file or return code that the VM runs without a Go
+// panic.
+func TestDecodeCorruptFiles(t *testing.T) {
+	data := encode(t, &compiler.Artifact{Function: compile(t, program)})
+
+	for i := range data {
+		for _, mask := range []byte{0x01, 0x80, 0xff} {
+			corrupt := bytes.Clone(data)
+			corrupt[i] ^= mask
+
+			artifact, err := compiler.Decode(bytes.NewReader(corrupt))
+			if err != nil {
+				continue
+			}
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						t.Errorf("byte %d ^ %#x: running decoded code panicked: %v", i, mask, r)
+					}
+				}()
+
+				ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+				defer cancel()
+
+				machine := vm.NewVM(&logerror.LogError{Output: io.Discard})
+				machine.SetStdout(io.Discard)
+				machine.SetLimits(limits.Limits{MaxSteps: 10000, MaxMemory: 1 << 20})
+				machine.Interpret(ctx, artifact.Function)
+			}()
+		}
+	}
+}
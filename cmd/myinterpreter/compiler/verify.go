@@ -0,0 +1,283 @@
+package compiler
+
+import "fmt"
+
+// operandSizes gives the number of operand bytes that follow each opcode
+// that has any, apart from the upvalue pairs that follow OpClosure.
+var operandSizes = map[OpCode]int{
+	OpConstant:     2,
+	OpGetLocal:     1,
+	OpSetLocal:     1,
+	OpGetGlobal:    2,
+	OpDefineGlobal: 2,
+	OpSetGlobal:    2,
+	OpGetUpvalue:   1,
+	OpSetUpvalue:   1,
+	OpGetProperty:  2,
+	OpSetProperty:  2,
+	OpGetSuper:     2,
+	OpJump:         2,
+	OpJumpIfFalse:  2,
+	OpLoop:         2,
+	OpCall:         1,
+	OpInvoke:       3,
+	OpSuperInvoke:  3,
+	OpClosure:      2,
+	OpClass:        2,
+	OpMethod:       2,
+	OpList:         2,
+	OpMap:          2,
+	OpTry:          2,
+	OpImport:       2,
+}
+
+// stackEffects gives how many values each instruction needs on the stack
+// and how many it leaves in their place, for those that don't depend on an
+// operand.
+var stackEffects = map[OpCode][2]int{
+	OpConstant:     {0, 1},
+	OpNil:          {0, 1},
+	OpTrue:         {0, 1},
+	OpFalse:        {0, 1},
+	OpPop:          {1, 0},
+	OpGetLocal:     {0, 1},
+	OpSetLocal:     {1, 1},
+	OpGetGlobal:    {0, 1},
+	OpDefineGlobal: {1, 0},
+	OpSetGlobal:    {1, 1},
+	OpGetUpvalue:   {0, 1},
+	OpSetUpvalue:   {1, 1},
+	OpGetProperty:  {1, 1},
+	OpSetProperty:  {2, 1},
+	OpGetSuper:     {2, 1},
+	OpEqual:        {2, 1},
+	OpNotEqual:     {2, 1},
+	OpGreater:      {2, 1},
+	OpGreaterEqual: {2, 1},
+	OpLess:         {2, 1},
+	OpLessEqual:    {2, 1},
+	OpAdd:          {2, 1},
+	OpSubtract:     {2, 1},
+	OpMultiply:     {2, 1},
+	OpDivide:       {2, 1},
+	OpNot:          {1, 1},
+	OpNegate:       {1, 1},
+	OpPrint:        {1, 0},
+	OpJump:         {0, 0},
+	OpJumpIfFalse:  {1, 1},
+	OpLoop:         {0, 0},
+	OpClosure:      {0, 1},
+	OpCloseUpvalue: {1, 0},
+	OpReturn:       {1, 0},
+	OpClass:        {0, 1},
+	OpInherit:      {2, 1},
+	OpMethod:       {2, 1},
+	OpGetIndex:     {2, 1},
+	OpSetIndex:     {3, 1},
+	OpTry:          {0, 0},
+	OpEndTry:       {0, 0},
+	OpThrow:        {1, 0},
+	OpImport:       {0, 1},
+}
+
+// instruction is a decoded instruction of a chunk being verified.
+type instruction struct {
+	op     OpCode
+	next   int
+	target int
+	// pops and pushes are the values taken from and left on the stack.
+	pops   int
+	pushes int
+	// slot is the highest stack slot the instruction reads, or -1.
+	slot int
+}
+
+// verify checks that code read from a compiled file can be run without
+// reading outside its chunk or the stack: every opcode is known and has all
+// its operands, the constants and upvalues it references exist and have the
+// right type, jumps land on instructions, and every path through the code
+// finds the values it needs on the stack and ends in a return or throw.
+// Functions nested in the constant pool are checked too.
+func verify(function *Function) error {
+	if function.UpvalueCount > maxUpvalues {
+		return fmt.Errorf("%s captures %d variables", function, function.UpvalueCount)
+	}
+
+	instructions, err := decodeInstructions(function)
+	if err != nil {
+		return err
+	}
+	if err := checkStack(function, instructions); err != nil {
+		return err
+	}
+
+	for _, constant := range function.Chunk.Constants {
+		if nested, ok := constant.(*Function); ok {
+			if err := verify(nested); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// decodeInstructions splits the code of function into instructions by
+// offset, checking each one on its own.
+func decodeInstructions(function *Function) (map[int]instruction, error) {
+	chunk := &function.Chunk
+	code := chunk.Code
+	instructions := make(map[int]instruction)
+
+	for offset := 0; offset < len(code); {
+		op := OpCode(code[offset])
+		effect, ok := stackEffects[op]
+		if _, named := opNames[op]; !named {
+			return nil, fmt.Errorf("unknown opcode %d at %d in %s", op, offset, function)
+		}
+
+		operand := offset + 1
+		next := operand + operandSizes[op]
+		if next > len(code) {
+			return nil, fmt.Errorf("%s at %d in %s is missing operands", op, offset, function)
+		}
+		current := instruction{op: op, next: next, target: -1, pops: effect[0], pushes: effect[1], slot: -1}
+		if !ok {
+			// The counts of calls and literals are operands.
+			switch op {
+			case OpCall, OpInvoke:
+				argCount := int(code[next-1])
+				current.pops, current.pushes = argCount+1, 1
+			case OpSuperInvoke:
+				argCount := int(code[next-1])
+				current.pops, current.pushes = argCount+2, 1
+			case OpList:
+				current.pops, current.pushes = chunk.readShort(operand), 1
+			case OpMap:
+				current.pops, current.pushes = 2*chunk.readShort(operand), 1
+			}
+		}
+
+		switch op {
+		case OpConstant:
+			if err := checkConstant(chunk, operand); err != nil {
+				return nil, fmt.Errorf("%s at %d in %s: %w", op, offset, function, err)
+			}
+		case OpGetGlobal, OpDefineGlobal, OpSetGlobal, OpGetProperty, OpSetProperty, OpGetSuper,
+			OpClass, OpMethod, OpImport, OpInvoke, OpSuperInvoke:
+			if err := checkConstant(chunk, operand); err != nil {
+				return nil, fmt.Errorf("%s at %d in %s: %w", op, offset, function, err)
+			}
+			if _, ok := chunk.Constants[chunk.readShort(operand)].(string); !ok {
+				return nil, fmt.Errorf("%s at %d in %s names a constant that isn't a string", op, offset, function)
+			}
+		case OpGetLocal, OpSetLocal:
+			current.slot = int(code[operand])
+		case OpGetUpvalue, OpSetUpvalue:
+			if int(code[operand]) >= function.UpvalueCount {
+				return nil, fmt.Errorf("%s at %d in %s reads upvalue %d of %d", op, offset, function, code[operand], function.UpvalueCount)
+			}
+		case OpClosure:
+			if err := checkConstant(chunk, operand); err != nil {
+				return nil, fmt.Errorf("%s at %d in %s: %w", op, offset, function, err)
+			}
+			nested, ok := chunk.Constants[chunk.readShort(operand)].(*Function)
+			if !ok {
+				return nil, fmt.Errorf("%s at %d in %s refers to a constant that isn't a function", op, offset, function)
+			}
+			if next+2*nested.UpvalueCount > len(code) {
+				return nil, fmt.Errorf("%s at %d in %s is missing upvalues", op, offset, function)
+			}
+			for range nested.UpvalueCount {
+				isLocal, index := code[next], int(code[next+1])
+				switch {
+				case isLocal > 1, isLocal == 0 && index >= function.UpvalueCount:
+					return nil, fmt.Errorf("%s at %d in %s captures an invalid variable", op, offset, function)
+				case isLocal == 1:
+					current.slot = max(current.slot, index)
+				}
+				next += 2
+			}
+			current.next = next
+		case OpJump, OpJumpIfFalse, OpTry:
+			current.target = next + chunk.readShort(operand)
+		case OpLoop:
+			current.target = next - chunk.readShort(operand)
+		}
+
+		instructions[offset] = current
+		offset = next
+	}
+
+	for offset, current := range instructions {
+		if _, ok := instructions[current.target]; current.target >= 0 && !ok {
+			return nil, fmt.Errorf("%s at %d in %s jumps to %d, which isn't an instruction", current.op, offset, function, current.target)
+		}
+	}
+	return instructions, nil
+}
+
+// checkStack follows every path through the code from its start, working out
+// the height of the stack before each instruction. Slot zero holds the
+// function or receiver and the parameters follow it.
+func checkStack(function *Function, instructions map[int]instruction) error {
+	heights := map[int]int{0: function.Arity + 1}
+	pending := []int{0}
+	if _, ok := instructions[0]; !ok {
+		return fmt.Errorf("%s has no code", function)
+	}
+
+	reach := func(offset int, height int) error {
+		if _, ok := instructions[offset]; !ok {
+			return fmt.Errorf("%s runs past the end of its code", function)
+		}
+		if known, ok := heights[offset]; ok {
+			if known != height {
+				return fmt.Errorf("stack height at %d in %s is both %d and %d", offset, function, known, height)
+			}
+			return nil
+		}
+		heights[offset] = height
+		pending = append(pending, offset)
+		return nil
+	}
+
+	for len(pending) > 0 {
+		offset := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		current, height := instructions[offset], heights[offset]
+
+		if current.pops > height || current.slot >= height {
+			return fmt.Errorf("%s at %d in %s reads past the stack", current.op, offset, function)
+		}
+		after := height - current.pops + current.pushes
+
+		var err error
+		switch current.op {
+		case OpReturn, OpThrow:
+		case OpJump, OpLoop:
+			err = reach(current.target, after)
+		case OpJumpIfFalse:
+			if err = reach(current.next, after); err == nil {
+				err = reach(current.target, after)
+			}
+		case OpTry:
+			// The handler starts with the error object pushed.
+			if err = reach(current.next, after); err == nil {
+				err = reach(current.target, after+1)
+			}
+		default:
+			err = reach(current.next, after)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkConstant(chunk *Chunk, operand int) error {
+	if index := chunk.readShort(operand); index >= len(chunk.Constants) {
+		return fmt.Errorf("constant %d out of range", index)
+	}
+	return nil
+}
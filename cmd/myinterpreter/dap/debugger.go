@@ -0,0 +1,337 @@
+package dap
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/distolma/golox/cmd/myinterpreter/ast"
+	"github.com/distolma/golox/cmd/myinterpreter/interpreter"
+	logerror "github.com/distolma/golox/cmd/myinterpreter/log_error"
+	"github.com/distolma/golox/cmd/myinterpreter/parser"
+	"github.com/distolma/golox/cmd/myinterpreter/resolver"
+	"github.com/distolma/golox/cmd/myinterpreter/scanner"
+)
+
+// The program runs on a thread of its own, which is the only one there is.
+const threadID = 1
+
+// stepMode is what the program does when it is resumed.
+type stepMode int
+
+const (
+	stepContinue stepMode = iota
+	stepEntry
+	stepIn
+	stepOver
+	stepOut
+)
+
+// lineBreakpoint stops the program before a statement starting on its line,
+// provided its condition, if any, holds.
+type lineBreakpoint struct {
+	line      int
+	condition ast.Expr
+}
+
+// command is sent to the paused program: either a function to run while it
+// waits, or a request to carry on.
+type command struct {
+	inspect func()
+	resume  stepMode
+	done    chan struct{}
+}
+
+var errNotPaused = errors.New("The program isn't paused.")
+
+// debugger runs a program under the tree-walking interpreter and stops it
+// where the client asks. The interpreter and everything it holds are only
+// touched on the goroutine running the program; the server reaches them
+// through commands while the program is paused.
+type debugger struct {
+	server      *Server
+	interpreter *interpreter.Interpreter
+	log         *logerror.LogError
+	statements  []ast.Stmt
+	cancel      context.CancelFunc
+	commands    chan command
+
+	mu             sync.Mutex
+	breakpoints    map[string][]*lineBreakpoint
+	paths          map[*ast.Source]string
+	paused         bool
+	pauseRequested bool
+	terminated     bool
+
+	// Set on the program's goroutine only.
+	mode       stepMode
+	depth      int
+	frames     []interpreter.Frame
+	references references
+}
+
+func newDebugger(server *Server) *debugger {
+	return &debugger{
+		server:      server,
+		commands:    make(chan command),
+		breakpoints: make(map[string][]*lineBreakpoint),
+		paths:       make(map[*ast.Source]string),
+	}
+}
+
+// load reads, parses and resolves the program, reporting errors as output.
+func (d *debugger) load(program string, searchPath []string, stopOnEntry bool) bool {
+	d.log = &logerror.LogError{Output: d.server.output("stderr")}
+	d.interpreter = interpreter.NewInterpreter(d.log)
+	d.interpreter.SetStdout(d.server.output("stdout"))
+	d.interpreter.SetSearchPath(searchPath)
+	d.interpreter.SetModuleLoader(d.loadModule)
+	if err := d.interpreter.SetScriptPath(program); err != nil {
+		d.log.Output.Write([]byte(err.Error() + "\n"))
+		return false
+	}
+
+	var ok bool
+	d.statements, ok = d.loadModule(program)
+	if !ok {
+		return false
+	}
+
+	if stopOnEntry {
+		d.mode = stepEntry
+	}
+	d.interpreter.SetHook(d)
+	return true
+}
+
+func (d *debugger) loadModule(path string) ([]ast.Stmt, bool) {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		d.log.Output.Write([]byte("Error reading file: " + err.Error() + "\n"))
+		return nil, false
+	}
+
+	tokens := scanner.NewFileScanner(path, string(file), d.log).ScanTokens()
+	statements := parser.NewParser(tokens, d.log).Parse()
+	if d.log.HadError {
+		return nil, false
+	}
+
+	resolver.NewResolver(d.interpreter, d.log).ResolveStmts(statements)
+	return statements, !d.log.HadError
+}
+
+// run runs the program to the end and reports how it exited.
+func (d *debugger) run() {
+	ctx, cancel := context.WithCancel(context.Background())
+	d.cancel = cancel
+	go func() {
+		defer cancel()
+		d.interpreter.Interpret(ctx, d.statements)
+
+		exitCode := 0
+		if d.log.HadRuntimeError {
+			exitCode = 70
+		}
+		d.server.event("exited", exitedEvent{ExitCode: exitCode})
+		d.server.event("terminated", nil)
+	}()
+}
+
+// setBreakpoints replaces the breakpoints in the file at path.
+func (d *debugger) setBreakpoints(path string, breakpoints []*lineBreakpoint) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.breakpoints[path] = breakpoints
+}
+
+// Step implements interpreter.Hook.
+func (d *debugger) Step(stmt ast.Stmt, frames []interpreter.Frame) {
+	token, ok := ast.Start(stmt)
+	if !ok || token.Source == nil {
+		return
+	}
+
+	if reason := d.stopReason(token, frames); reason != "" {
+		d.stop(reason, frames)
+	}
+}
+
+func (d *debugger) stopReason(token ast.Token, frames []interpreter.Frame) string {
+	d.mu.Lock()
+	if d.terminated {
+		d.mu.Unlock()
+		return ""
+	}
+	if d.pauseRequested {
+		d.pauseRequested = false
+		d.mu.Unlock()
+		return "pause"
+	}
+	breakpoints := d.breakpoints[d.path(token.Source)]
+	d.mu.Unlock()
+
+	for _, breakpoint := range breakpoints {
+		if breakpoint.line == token.Line && d.holds(breakpoint, frames) {
+			return "breakpoint"
+		}
+	}
+
+	switch d.mode {
+	case stepEntry:
+		return "entry"
+	case stepIn:
+		return "step"
+	case stepOver:
+		if len(frames) <= d.depth {
+			return "step"
+		}
+	case stepOut:
+		if len(frames) < d.depth {
+			return "step"
+		}
+	}
+	return ""
+}
+
+// holds reports whether the condition of a breakpoint is true in the
+// innermost frame. A condition that fails to evaluate stops the program so
+// that the mistake is noticed.
+func (d *debugger) holds(breakpoint *lineBreakpoint, frames []interpreter.Frame) bool {
+	if breakpoint.condition == nil {
+		return true
+	}
+
+	value, err := evaluate(d.interpreter, breakpoint.condition, frames[len(frames)-1].Environment)
+	if err != nil {
+		return true
+	}
+	return value != nil && value != false
+}
+
+// path is the absolute path of the file a token came from.
+func (d *debugger) path(file *ast.Source) string {
+	path, ok := d.paths[file]
+	if !ok {
+		path = absolute(file.Name)
+		d.paths[file] = path
+	}
+	return path
+}
+
+// stop tells the client the program stopped and serves its commands until
+// it is resumed.
+func (d *debugger) stop(reason string, frames []interpreter.Frame) {
+	d.frames = append([]interpreter.Frame(nil), frames...)
+	d.references.reset()
+
+	d.mu.Lock()
+	d.paused = true
+	d.mu.Unlock()
+	d.server.event("stopped", stoppedEvent{Reason: reason, ThreadID: threadID, AllThreadsStopped: true})
+
+	for command := range d.commands {
+		if command.inspect != nil {
+			command.inspect()
+			close(command.done)
+			continue
+		}
+
+		d.mu.Lock()
+		d.paused = false
+		d.mu.Unlock()
+
+		d.mode = command.resume
+		d.depth = len(frames)
+		close(command.done)
+		return
+	}
+}
+
+func (d *debugger) isPaused() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.paused
+}
+
+// inspect runs f on the program's goroutine while it is paused.
+func (d *debugger) inspect(f func()) error {
+	if !d.isPaused() {
+		return errNotPaused
+	}
+
+	done := make(chan struct{})
+	d.commands <- command{inspect: f, done: done}
+	<-done
+	return nil
+}
+
+// resume carries on running a paused program in the given mode.
+func (d *debugger) resume(mode stepMode) error {
+	if !d.isPaused() {
+		return errNotPaused
+	}
+
+	done := make(chan struct{})
+	d.commands <- command{resume: mode, done: done}
+	<-done
+	return nil
+}
+
+// pause stops the program before its next statement.
+func (d *debugger) pause() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pauseRequested = true
+}
+
+// terminate cancels the program. Output stops at once, though it only halts
+// at the next loop iteration or call.
+func (d *debugger) terminate() {
+	d.mu.Lock()
+	d.terminated = true
+	d.mu.Unlock()
+
+	d.server.mute()
+	if d.cancel != nil {
+		d.cancel()
+	}
+	d.resume(stepContinue)
+}
+
+// stackFrames lists the frames of the paused program, innermost first.
+func (d *debugger) stackFrames() []stackFrame {
+	var stackFrames []stackFrame
+	for index := len(d.frames) - 1; index >= 0; index-- {
+		frame := d.frames[index]
+		stackFrame := stackFrame{ID: index + 1, Name: frame.Name}
+		if token, ok := ast.Start(frame.Statement); ok && token.Source != nil {
+			path := d.path(token.Source)
+			stackFrame.Source = &source{Name: filepath.Base(path), Path: path}
+			stackFrame.Line = token.Line
+			stackFrame.Column = token.Column
+		}
+		stackFrames = append(stackFrames, stackFrame)
+	}
+	return stackFrames
+}
+
+// frame returns the frame with the given id, or the innermost one for 0.
+func (d *debugger) frame(id int) (interpreter.Frame, bool) {
+	if id == 0 && len(d.frames) > 0 {
+		id = len(d.frames)
+	}
+	if id < 1 || id > len(d.frames) {
+		return interpreter.Frame{}, false
+	}
+	return d.frames[id-1], true
+}
+
+func absolute(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
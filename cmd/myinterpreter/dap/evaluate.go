@@ -0,0 +1,114 @@
+package dap
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/distolma/golox/cmd/myinterpreter/ast"
+	"github.com/distolma/golox/cmd/myinterpreter/environment"
+	"github.com/distolma/golox/cmd/myinterpreter/interpreter"
+	logerror "github.com/distolma/golox/cmd/myinterpreter/log_error"
+	"github.com/distolma/golox/cmd/myinterpreter/parser"
+	"github.com/distolma/golox/cmd/myinterpreter/scanner"
+)
+
+// parseExpression parses the text of a breakpoint condition or a watch.
+func parseExpression(text string) (ast.Expr, error) {
+	log := &logerror.LogError{Output: io.Discard}
+	tokens := scanner.NewScanner(text, log).ScanTokens()
+	expr := parser.NewParser(tokens, log).ParseExpression()
+	if log.HadError {
+		return nil, errors.New(log.Reports[0].Message)
+	}
+	return expr, nil
+}
+
+// evaluate runs expr in env. Names are bound to the scopes env can see at
+// the time, since the resolver never saw the expression.
+func evaluate(i *interpreter.Interpreter, expr ast.Expr, env *environment.Environment) (interface{}, error) {
+	if err := bind(i, expr, env); err != nil {
+		return nil, err
+	}
+
+	value, err := i.EvaluateIn(expr, env)
+	if runtimeError, ok := err.(*interpreter.RuntimeError); ok {
+		return nil, errors.New(runtimeError.Message)
+	}
+	return value, err
+}
+
+// bind tells the interpreter how far out each local variable in expr is
+// from env. Names that aren't found are left to be looked up as globals.
+func bind(i *interpreter.Interpreter, expr ast.Expr, env *environment.Environment) error {
+	local := func(expr ast.Expr, name ast.Token) bool {
+		distance := 0
+		for scope := env; scope != nil; scope = scope.Enclosing {
+			if _, ok := scope.Lookup(name.Lexeme); ok {
+				i.Resolve(expr, distance)
+				return true
+			}
+			distance++
+		}
+		// A depth bound when the expression was evaluated at an earlier stop
+		// would point into the wrong scope here.
+		i.Unresolve(expr)
+		return false
+	}
+
+	switch expr := expr.(type) {
+	case *ast.Assign:
+		local(expr, expr.Name)
+		return bind(i, expr.Value, env)
+	case *ast.Binary:
+		return errors.Join(bind(i, expr.Left, env), bind(i, expr.Right, env))
+	case *ast.Call:
+		err := bind(i, expr.Callee, env)
+		for _, argument := range expr.Arguments {
+			err = errors.Join(err, bind(i, argument, env))
+		}
+		return err
+	case *ast.Get:
+		return bind(i, expr.Object, env)
+	case *ast.Grouping:
+		return bind(i, expr.Expression, env)
+	case *ast.Index:
+		return errors.Join(bind(i, expr.Object, env), bind(i, expr.Index, env))
+	case *ast.Lambda:
+		return errors.New("Can't evaluate a function here.")
+	case *ast.List:
+		var err error
+		for _, element := range expr.Elements {
+			err = errors.Join(err, bind(i, element, env))
+		}
+		return err
+	case *ast.Logical:
+		return errors.Join(bind(i, expr.Left, env), bind(i, expr.Right, env))
+	case *ast.Map:
+		var err error
+		for index := range expr.Keys {
+			err = errors.Join(err, bind(i, expr.Keys[index], env), bind(i, expr.Values[index], env))
+		}
+		return err
+	case *ast.Set:
+		return errors.Join(bind(i, expr.Object, env), bind(i, expr.Value, env))
+	case *ast.SetIndex:
+		return errors.Join(bind(i, expr.Object, env), bind(i, expr.Index, env), bind(i, expr.Value, env))
+	case *ast.Super:
+		if !local(expr, expr.Keyword) {
+			return errors.New("Can't use 'super' here.")
+		}
+	case *ast.This:
+		if !local(expr, expr.Keyword) {
+			return errors.New("Can't use 'this' here.")
+		}
+	case *ast.Unary:
+		return bind(i, expr.Right, env)
+	case *ast.Variable:
+		local(expr, expr.Name)
+	case *ast.Literal:
+	default:
+		return fmt.Errorf("Can't evaluate %T.", expr)
+	}
+	return nil
+}
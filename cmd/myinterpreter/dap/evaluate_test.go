@@ -0,0 +1,36 @@
+package dap
+
+import (
+	"io"
+	"testing"
+
+	"github.com/distolma/golox/cmd/myinterpreter/environment"
+	"github.com/distolma/golox/cmd/myinterpreter/interpreter"
+	logerror "github.com/distolma/golox/cmd/myinterpreter/log_error"
+)
+
+// TestEvaluateRebindsNames evaluates one parsed condition at several stops,
+// the way a breakpoint condition is, and checks that each stop sees its own
+// scopes.
+func TestEvaluateRebindsNames(t *testing.T) {
+	i := interpreter.NewInterpreter(&logerror.LogError{Output: io.Discard})
+	expr, err := parseExpression("n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outer := environment.NewEnvironment(nil)
+	outer.Define("n", 1.0)
+	inner := environment.NewEnvironment(environment.NewEnvironment(outer))
+	inner.Define("n", 2.0)
+
+	if value, err := evaluate(i, expr, inner); err != nil || value != 2.0 {
+		t.Errorf("evaluate() in the inner scope = %v, %v, want 2", value, err)
+	}
+	if value, err := evaluate(i, expr, outer); err != nil || value != 1.0 {
+		t.Errorf("evaluate() in the outer scope = %v, %v, want 1", value, err)
+	}
+	if value, err := evaluate(i, expr, environment.NewEnvironment(nil)); err == nil || err.Error() != "Undefined variable 'n'." {
+		t.Errorf("evaluate() where n isn't defined = %v, %v, want an undefined variable error", value, err)
+	}
+}
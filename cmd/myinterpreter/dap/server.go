@@ -0,0 +1,349 @@
+// Package dap implements a Debug Adapter Protocol server that runs Lox
+// programs under the tree-walking interpreter.
+package dap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/distolma/golox/cmd/myinterpreter/ast"
+	"github.com/distolma/golox/cmd/myinterpreter/framing"
+	"github.com/distolma/golox/cmd/myinterpreter/interpreter"
+)
+
+type Server struct {
+	in         *framing.Reader
+	out        io.Writer
+	searchPath []string
+	debugger   *debugger
+	launched   bool
+	configured bool
+	started    bool
+	nextID     int
+
+	// mu guards writing messages, which the program does as it runs.
+	mu    sync.Mutex
+	seq   int
+	muted bool
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	s := &Server{in: framing.NewReader(in), out: out}
+	s.debugger = newDebugger(s)
+	return s
+}
+
+// SetSearchPath sets the directories searched for the program's imports.
+func (s *Server) SetSearchPath(directories []string) {
+	s.searchPath = directories
+}
+
+// Run serves requests until the client disconnects or closes the input.
+func (s *Server) Run() error {
+	for {
+		body, err := s.in.Read()
+		if err == io.EOF {
+			s.debugger.terminate()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			return fmt.Errorf("invalid message: %v", err)
+		}
+
+		s.handle(&req)
+		if req.Command == "disconnect" {
+			return nil
+		}
+	}
+}
+
+// write sends a message, numbering it with the next sequence number.
+func (s *Server) write(message func(seq int) interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	body, err := json.Marshal(message(s.seq))
+	if err != nil {
+		panic(err)
+	}
+	framing.Write(s.out, body)
+}
+
+func (s *Server) reply(req *request, body interface{}, err error) {
+	s.write(func(seq int) interface{} {
+		resp := response{Seq: seq, Type: "response", RequestSeq: req.Seq, Success: err == nil, Command: req.Command, Body: body}
+		if err != nil {
+			resp.Message = err.Error()
+		}
+		return resp
+	})
+}
+
+func (s *Server) event(name string, body interface{}) {
+	s.write(func(seq int) interface{} {
+		return event{Seq: seq, Type: "event", Event: name, Body: body}
+	})
+}
+
+// output returns a writer whose writes reach the client as output events in
+// category.
+func (s *Server) output(category string) io.Writer {
+	return outputWriter{server: s, category: category}
+}
+
+// mute drops the program's output from now on.
+func (s *Server) mute() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.muted = true
+}
+
+type outputWriter struct {
+	server   *Server
+	category string
+}
+
+func (w outputWriter) Write(p []byte) (int, error) {
+	w.server.mu.Lock()
+	muted := w.server.muted
+	w.server.mu.Unlock()
+
+	if !muted {
+		w.server.event("output", outputEvent{Category: w.category, Output: string(p)})
+	}
+	return len(p), nil
+}
+
+func (s *Server) handle(req *request) {
+	var body interface{}
+	var err error
+	switch req.Command {
+	case "initialize":
+		body = capabilities{
+			SupportsConfigurationDoneRequest: true,
+			SupportsConditionalBreakpoints:   true,
+			SupportsEvaluateForHovers:        true,
+			SupportsTerminateRequest:         true,
+		}
+		s.reply(req, body, nil)
+		s.event("initialized", nil)
+		return
+	case "launch":
+		var args launchArguments
+		if err = decode(req.Arguments, &args); err == nil {
+			err = s.launch(args)
+		}
+	case "setBreakpoints":
+		var args setBreakpointsArguments
+		if err = decode(req.Arguments, &args); err == nil {
+			body = s.setBreakpoints(args)
+		}
+	case "configurationDone":
+		s.configured = true
+		s.start()
+	case "threads":
+		body = map[string]interface{}{"threads": []thread{{ID: threadID, Name: "main"}}}
+	case "stackTrace":
+		var args stackTraceArguments
+		if err = decode(req.Arguments, &args); err == nil {
+			body, err = s.stackTrace(args)
+		}
+	case "scopes":
+		var args scopesArguments
+		if err = decode(req.Arguments, &args); err == nil {
+			body, err = s.scopes(args)
+		}
+	case "variables":
+		var args variablesArguments
+		if err = decode(req.Arguments, &args); err == nil {
+			body, err = s.variables(args)
+		}
+	case "evaluate":
+		var args evaluateArguments
+		if err = decode(req.Arguments, &args); err == nil {
+			body, err = s.evaluate(args)
+		}
+	case "continue":
+		s.resume(req, stepContinue, map[string]interface{}{"allThreadsContinued": true})
+		return
+	case "next":
+		s.resume(req, stepOver, nil)
+		return
+	case "stepIn":
+		s.resume(req, stepIn, nil)
+		return
+	case "stepOut":
+		s.resume(req, stepOut, nil)
+		return
+	case "pause":
+		s.debugger.pause()
+	case "terminate", "disconnect":
+		s.debugger.terminate()
+	default:
+		err = fmt.Errorf("Unsupported command: %s.", req.Command)
+	}
+
+	s.reply(req, body, err)
+}
+
+// resume replies to a request to resume the program before doing so, so
+// that the reply comes ahead of the program stopping again.
+func (s *Server) resume(req *request, mode stepMode, body interface{}) {
+	if !s.debugger.isPaused() {
+		s.reply(req, nil, errNotPaused)
+		return
+	}
+
+	s.reply(req, body, nil)
+	s.debugger.resume(mode)
+}
+
+func decode(arguments json.RawMessage, v interface{}) error {
+	if len(arguments) == 0 {
+		return nil
+	}
+	return json.Unmarshal(arguments, v)
+}
+
+func (s *Server) launch(args launchArguments) error {
+	if s.launched {
+		return errors.New("A program is already running.")
+	}
+	if args.Program == "" {
+		return errors.New("No program to debug.")
+	}
+
+	if !s.debugger.load(absolute(args.Program), s.searchPath, args.StopOnEntry) {
+		return fmt.Errorf("Could not load '%s'.", args.Program)
+	}
+	s.launched = true
+	s.start()
+	return nil
+}
+
+// start runs the program once it is launched and the client has finished
+// setting breakpoints.
+func (s *Server) start() {
+	if s.launched && s.configured && !s.started {
+		s.started = true
+		s.debugger.run()
+	}
+}
+
+func (s *Server) setBreakpoints(args setBreakpointsArguments) map[string]interface{} {
+	path := absolute(args.Source.Path)
+
+	var breakpoints []*lineBreakpoint
+	result := []breakpoint{}
+	for _, requested := range args.Breakpoints {
+		s.nextID++
+		verified := true
+		var condition ast.Expr
+		if requested.Condition != "" {
+			var err error
+			condition, err = parseExpression(requested.Condition)
+			verified = err == nil
+		}
+
+		if verified {
+			breakpoints = append(breakpoints, &lineBreakpoint{line: requested.Line, condition: condition})
+		}
+		result = append(result, breakpoint{ID: s.nextID, Verified: verified, Line: requested.Line, Source: &args.Source})
+	}
+
+	s.debugger.setBreakpoints(path, breakpoints)
+	return map[string]interface{}{"breakpoints": result}
+}
+
+func (s *Server) stackTrace(args stackTraceArguments) (map[string]interface{}, error) {
+	var frames []stackFrame
+	err := s.debugger.inspect(func() {
+		frames = s.debugger.stackFrames()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	total := len(frames)
+	frames = frames[min(args.StartFrame, total):]
+	if args.Levels > 0 && args.Levels < len(frames) {
+		frames = frames[:args.Levels]
+	}
+	return map[string]interface{}{"stackFrames": frames, "totalFrames": total}, nil
+}
+
+func (s *Server) scopes(args scopesArguments) (map[string]interface{}, error) {
+	var scopes []scope
+	var ok bool
+	err := s.debugger.inspect(func() {
+		var frame interpreter.Frame
+		if frame, ok = s.debugger.frame(args.FrameID); ok {
+			scopes = s.debugger.references.scopes(frame.Environment)
+		}
+	})
+	if err == nil && !ok {
+		err = fmt.Errorf("Unknown frame %d.", args.FrameID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"scopes": scopes}, nil
+}
+
+func (s *Server) variables(args variablesArguments) (map[string]interface{}, error) {
+	variables := []variable{}
+	var ok bool
+	err := s.debugger.inspect(func() {
+		var children func() []variable
+		if children, ok = s.debugger.references.get(args.VariablesReference); ok {
+			variables = append(variables, children()...)
+		}
+	})
+	if err == nil && !ok {
+		err = fmt.Errorf("Unknown variables reference %d.", args.VariablesReference)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"variables": variables}, nil
+}
+
+// evaluate evaluates an expression typed in by the user in a frame of the
+// paused program.
+func (s *Server) evaluate(args evaluateArguments) (map[string]interface{}, error) {
+	expr, err := parseExpression(args.Expression)
+	if err != nil {
+		return nil, err
+	}
+
+	var result variable
+	var evalErr error
+	err = s.debugger.inspect(func() {
+		frame, ok := s.debugger.frame(args.FrameID)
+		if !ok {
+			evalErr = fmt.Errorf("Unknown frame %d.", args.FrameID)
+			return
+		}
+
+		var value interface{}
+		if value, evalErr = evaluate(s.debugger.interpreter, expr, frame.Environment); evalErr == nil {
+			result = s.debugger.references.variable("", value)
+		}
+	})
+	if err == nil {
+		err = evalErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"result": result.Value, "type": result.Type, "variablesReference": result.VariablesReference}, nil
+}
@@ -0,0 +1,200 @@
+package dap
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/distolma/golox/cmd/myinterpreter/framing"
+)
+
+// message is a response or event read back from the server.
+type message struct {
+	Type       string          `json:"type"`
+	Command    string          `json:"command"`
+	Event      string          `json:"event"`
+	RequestSeq int             `json:"request_seq"`
+	Success    bool            `json:"success"`
+	Message    string          `json:"message"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// client talks to a server running in the background over pipes.
+type client struct {
+	t        *testing.T
+	in       *io.PipeWriter
+	seq      int
+	messages chan message
+	done     chan error
+}
+
+func newClient(t *testing.T) *client {
+	t.Helper()
+
+	requests, in := io.Pipe()
+	out, responses := io.Pipe()
+	c := &client{t: t, in: in, messages: make(chan message, 100), done: make(chan error, 1)}
+
+	go func() {
+		c.done <- NewServer(requests, responses).Run()
+		responses.Close()
+	}()
+	go func() {
+		defer close(c.messages)
+		reader := framing.NewReader(out)
+		for {
+			body, err := reader.Read()
+			if err != nil {
+				return
+			}
+
+			var m message
+			if err := json.Unmarshal(body, &m); err == nil {
+				c.messages <- m
+			}
+		}
+	}()
+
+	t.Cleanup(func() {
+		in.Close()
+		out.Close()
+	})
+	return c
+}
+
+// send makes a request and returns its sequence number.
+func (c *client) send(command string, arguments interface{}) int {
+	c.t.Helper()
+
+	c.seq++
+	body, err := json.Marshal(map[string]interface{}{"seq": c.seq, "type": "request", "command": command, "arguments": arguments})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if err := framing.Write(c.in, body); err != nil {
+		c.t.Fatalf("sending %s: %v", command, err)
+	}
+	return c.seq
+}
+
+// expect reads messages until one matches, failing if none arrives in time.
+func (c *client) expect(description string, match func(message) bool) message {
+	c.t.Helper()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case m, ok := <-c.messages:
+			if !ok {
+				c.t.Fatalf("server closed before %s", description)
+			}
+			if match(m) {
+				return m
+			}
+		case <-timeout:
+			c.t.Fatalf("timed out waiting for %s", description)
+		}
+	}
+}
+
+// request sends a request, which must succeed, and decodes the body of its
+// response into body unless that is nil.
+func (c *client) request(command string, arguments interface{}, body interface{}) {
+	c.t.Helper()
+
+	seq := c.send(command, arguments)
+	m := c.expect("response to "+command, func(m message) bool {
+		return m.Type == "response" && m.RequestSeq == seq
+	})
+	if !m.Success {
+		c.t.Fatalf("%s failed: %s", command, m.Message)
+	}
+	if body != nil {
+		if err := json.Unmarshal(m.Body, body); err != nil {
+			c.t.Fatalf("%s body: %v", command, err)
+		}
+	}
+}
+
+func (c *client) event(name string) message {
+	c.t.Helper()
+	return c.expect(name+" event", func(m message) bool {
+		return m.Type == "event" && m.Event == name
+	})
+}
+
+func TestBreakpointStackAndEvaluate(t *testing.T) {
+	program := filepath.Join(t.TempDir(), "program.lox")
+	text := "var a = 1;\nfun twice(x) {\n  return x * 2;\n}\nprint twice(a + 1);\n"
+	if err := os.WriteFile(program, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newClient(t)
+	c.request("initialize", map[string]interface{}{"adapterID": "lox"}, nil)
+	c.event("initialized")
+	c.request("launch", launchArguments{Program: program}, nil)
+
+	var breakpoints struct {
+		Breakpoints []breakpoint `json:"breakpoints"`
+	}
+	c.request("setBreakpoints", setBreakpointsArguments{
+		Source:      source{Path: program},
+		Breakpoints: []sourceBreakpoint{{Line: 3}},
+	}, &breakpoints)
+	if len(breakpoints.Breakpoints) != 1 || !breakpoints.Breakpoints[0].Verified {
+		t.Fatalf("breakpoints = %+v, want one verified", breakpoints.Breakpoints)
+	}
+	c.request("configurationDone", nil, nil)
+
+	var stopped stoppedEvent
+	if err := json.Unmarshal(c.event("stopped").Body, &stopped); err != nil {
+		t.Fatal(err)
+	}
+	if stopped.Reason != "breakpoint" {
+		t.Errorf("stopped for %q, want breakpoint", stopped.Reason)
+	}
+
+	var trace struct {
+		StackFrames []stackFrame `json:"stackFrames"`
+	}
+	c.request("stackTrace", stackTraceArguments{ThreadID: threadID}, &trace)
+	if len(trace.StackFrames) != 2 || trace.StackFrames[0].Line != 3 || trace.StackFrames[1].Line != 5 {
+		t.Fatalf("stack = %+v, want twice on line 3 called from line 5", trace.StackFrames)
+	}
+
+	var result struct {
+		Result string `json:"result"`
+	}
+	c.request("evaluate", evaluateArguments{Expression: "x + a", FrameID: trace.StackFrames[0].ID}, &result)
+	if result.Result != "3" {
+		t.Errorf("x + a = %q, want 3", result.Result)
+	}
+
+	c.request("continue", map[string]interface{}{"threadId": threadID}, nil)
+	var output outputEvent
+	if err := json.Unmarshal(c.event("output").Body, &output); err != nil {
+		t.Fatal(err)
+	}
+	if output.Output != "4\n" {
+		t.Errorf("output = %q, want %q", output.Output, "4\n")
+	}
+	c.event("terminated")
+
+	c.request("disconnect", nil, nil)
+	if err := <-c.done; err != nil {
+		t.Errorf("Run() = %v", err)
+	}
+}
+
+func TestUnsupportedCommand(t *testing.T) {
+	c := newClient(t)
+	seq := c.send("frobnicate", nil)
+	m := c.expect("response", func(m message) bool { return m.Type == "response" && m.RequestSeq == seq })
+	if m.Success || m.Message == "" {
+		t.Errorf("response = %+v, want a failure with a message", m)
+	}
+}
@@ -0,0 +1,135 @@
+package dap
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/distolma/golox/cmd/myinterpreter/collection"
+	"github.com/distolma/golox/cmd/myinterpreter/environment"
+	"github.com/distolma/golox/cmd/myinterpreter/interpreter"
+)
+
+// references hands out the variablesReference numbers through which the
+// client expands scopes and values. They are only good until the program
+// resumes.
+type references struct {
+	children []func() []variable
+}
+
+func (r *references) reset() {
+	r.children = nil
+}
+
+func (r *references) add(children func() []variable) int {
+	r.children = append(r.children, children)
+	return len(r.children)
+}
+
+func (r *references) get(reference int) (func() []variable, bool) {
+	if reference < 1 || reference > len(r.children) {
+		return nil, false
+	}
+	return r.children[reference-1], true
+}
+
+// scopes lists the environments visible from env, innermost first. The
+// natives every module shares are left out.
+func (r *references) scopes(env *environment.Environment) []scope {
+	var scopes []scope
+	for ; env != nil && env.Enclosing != nil; env = env.Enclosing {
+		name := "Locals"
+		if env.Enclosing.Enclosing == nil {
+			name = "Globals"
+		} else if len(scopes) > 0 {
+			name = "Enclosing"
+		}
+
+		scopes = append(scopes, scope{Name: name, VariablesReference: r.add(r.environment(env))})
+	}
+	return scopes
+}
+
+func (r *references) environment(env *environment.Environment) func() []variable {
+	return func() []variable {
+		var variables []variable
+		for _, name := range env.Names() {
+			value, _ := env.Lookup(name)
+			variables = append(variables, r.variable(name, value))
+		}
+		return variables
+	}
+}
+
+// variable describes a value, giving it a reference when it has fields or
+// elements to expand.
+func (r *references) variable(name string, value interface{}) variable {
+	v := variable{Name: name, Value: format(value), Type: typeName(value)}
+
+	switch value := value.(type) {
+	case *interpreter.Instance:
+		v.VariablesReference = r.add(func() []variable {
+			var variables []variable
+			for _, field := range value.FieldNames() {
+				fieldValue, _ := value.Property(field)
+				variables = append(variables, r.variable(field, fieldValue))
+			}
+			return variables
+		})
+	case *collection.List:
+		v.VariablesReference = r.add(func() []variable {
+			var variables []variable
+			for index, element := range value.Elements {
+				variables = append(variables, r.variable(fmt.Sprintf("[%d]", index), element))
+			}
+			return variables
+		})
+	case *collection.Map:
+		v.VariablesReference = r.add(func() []variable {
+			var variables []variable
+			values := value.Values()
+			for index, key := range value.Keys() {
+				variables = append(variables, r.variable(format(key), values[index]))
+			}
+			return variables
+		})
+	}
+	return v
+}
+
+// format shows a value the way it would be written in Lox.
+func format(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return strconv.Quote(value)
+	}
+	return fmt.Sprint(value)
+}
+
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case *interpreter.Class:
+		return "class"
+	case *interpreter.Instance:
+		return "instance"
+	case *interpreter.Module:
+		return "module"
+	case *collection.List:
+		return "list"
+	case *collection.Map:
+		return "map"
+	}
+	if interpreter.IsCallable(value) {
+		return "function"
+	}
+	return ""
+}
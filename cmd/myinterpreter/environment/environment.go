@@ -2,6 +2,7 @@ package environment
 
 import (
 	"fmt"
+	"slices"
 )
 
 type Environment struct {
@@ -25,6 +26,23 @@ func (e *Environment) Get(name string) (interface{}, error) {
 	return nil, fmt.Errorf("Undefined variable '%s'.", name)
 }
 
+// Lookup finds name in this environment only, ignoring enclosing ones.
+func (e *Environment) Lookup(name string) (interface{}, bool) {
+	value, ok := e.values[name]
+	return value, ok
+}
+
+// Names lists the variables defined in this environment only, in sorted
+// order.
+func (e *Environment) Names() []string {
+	names := make([]string, 0, len(e.values))
+	for name := range e.values {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
 func (e *Environment) Assign(name string, value interface{}) error {
 	if _, ok := e.values[name]; ok {
 		e.Define(name, value)
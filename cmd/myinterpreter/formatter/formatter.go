@@ -0,0 +1,453 @@
+// Package formatter prints Lox programs in a canonical layout: two-space
+// indentation, opening braces on the line of their statement, single spaces
+// around binary operators and one statement per line. Comments and blank
+// lines are kept from the trivia the scanner attached to tokens.
+package formatter
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/distolma/golox/cmd/myinterpreter/ast"
+)
+
+const indentation = "  "
+
+type printer struct {
+	tokens []ast.Token
+	trivia []ast.Trivia
+	next   int
+
+	lines  []string
+	line   strings.Builder
+	indent int
+	// blank is set when a blank line is waiting to be written before the
+	// next statement or comment. start is set at the top of a block, where
+	// blank lines are dropped.
+	blank bool
+	start bool
+}
+
+// Format prints statements, which were parsed from tokens, in canonical
+// layout.
+func Format(tokens []ast.Token, statements []ast.Stmt) string {
+	p := &printer{tokens: tokens, start: true}
+	for _, token := range tokens {
+		p.trivia = append(p.trivia, token.Trivia...)
+	}
+
+	for _, stmt := range statements {
+		p.stmt(stmt)
+		p.newline()
+	}
+	p.comments(math.MaxInt)
+
+	if len(p.lines) == 0 {
+		return ""
+	}
+	return strings.Join(p.lines, "\n") + "\n"
+}
+
+func (p *printer) write(text string) {
+	if p.line.Len() == 0 && text != "" {
+		p.line.WriteString(strings.Repeat(indentation, p.indent))
+	}
+	p.line.WriteString(text)
+}
+
+func (p *printer) newline() {
+	p.lines = append(p.lines, p.line.String())
+	p.line.Reset()
+	p.start = false
+}
+
+// separate writes the blank line waiting to be written, if any.
+func (p *printer) separate() {
+	if p.blank && !p.start {
+		p.lines = append(p.lines, "")
+	}
+	p.blank = false
+}
+
+// comments writes the trivia before offset. A comment that trailed a token
+// stays at the end of the last line written.
+func (p *printer) comments(offset int) {
+	for ; p.next < len(p.trivia) && p.trivia[p.next].Offset < offset; p.next++ {
+		trivia := p.trivia[p.next]
+		if trivia.Kind == ast.TriviaBlankLine {
+			p.blank = true
+			continue
+		}
+
+		if last := len(p.lines) - 1; trivia.Trailing && !p.blank && last >= 0 && p.lines[last] != "" {
+			p.lines[last] += " " + trivia.Text
+			continue
+		}
+
+		p.separate()
+		p.write(trivia.Text)
+		p.newline()
+	}
+}
+
+// hasComments reports whether there are comments before offset.
+func (p *printer) hasComments(offset int) bool {
+	for i := p.next; i < len(p.trivia) && p.trivia[i].Offset < offset; i++ {
+		if p.trivia[i].Kind == ast.TriviaComment {
+			return true
+		}
+	}
+	return false
+}
+
+// offset is where stmt starts in the source.
+func (p *printer) offset(stmt ast.Stmt) int {
+	block, ok := stmt.(*ast.Block)
+	if !ok {
+		token, _ := ast.Start(stmt)
+		return token.Offset
+	}
+	if loop, ok := forLoop(block); ok {
+		return loop.Keyword.Offset
+	}
+
+	// A block doesn't keep its opening brace, so look back for it from the
+	// first thing inside.
+	inner := block.Brace.Offset
+	if len(block.Statements) > 0 {
+		inner = p.offset(block.Statements[0])
+	}
+	index := sort.Search(len(p.tokens), func(i int) bool { return p.tokens[i].Offset >= inner })
+	for index--; index >= 0; index-- {
+		if p.tokens[index].Type == ast.TLeftBrace {
+			return p.tokens[index].Offset
+		}
+	}
+	return inner
+}
+
+// forLoop recognizes the block the parser wraps around a 'for' loop with an
+// initializer.
+func forLoop(block *ast.Block) (*ast.While, bool) {
+	if block.Brace.Lexeme != "" || len(block.Statements) != 2 {
+		return nil, false
+	}
+	loop, ok := block.Statements[1].(*ast.While)
+	return loop, ok && loop.Keyword.Type == ast.TFor
+}
+
+// stmt writes a statement starting on a new line and leaves the last line
+// open.
+func (p *printer) stmt(stmt ast.Stmt) {
+	p.comments(p.offset(stmt))
+	p.separate()
+
+	switch stmt := stmt.(type) {
+	case *ast.Block:
+		if loop, ok := forLoop(stmt); ok {
+			p.forStmt(stmt.Statements[0], loop)
+			return
+		}
+		p.block(stmt.Statements, stmt.Brace)
+	case *ast.Break:
+		p.write("break;")
+	case *ast.Class:
+		p.write("class " + stmt.Name.Lexeme)
+		if stmt.Superclass != nil {
+			p.write(" < " + stmt.Superclass.Name.Lexeme)
+		}
+		p.write(" {")
+		if len(stmt.Methods) == 0 && !p.hasComments(stmt.Brace.Offset) {
+			p.write("}")
+			return
+		}
+		p.newline()
+		p.start = true
+		p.indent++
+		for _, method := range stmt.Methods {
+			p.comments(method.Name.Offset)
+			p.separate()
+			p.function(method)
+			p.newline()
+		}
+		p.close(stmt.Brace)
+	case *ast.Continue:
+		p.write("continue;")
+	case *ast.Expression:
+		p.expr(stmt.Expression)
+		p.write(";")
+	case *ast.Function:
+		p.write("fun ")
+		p.function(stmt)
+	case *ast.If:
+		p.ifStmt(stmt)
+	case *ast.Import:
+		if stmt.Alias.Lexeme != "" {
+			p.write("import " + stmt.Path.Lexeme + " as " + stmt.Alias.Lexeme + ";")
+			return
+		}
+		names := make([]string, len(stmt.Names))
+		for i, name := range stmt.Names {
+			names[i] = name.Lexeme
+		}
+		p.write("from " + stmt.Path.Lexeme + " import " + strings.Join(names, ", ") + ";")
+	case *ast.Print:
+		p.write("print ")
+		p.expr(stmt.Expression)
+		p.write(";")
+	case *ast.Return:
+		p.write("return")
+		if stmt.Value != nil {
+			p.write(" ")
+			p.expr(stmt.Value)
+		}
+		p.write(";")
+	case *ast.Throw:
+		p.write("throw ")
+		p.expr(stmt.Value)
+		p.write(";")
+	case *ast.Try:
+		p.write("try ")
+		p.block(stmt.Body.Statements, stmt.Body.Brace)
+		if stmt.Catch != nil {
+			p.write(" catch (" + stmt.Name.Lexeme + ") ")
+			p.block(stmt.Catch.Statements, stmt.Catch.Brace)
+		}
+		if stmt.Finally != nil {
+			p.write(" finally ")
+			p.block(stmt.Finally.Statements, stmt.Finally.Brace)
+		}
+	case *ast.Var:
+		p.write("var " + stmt.Name.Lexeme)
+		if stmt.Initializer != nil {
+			p.write(" = ")
+			p.expr(stmt.Initializer)
+		}
+		p.write(";")
+	case *ast.While:
+		if stmt.Keyword.Type == ast.TFor {
+			p.forStmt(nil, stmt)
+			return
+		}
+		p.write("while (")
+		p.expr(stmt.Condition)
+		p.write(")")
+		p.body(stmt.Body)
+	}
+}
+
+// block writes braces around statements, ending at the closing brace.
+func (p *printer) block(statements []ast.Stmt, brace ast.Token) {
+	p.write("{")
+	if len(statements) == 0 && !p.hasComments(brace.Offset) {
+		p.write("}")
+		return
+	}
+
+	p.newline()
+	p.start = true
+	p.indent++
+	for _, stmt := range statements {
+		p.stmt(stmt)
+		p.newline()
+	}
+	p.close(brace)
+}
+
+// close writes the comments left at the end of a block and its closing
+// brace.
+func (p *printer) close(brace ast.Token) {
+	p.comments(brace.Offset)
+	p.blank = false
+	p.indent--
+	p.write("}")
+}
+
+// body writes the body of a loop or a branch of an if: a block on the same
+// line, anything else indented on the next.
+func (p *printer) body(stmt ast.Stmt) {
+	if block, ok := stmt.(*ast.Block); ok {
+		if _, ok := forLoop(block); !ok {
+			p.write(" ")
+			p.block(block.Statements, block.Brace)
+			return
+		}
+	}
+
+	p.newline()
+	p.indent++
+	p.stmt(stmt)
+	p.indent--
+}
+
+func (p *printer) ifStmt(stmt *ast.If) {
+	p.write("if (")
+	p.expr(stmt.Condition)
+	p.write(")")
+	p.body(stmt.ThenBranch)
+	if stmt.ElseBranch == nil {
+		return
+	}
+
+	if _, ok := stmt.ThenBranch.(*ast.Block); ok {
+		p.write(" else")
+	} else {
+		p.newline()
+		p.write("else")
+	}
+
+	if elseIf, ok := stmt.ElseBranch.(*ast.If); ok {
+		p.write(" ")
+		p.ifStmt(elseIf)
+		return
+	}
+	p.body(stmt.ElseBranch)
+}
+
+// forStmt puts back together a 'for' loop that the parser turned into a
+// while loop.
+func (p *printer) forStmt(initializer ast.Stmt, loop *ast.While) {
+	p.write("for (")
+	switch initializer := initializer.(type) {
+	case *ast.Var:
+		p.write("var " + initializer.Name.Lexeme)
+		if initializer.Initializer != nil {
+			p.write(" = ")
+			p.expr(initializer.Initializer)
+		}
+	case *ast.Expression:
+		p.expr(initializer.Expression)
+	}
+	p.write(";")
+
+	// A missing condition was filled in with a 'true' made from the 'for'
+	// keyword.
+	if literal, ok := loop.Condition.(*ast.Literal); !ok || literal.Token.Type != ast.TFor {
+		p.write(" ")
+		p.expr(loop.Condition)
+	}
+	p.write(";")
+
+	if loop.Increment != nil {
+		p.write(" ")
+		p.expr(loop.Increment)
+	}
+	p.write(")")
+	p.body(loop.Body)
+}
+
+func (p *printer) function(function *ast.Function) {
+	p.write(function.Name.Lexeme)
+	p.params(function.Params)
+	p.write(" ")
+	p.block(function.Body, function.Brace)
+}
+
+func (p *printer) params(params []ast.Token) {
+	names := make([]string, len(params))
+	for i, param := range params {
+		names[i] = param.Lexeme
+	}
+	p.write("(" + strings.Join(names, ", ") + ")")
+}
+
+func (p *printer) exprs(exprs []ast.Expr) {
+	for i, expr := range exprs {
+		if i > 0 {
+			p.write(", ")
+		}
+		p.expr(expr)
+	}
+}
+
+func (p *printer) expr(expr ast.Expr) {
+	switch expr := expr.(type) {
+	case *ast.Assign:
+		p.write(expr.Name.Lexeme + " = ")
+		p.expr(expr.Value)
+	case *ast.Binary:
+		p.expr(expr.Left)
+		p.write(" " + expr.Operator.Lexeme + " ")
+		p.expr(expr.Right)
+	case *ast.Call:
+		p.expr(expr.Callee)
+		p.write("(")
+		p.exprs(expr.Arguments)
+		p.write(")")
+	case *ast.Get:
+		p.expr(expr.Object)
+		p.write("." + expr.Name.Lexeme)
+	case *ast.Grouping:
+		p.write("(")
+		p.expr(expr.Expression)
+		p.write(")")
+	case *ast.Index:
+		p.expr(expr.Object)
+		p.write("[")
+		p.expr(expr.Index)
+		p.write("]")
+	case *ast.Lambda:
+		p.lambda(expr)
+	case *ast.List:
+		p.write("[")
+		p.exprs(expr.Elements)
+		p.write("]")
+	case *ast.Literal:
+		p.write(expr.Token.Lexeme)
+	case *ast.Logical:
+		p.expr(expr.Left)
+		p.write(" " + expr.Operator.Lexeme + " ")
+		p.expr(expr.Right)
+	case *ast.Map:
+		p.write("{")
+		for i := range expr.Keys {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.expr(expr.Keys[i])
+			p.write(": ")
+			p.expr(expr.Values[i])
+		}
+		p.write("}")
+	case *ast.Set:
+		p.expr(expr.Object)
+		p.write("." + expr.Name.Lexeme + " = ")
+		p.expr(expr.Value)
+	case *ast.SetIndex:
+		p.expr(expr.Object)
+		p.write("[")
+		p.expr(expr.Index)
+		p.write("] = ")
+		p.expr(expr.Value)
+	case *ast.Super:
+		p.write("super." + expr.Method.Lexeme)
+	case *ast.This:
+		p.write("this")
+	case *ast.Unary:
+		p.write(expr.Operator.Lexeme)
+		p.expr(expr.Right)
+	case *ast.Variable:
+		p.write(expr.Name.Lexeme)
+	}
+}
+
+func (p *printer) lambda(lambda *ast.Lambda) {
+	function := lambda.Function
+	if lambda.Keyword.Type != ast.TArrow {
+		p.write("fun ")
+		p.params(function.Params)
+		p.write(" ")
+		p.block(function.Body, function.Brace)
+		return
+	}
+
+	p.params(function.Params)
+	p.write(" => ")
+	// An arrow function without braces returns its expression.
+	if function.Brace.Lexeme == "" {
+		p.expr(function.Body[0].(*ast.Return).Value)
+		return
+	}
+	p.block(function.Body, function.Brace)
+}
@@ -0,0 +1,95 @@
+package formatter_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/distolma/golox/cmd/myinterpreter/ast"
+	"github.com/distolma/golox/cmd/myinterpreter/formatter"
+	logerror "github.com/distolma/golox/cmd/myinterpreter/log_error"
+	"github.com/distolma/golox/cmd/myinterpreter/parser"
+	"github.com/distolma/golox/cmd/myinterpreter/scanner"
+)
+
+var sources = map[string]string{
+	"expressions": `var a=1+2*3;print -a;print !(a>=2 and a!=3)or nil;a=a/2-1;`,
+	"control flow": `// Counts down.
+for(var i=10;i>0;i=i-1){if(i==5)continue;else if(i<2)break;print i;}
+
+
+while(false){}   // never runs
+`,
+	"functions": `fun add(a,b){return a+b;}
+var sq=(x)=>x*x;var f=fun(x){
+  // doubled
+  return x*2;};
+print add(sq(2),f(3));`,
+	"classes": `class A{init(x){this.x=x;}
+get(){return this.x;}}
+class B<A{get(){return super.get()+1;}}
+print B(1).get();`,
+	"collections": `var xs=[1,2,[3,4],];var m={"a":1,2:"b"};xs[0]=m["a"];print xs[-1][0];`,
+	"try and import": `import "lib/math.lox" as math;from "lib/math.lox" import square,bump;
+try{throw "x";}catch(e){print e.message;}finally{print "done";}`,
+	"comments": `// leading
+var a = 1; // trailing
+
+// between
+
+print a;
+// at the end
+`,
+}
+
+// format returns source in canonical layout, along with its syntax tree.
+func format(t *testing.T, source string) (string, string) {
+	t.Helper()
+
+	log := &logerror.LogError{Output: io.Discard}
+	tokens := scanner.NewScanner(source, log).ScanTokens()
+	statements := parser.NewParser(tokens, log).Parse()
+	if log.HadError {
+		t.Fatalf("parsing %q failed: %v", source, log.Reports)
+	}
+	printer := ast.AstPrinter{}
+	return formatter.Format(tokens, statements), printer.Print(statements)
+}
+
+// TestFormatIsIdempotent checks that formatted code is left alone by a second
+// pass and still has the same syntax tree and comments as the original.
+func TestFormatIsIdempotent(t *testing.T) {
+	for name, source := range sources {
+		t.Run(name, func(t *testing.T) {
+			once, original := format(t, source)
+			twice, formatted := format(t, once)
+
+			if twice != once {
+				t.Errorf("formatting again changed the code\n--- once\n%s\n--- twice\n%s", once, twice)
+			}
+			if formatted != original {
+				t.Errorf("formatting changed the syntax tree\n--- source\n%s\n--- formatted\n%s", source, once)
+			}
+			if strings.Count(once, "//") != strings.Count(source, "//") {
+				t.Errorf("formatting lost comments\n--- source\n%s\n--- formatted\n%s", source, once)
+			}
+		})
+	}
+}
+
+func TestFormatLayout(t *testing.T) {
+	got, _ := format(t, `class A<B{m(x){if(x)print x;else{return;}}}`)
+	want := `class A < B {
+  m(x) {
+    if (x)
+      print x;
+    else {
+      return;
+    }
+  }
+}
+`
+	if got != want {
+		t.Errorf("Format() =\n%s\nwant\n%s", got, want)
+	}
+}
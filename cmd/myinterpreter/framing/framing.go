@@ -0,0 +1,50 @@
+// Package framing reads and writes the messages of the Language Server and
+// Debug Adapter protocols, which both send each body after a Content-Length
+// header.
+package framing
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+)
+
+type Reader struct {
+	in *bufio.Reader
+}
+
+func NewReader(in io.Reader) *Reader {
+	return &Reader{in: bufio.NewReader(in)}
+}
+
+// Read returns the body of the next message. It returns io.EOF once the
+// input ends, even in the middle of a header.
+func (r *Reader) Read() ([]byte, error) {
+	header, err := textproto.NewReader(r.in).ReadMIMEHeader()
+	if err != nil {
+		if err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length: %q", header.Get("Content-Length"))
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r.in, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// Write sends body to out as a single message.
+func Write(out io.Writer, body []byte) error {
+	_, err := fmt.Fprintf(out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return err
+}
@@ -0,0 +1,62 @@
+package framing
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReadWrite(t *testing.T) {
+	var stream bytes.Buffer
+	messages := []string{`{"id":1}`, "", `{"text":"héllo\r\n"}`}
+	for _, message := range messages {
+		if err := Write(&stream, []byte(message)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reader := NewReader(&stream)
+	for _, want := range messages {
+		body, err := reader.Read()
+		if err != nil {
+			t.Fatalf("Read() = %v, want %q", err, want)
+		}
+		if string(body) != want {
+			t.Errorf("Read() = %q, want %q", body, want)
+		}
+	}
+	if _, err := reader.Read(); err != io.EOF {
+		t.Errorf("Read() at the end = %v, want %v", err, io.EOF)
+	}
+}
+
+func TestReadHeaders(t *testing.T) {
+	input := "Content-Type: application/vscode-jsonrpc; charset=utf-8\r\ncontent-length: 2\r\n\r\n{}"
+	body, err := NewReader(strings.NewReader(input)).Read()
+	if err != nil || string(body) != "{}" {
+		t.Errorf("Read() = %q, %v, want %q", body, err, "{}")
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	tests := map[string]struct {
+		input string
+		eof   bool
+	}{
+		"end inside a header":     {"Content-Length: 2\r\n", true},
+		"missing Content-Length":  {"Content-Type: x\r\n\r\n{}", false},
+		"invalid Content-Length":  {"Content-Length: two\r\n\r\n{}", false},
+		"negative Content-Length": {"Content-Length: -1\r\n\r\n{}", false},
+		"short body":              {"Content-Length: 10\r\n\r\n{}", false},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewReader(strings.NewReader(test.input)).Read()
+			if err == nil || (err == io.EOF) != test.eof {
+				t.Errorf("Read() = %v, want an error, io.EOF: %v", err, test.eof)
+			}
+		})
+	}
+}
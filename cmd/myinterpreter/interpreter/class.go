@@ -0,0 +1,50 @@
+package interpreter
+
+import (
+	"github.com/distolma/golox/cmd/myinterpreter/ast"
+	"github.com/distolma/golox/cmd/myinterpreter/limits"
+)
+
+type Class struct {
+	name       string
+	superclass *Class
+	methods    map[string]*Function
+}
+
+func NewClass(name string, superclass *Class, methods map[string]*Function) *Class {
+	return &Class{name: name, superclass: superclass, methods: methods}
+}
+
+func (c *Class) findMethod(name string) *Function {
+	if method, ok := c.methods[name]; ok {
+		return method
+	}
+
+	if c.superclass != nil {
+		return c.superclass.findMethod(name)
+	}
+
+	return nil
+}
+
+func (c *Class) arity() int {
+	if initializer := c.findMethod("init"); initializer != nil {
+		return initializer.arity()
+	}
+	return 0
+}
+
+func (c *Class) call(interpreter *Interpreter, paren ast.Token, arguments []interface{}) interface{} {
+	interpreter.allocate(paren, limits.SizeInstance)
+	instance := NewInstance(c)
+
+	if initializer := c.findMethod("init"); initializer != nil {
+		initializer.bind(instance).call(interpreter, paren, arguments)
+	}
+
+	return instance
+}
+
+func (c *Class) String() string {
+	return c.name
+}
@@ -0,0 +1,75 @@
+package interpreter
+
+import (
+	"github.com/distolma/golox/cmd/myinterpreter/ast"
+	"github.com/distolma/golox/cmd/myinterpreter/environment"
+)
+
+// Hook observes a run statement by statement, as a debugger does.
+type Hook interface {
+	// Step is called before each statement runs. frames is the call stack,
+	// innermost last, and is only valid until Step returns.
+	Step(stmt ast.Stmt, frames []Frame)
+}
+
+// Frame is a call in progress: the script itself, a module being imported or
+// a function.
+type Frame struct {
+	Name string
+	// Statement is the statement being run and Environment is the scope it
+	// runs in.
+	Statement   ast.Stmt
+	Environment *environment.Environment
+}
+
+// SetHook installs h to be called before every statement, or removes the hook
+// if h is nil.
+func (i *Interpreter) SetHook(h Hook) {
+	i.hook = h
+	i.frames = nil
+}
+
+// EvaluateIn evaluates expr as if it appeared where env is in scope. The hook
+// isn't called while it runs.
+func (i *Interpreter) EvaluateIn(expr ast.Expr, env *environment.Environment) (value interface{}, err error) {
+	previous, hook := i.environment, i.hook
+	defer func() {
+		i.environment, i.hook = previous, hook
+		if r := recover(); r != nil {
+			runtimeError, ok := r.(RuntimeError)
+			if !ok {
+				panic(r)
+			}
+			err = &runtimeError
+		}
+	}()
+
+	i.environment, i.hook = env, nil
+	return i.evaluate(expr), nil
+}
+
+// enterFrame pushes a frame named name while a hook is installed and returns
+// a function that pops it again.
+func (i *Interpreter) enterFrame(name string) func() {
+	if i.hook == nil {
+		return func() {}
+	}
+
+	i.frames = append(i.frames, Frame{Name: name, Environment: i.environment})
+	return func() {
+		i.frames = i.frames[:len(i.frames)-1]
+	}
+}
+
+// trace records stmt as the current statement of the innermost frame and
+// hands control to the hook.
+func (i *Interpreter) trace(stmt ast.Stmt) {
+	if len(i.frames) == 0 {
+		return
+	}
+
+	frame := &i.frames[len(i.frames)-1]
+	frame.Statement = stmt
+	frame.Environment = i.environment
+	i.hook.Step(stmt, i.frames)
+}
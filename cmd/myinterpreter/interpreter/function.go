@@ -9,26 +9,38 @@ import (
 
 type Callable interface {
 	arity() int
-	call(interpreter *Interpreter, arguments []interface{}) interface{}
+	call(interpreter *Interpreter, paren ast.Token, arguments []interface{}) interface{}
 }
 
 type Function struct {
-	declaraton ast.Function
-	closure    *environment.Environment
+	declaraton    ast.Function
+	closure       *environment.Environment
+	module        *Module
+	isInitializer bool
 }
 
-func NewFunction(declaraton ast.Function, env *environment.Environment) *Function {
-	return &Function{declaraton: declaraton, closure: env}
+func NewFunction(declaraton ast.Function, env *environment.Environment, module *Module, isInitializer bool) *Function {
+	return &Function{declaraton: declaraton, closure: env, module: module, isInitializer: isInitializer}
+}
+
+func (f *Function) bind(instance *Instance) *Function {
+	env := environment.NewEnvironment(f.closure)
+	env.Define("this", instance)
+	return NewFunction(f.declaraton, env, f.module, f.isInitializer)
 }
 
 func (f *Function) arity() int {
 	return len(f.declaraton.Params)
 }
 
-func (f *Function) call(interpreter *Interpreter, arguments []interface{}) (returnValue interface{}) {
+func (f *Function) call(interpreter *Interpreter, paren ast.Token, arguments []interface{}) (returnValue interface{}) {
 	defer func() {
 		if err := recover(); err != nil {
 			if v, ok := err.(Return); ok {
+				if f.isInitializer {
+					returnValue = f.closure.GetAt(0, "this")
+					return
+				}
 				returnValue = v.Value
 				return
 			}
@@ -36,15 +48,30 @@ func (f *Function) call(interpreter *Interpreter, arguments []interface{}) (retu
 		}
 	}()
 
+	// Globals are looked up in the module that declared the function.
+	defer interpreter.enterModule(f.module)()
+	defer interpreter.enterFrame(f.name())()
+
 	callEnv := environment.NewEnvironment(f.closure)
 	for i, param := range f.declaraton.Params {
 		callEnv.Define(param.Lexeme, arguments[i])
 	}
 
 	interpreter.executeBlock(f.declaraton.Body, callEnv)
+
+	if f.isInitializer {
+		return f.closure.GetAt(0, "this")
+	}
 	return nil
 }
 
+func (f *Function) name() string {
+	if f.declaraton.Name.Lexeme == "" {
+		return "anonymous"
+	}
+	return f.declaraton.Name.Lexeme
+}
+
 func (f *Function) String() string {
-	return fmt.Sprintf("<fn %s>", f.declaraton.Name.Lexeme)
+	return fmt.Sprintf("<fn %s>", f.name())
 }
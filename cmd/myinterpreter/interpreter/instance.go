@@ -0,0 +1,85 @@
+package interpreter
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/distolma/golox/cmd/myinterpreter/ast"
+)
+
+type Instance struct {
+	class  *Class
+	fields map[string]interface{}
+	// origin is where an error object was raised, so that throwing it again
+	// reports the same position.
+	origin ast.Token
+}
+
+func NewInstance(class *Class) *Instance {
+	return &Instance{class: class, fields: make(map[string]interface{})}
+}
+
+func (i *Instance) Get(name ast.Token) interface{} {
+	if value, ok := i.fields[name.Lexeme]; ok {
+		return value
+	}
+
+	if method := i.class.findMethod(name.Lexeme); method != nil {
+		return method.bind(i)
+	}
+
+	panic(NewRuntimeError(name, fmt.Sprintf("Undefined property '%s'.", name.Lexeme)))
+}
+
+func (i *Instance) Set(name ast.Token, value interface{}) {
+	i.fields[name.Lexeme] = value
+}
+
+func (i *Instance) String() string {
+	return i.class.name + " instance"
+}
+
+// Property looks up a field or bound method by name without raising an
+// error when it is missing.
+func (i *Instance) Property(name string) (interface{}, bool) {
+	if value, ok := i.fields[name]; ok {
+		return value, true
+	}
+
+	if method := i.class.findMethod(name); method != nil {
+		return method.bind(i), true
+	}
+
+	return nil, false
+}
+
+// FieldNames lists the fields set on the instance in sorted order.
+func (i *Instance) FieldNames() []string {
+	names := make([]string, 0, len(i.fields))
+	for name := range i.fields {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// PropertyNames lists the fields of the instance and the methods of its
+// class, including inherited ones, in sorted order.
+func (i *Instance) PropertyNames() []string {
+	names := i.FieldNames()
+	for class := i.class; class != nil; class = class.superclass {
+		for name := range class.methods {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+func (i *Instance) SetField(name string, value interface{}) {
+	i.fields[name] = value
+}
+
+func (i *Instance) ClassName() string {
+	return i.class.name
+}
@@ -1,59 +1,131 @@
 package interpreter
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/distolma/golox/cmd/myinterpreter/ast"
+	"github.com/distolma/golox/cmd/myinterpreter/collection"
 	"github.com/distolma/golox/cmd/myinterpreter/environment"
+	"github.com/distolma/golox/cmd/myinterpreter/limits"
 	logerror "github.com/distolma/golox/cmd/myinterpreter/log_error"
+	"github.com/distolma/golox/cmd/myinterpreter/module"
 )
 
 type Interpreter struct {
 	log         *logerror.LogError
 	environment *environment.Environment
 	globals     *environment.Environment
+	builtins    *environment.Environment
 	locals      map[ast.Expr]int
+	stdout      io.Writer
+	meter       *limits.Meter
+	ctx         context.Context
+	module      *Module
+	modules     *module.Registry
+	loader      ModuleLoader
+	searchPath  []string
+	hook        Hook
+	frames      []Frame
 }
 
 func NewInterpreter(log *logerror.LogError) *Interpreter {
-	globals := environment.NewEnvironment(nil)
-	globals.Define("clock", Clock{})
-
-	return &Interpreter{
+	// Natives live in an environment enclosing the globals of every module.
+	builtins := environment.NewEnvironment(nil)
+	main := &Module{name: "main", globals: environment.NewEnvironment(builtins)}
+	interpreter := &Interpreter{
 		log:         log,
-		environment: globals,
-		globals:     globals,
+		environment: main.globals,
+		globals:     main.globals,
+		builtins:    builtins,
 		locals:      make(map[ast.Expr]int),
+		stdout:      os.Stdout,
+		meter:       limits.NewMeter(limits.Limits{}),
+		ctx:         context.Background(),
+		module:      main,
+		modules:     module.NewRegistry(),
+	}
+	interpreter.DefineNative("clock", 0, clock)
+	for _, native := range collection.Natives {
+		function := native.Function
+		interpreter.DefineNative(native.Name, native.Arity, func(arguments []interface{}) (interface{}, error) {
+			return function(interpreter.meter, arguments)
+		})
 	}
+
+	return interpreter
+}
+
+// SetStdout redirects the output of print statements.
+func (i *Interpreter) SetStdout(w io.Writer) {
+	i.stdout = w
 }
 
-func (i *Interpreter) Interpret(statements []ast.Stmt) {
+// SetLimits bounds the resources used by each subsequent run.
+func (i *Interpreter) SetLimits(l limits.Limits) {
+	i.meter = limits.NewMeter(l)
+}
+
+// Interpret runs statements until they finish or ctx is done.
+func (i *Interpreter) Interpret(ctx context.Context, statements []ast.Stmt) {
+	defer i.setContext(ctx)()
 	defer func() {
 		if err := recover(); err != nil {
 			if runtimeError, ok := err.(RuntimeError); ok {
-				i.log.RuntimeError(runtimeError.Token, runtimeError.Message)
+				i.log.RuntimeErrorWithCode(runtimeError.Token, runtimeError.Code, runtimeError.Message)
 			} else {
 				panic(err)
 			}
 		}
 	}()
 
+	defer i.enterFrame("<script>")()
+	i.meter.Reset()
 	for _, statement := range statements {
 		i.execute(statement)
 	}
 }
 
+// Eval executes statements like Interpret and returns the value of the last
+// statement if it is an expression statement.
+func (i *Interpreter) Eval(ctx context.Context, statements []ast.Stmt) (result interface{}) {
+	defer i.setContext(ctx)()
+	defer func() {
+		if err := recover(); err != nil {
+			if runtimeError, ok := err.(RuntimeError); ok {
+				i.log.RuntimeErrorWithCode(runtimeError.Token, runtimeError.Code, runtimeError.Message)
+				result = nil
+			} else {
+				panic(err)
+			}
+		}
+	}()
+
+	i.meter.Reset()
+	for index, statement := range statements {
+		if expression, ok := statement.(*ast.Expression); ok && index == len(statements)-1 {
+			return i.evaluate(expression.Expression)
+		}
+		i.execute(statement)
+	}
+
+	return nil
+}
+
 func (i *Interpreter) InterpretExpression(expr ast.Expr) string {
 	defer func() {
 		if err := recover(); err != nil {
 			if runtimeError, ok := err.(RuntimeError); ok {
-				i.log.RuntimeError(runtimeError.Token, runtimeError.Message)
+				i.log.RuntimeErrorWithCode(runtimeError.Token, runtimeError.Code, runtimeError.Message)
 			} else {
 				panic(err)
 			}
 		}
 	}()
 
+	i.meter.Reset()
 	return i.stringify(i.evaluate(expr))
 }
 
@@ -77,6 +149,11 @@ func (i *Interpreter) VisitLogicalExpr(expr *ast.Logical) interface{} {
 	return i.evaluate(expr.Right)
 }
 
+func (i *Interpreter) VisitLambdaExpr(expr *ast.Lambda) interface{} {
+	i.allocate(expr.Keyword, limits.SizeFunction)
+	return NewFunction(*expr.Function, i.environment, i.module, false)
+}
+
 func (i *Interpreter) VisitGroupingExpr(expr *ast.Grouping) interface{} {
 	return i.evaluate(expr.Expression)
 }
@@ -139,6 +216,7 @@ func (i *Interpreter) VisitBinaryExpr(expr *ast.Binary) interface{} {
 		leftString, leftOk := left.(string)
 		rightString, rightOk := right.(string)
 		if leftOk && rightOk {
+			i.allocate(expr.Operator, len(leftString)+len(rightString))
 			return leftString + rightString
 		}
 
@@ -165,6 +243,103 @@ func (i *Interpreter) VisitBinaryExpr(expr *ast.Binary) interface{} {
 	return nil
 }
 
+func (i *Interpreter) VisitListExpr(expr *ast.List) interface{} {
+	elements := make([]interface{}, len(expr.Elements))
+	for index, element := range expr.Elements {
+		elements[index] = i.evaluate(element)
+	}
+
+	i.allocate(expr.Bracket, limits.SizeList+len(elements)*limits.SizeElement)
+	return collection.NewList(elements)
+}
+
+func (i *Interpreter) VisitMapExpr(expr *ast.Map) interface{} {
+	result := collection.NewMap()
+	for index, key := range expr.Keys {
+		key := i.evaluate(key)
+		value := i.evaluate(expr.Values[index])
+		if err := result.Set(key, value); err != nil {
+			panic(NewRuntimeError(expr.Brace, err.Error()))
+		}
+	}
+
+	i.allocate(expr.Brace, limits.SizeMap+result.Len()*limits.SizeEntry)
+	return result
+}
+
+func (i *Interpreter) VisitIndexExpr(expr *ast.Index) interface{} {
+	object := i.evaluate(expr.Object)
+	index := i.evaluate(expr.Index)
+
+	value, err := collection.Index(object, index)
+	if err != nil {
+		panic(NewRuntimeError(expr.Bracket, err.Error()))
+	}
+	return value
+}
+
+func (i *Interpreter) VisitSetIndexExpr(expr *ast.SetIndex) interface{} {
+	object := i.evaluate(expr.Object)
+	index := i.evaluate(expr.Index)
+	value := i.evaluate(expr.Value)
+
+	if err := collection.SetIndex(i.meter, object, index, value); err != nil {
+		panic(NewNativeError(expr.Bracket, err))
+	}
+	return value
+}
+
+func (i *Interpreter) VisitGetExpr(expr *ast.Get) interface{} {
+	object := i.evaluate(expr.Object)
+	if instance, ok := object.(*Instance); ok {
+		return instance.Get(expr.Name)
+	}
+	if module, ok := object.(*Module); ok {
+		return module.Get(expr.Name)
+	}
+
+	panic(NewRuntimeError(expr.Name, "Only instances have properties."))
+}
+
+func (i *Interpreter) VisitSetExpr(expr *ast.Set) interface{} {
+	object := i.evaluate(expr.Object)
+
+	instance, ok := object.(*Instance)
+	if !ok {
+		panic(NewRuntimeError(expr.Name, "Only instances have fields."))
+	}
+
+	value := i.evaluate(expr.Value)
+	if _, ok := instance.fields[expr.Name.Lexeme]; !ok {
+		i.allocate(expr.Name, limits.SizeField)
+	}
+	instance.Set(expr.Name, value)
+	return value
+}
+
+func (i *Interpreter) VisitSuperExpr(expr *ast.Super) interface{} {
+	distance := i.locals[expr]
+	superclass := i.environment.GetAt(distance, "super").(*Class)
+
+	// "this" is always one level nearer than "super"'s environment.
+	object := i.environment.GetAt(distance-1, "this").(*Instance)
+
+	method := superclass.findMethod(expr.Method.Lexeme)
+	if method == nil {
+		panic(NewRuntimeError(expr.Method, fmt.Sprintf("Undefined property '%s'.", expr.Method.Lexeme)))
+	}
+
+	return method.bind(object)
+}
+
+func (i *Interpreter) VisitThisExpr(expr *ast.This) interface{} {
+	value, err := i.lookUpVariable(expr.Keyword, expr)
+	if err != nil {
+		panic(NewRuntimeError(expr.Keyword, err.Error()))
+	}
+	return value
+}
+
 func (i *Interpreter) VisitCallExpr(expr *ast.Call) interface{} {
 	callee := i.evaluate(expr.Callee)
 
@@ -178,11 +353,17 @@ func (i *Interpreter) VisitCallExpr(expr *ast.Call) interface{} {
 		panic(NewRuntimeError(expr.Paren, "Can only call functions and classes."))
 	}
 
-	if len(arguments) != function.arity() {
-		panic(NewRuntimeError(expr.Paren, fmt.Sprintf("Expected %d arguments but got %d.", function.arity(), len(arguments))))
+	if arity := function.arity(); arity != VariadicArity && len(arguments) != arity {
+		panic(NewRuntimeError(expr.Paren, fmt.Sprintf("Expected %d arguments but got %d.", arity, len(arguments))))
+	}
+
+	if violation := i.meter.Enter(); violation != nil {
+		panic(NewLimitError(expr.Paren, violation))
 	}
+	defer i.meter.Exit()
 
-	return function.call(i, arguments)
+	i.allocate(expr.Paren, limits.SizeEnvironment)
+	return function.call(i, expr.Paren, arguments)
 }
 
 func (i *Interpreter) evaluate(expr ast.Expr) interface{} {
@@ -190,6 +371,17 @@ func (i *Interpreter) evaluate(expr ast.Expr) interface{} {
 }
 
 func (i *Interpreter) execute(stmt ast.Stmt) {
+	// Blocks have no position of their own, so limits are reported at the
+	// opening brace.
+	token, _ := ast.Start(stmt)
+	if block, ok := stmt.(*ast.Block); ok {
+		token = block.Brace
+	}
+	i.step(token)
+
+	if i.hook != nil {
+		i.trace(stmt)
+	}
 	stmt.Accept(i)
 }
 
@@ -197,6 +389,19 @@ func (i *Interpreter) Resolve(expr ast.Expr, depth int) {
 	i.locals[expr] = depth
 }
 
+// Unresolve forgets the depth recorded for expr, leaving it to be looked
+// up as a global.
+func (i *Interpreter) Unresolve(expr ast.Expr) {
+	delete(i.locals, expr)
+}
+
+// Depth returns the number of scopes between expr and the variable it
+// refers to, or false if the resolver left it to be looked up as a global.
+func (i *Interpreter) Depth(expr ast.Expr) (int, bool) {
+	depth, ok := i.locals[expr]
+	return depth, ok
+}
+
 func (i *Interpreter) executeBlock(statements []ast.Stmt, environment *environment.Environment) {
 	previous := i.environment
 
@@ -215,13 +420,49 @@ func (i *Interpreter) VisitBlockStmt(stmt *ast.Block) interface{} {
 	return nil
 }
 
+func (i *Interpreter) VisitClassStmt(stmt *ast.Class) interface{} {
+	var superclass *Class
+	if stmt.Superclass != nil {
+		var ok bool
+		superclass, ok = i.evaluate(stmt.Superclass).(*Class)
+		if !ok {
+			panic(NewRuntimeError(stmt.Superclass.Name, "Superclass must be a class."))
+		}
+	}
+
+	i.allocate(stmt.Name, limits.SizeClass+len(stmt.Methods)*limits.SizeFunction)
+	i.environment.Define(stmt.Name.Lexeme, nil)
+
+	if superclass != nil {
+		i.environment = environment.NewEnvironment(i.environment)
+		i.environment.Define("super", superclass)
+	}
+
+	methods := make(map[string]*Function)
+	for _, method := range stmt.Methods {
+		methods[method.Name.Lexeme] = NewFunction(*method, i.environment, i.module, method.Name.Lexeme == "init")
+	}
+
+	class := NewClass(stmt.Name.Lexeme, superclass, methods)
+
+	if superclass != nil {
+		i.environment = i.environment.Enclosing
+	}
+
+	if err := i.environment.Assign(stmt.Name.Lexeme, class); err != nil {
+		panic(NewRuntimeError(stmt.Name, err.Error()))
+	}
+	return nil
+}
+
 func (i *Interpreter) VisitExpressionStmt(stmt *ast.Expression) interface{} {
 	i.evaluate(stmt.Expression)
 	return nil
 }
 
 func (i *Interpreter) VisitFunctionStmt(stmt *ast.Function) interface{} {
-	function := NewFunction(*stmt, i.environment)
+	i.allocate(stmt.Name, limits.SizeFunction)
+	function := NewFunction(*stmt, i.environment, i.module, false)
 	i.environment.Define(stmt.Name.Lexeme, function)
 	return nil
 }
@@ -237,7 +478,7 @@ func (i *Interpreter) VisitIfStmt(stmt *ast.If) interface{} {
 
 func (i *Interpreter) VisitPrintStmt(stmt *ast.Print) interface{} {
 	value := i.evaluate(stmt.Expression)
-	fmt.Println(i.stringify(value))
+	fmt.Fprintln(i.stdout, i.stringify(value))
 	return nil
 }
 
@@ -261,11 +502,80 @@ func (i *Interpreter) VisitVarStmt(stmt *ast.Var) interface{} {
 
 func (i *Interpreter) VisitWhileStmt(stmt *ast.While) interface{} {
 	for i.isTruthy(i.evaluate(stmt.Condition)) {
-		i.execute(stmt.Body)
+		if i.executeLoopBody(stmt.Body) {
+			break
+		}
+		if stmt.Increment != nil {
+			i.evaluate(stmt.Increment)
+		}
+	}
+	return nil
+}
+
+// executeLoopBody runs one iteration of a loop and reports whether it ended
+// with a break statement.
+func (i *Interpreter) executeLoopBody(body ast.Stmt) (broke bool) {
+	defer func() {
+		if err := recover(); err != nil {
+			switch err.(type) {
+			case Break:
+				broke = true
+			case Continue:
+			default:
+				panic(err)
+			}
+		}
+	}()
+
+	i.execute(body)
+	return false
+}
+
+func (i *Interpreter) VisitThrowStmt(stmt *ast.Throw) interface{} {
+	value := i.evaluate(stmt.Value)
+	panic(NewThrowError(stmt.Keyword, value, i.stringify(value)))
+}
+
+func (i *Interpreter) VisitTryStmt(stmt *ast.Try) interface{} {
+	if stmt.Finally != nil {
+		// Deferred so that it also runs while a return, break, continue or
+		// uncaught error unwinds through the statement.
+		defer i.execute(stmt.Finally)
 	}
+
+	i.executeTry(stmt)
 	return nil
 }
 
+func (i *Interpreter) executeTry(stmt *ast.Try) {
+	defer func() {
+		if stmt.Catch == nil {
+			return
+		}
+
+		if err := recover(); err != nil {
+			runtimeError, ok := err.(RuntimeError)
+			if !ok || runtimeError.Code != "" {
+				panic(err)
+			}
+
+			environment := environment.NewEnvironment(i.environment)
+			environment.Define(stmt.Name.Lexeme, newErrorObject(runtimeError))
+			i.executeBlock(stmt.Catch.Statements, environment)
+		}
+	}()
+
+	i.execute(stmt.Body)
+}
+
+func (i *Interpreter) VisitBreakStmt(stmt *ast.Break) interface{} {
+	panic(Break{})
+}
+
+func (i *Interpreter) VisitContinueStmt(stmt *ast.Continue) interface{} {
+	panic(Continue{})
+}
+
 func (i *Interpreter) VisitAssignExpr(expr *ast.Assign) interface{} {
 	value := i.evaluate(expr.Value)
 
@@ -279,6 +589,34 @@ func (i *Interpreter) VisitAssignExpr(expr *ast.Assign) interface{} {
 	return value
 }
 
+// step counts a statement and checks whether the run must stop.
+func (i *Interpreter) step(token ast.Token) {
+	if violation := i.meter.Step(); violation != nil {
+		panic(NewLimitError(token, violation))
+	}
+
+	select {
+	case <-i.ctx.Done():
+		panic(NewLimitError(token, limits.Interrupted(i.ctx.Err())))
+	default:
+	}
+}
+
+// setContext makes ctx the context of the current run and returns a function
+// that clears it again, so calls made from Go afterwards are not cancelled.
+func (i *Interpreter) setContext(ctx context.Context) func() {
+	i.ctx = ctx
+	return func() {
+		i.ctx = context.Background()
+	}
+}
+
+func (i *Interpreter) allocate(token ast.Token, bytes int) {
+	if violation := i.meter.Allocate(bytes); violation != nil {
+		panic(NewLimitError(token, violation))
+	}
+}
+
 func (i *Interpreter) isTruthy(object interface{}) bool {
 	if object == nil {
 		return false
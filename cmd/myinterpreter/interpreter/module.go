@@ -0,0 +1,148 @@
+package interpreter
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/distolma/golox/cmd/myinterpreter/ast"
+	"github.com/distolma/golox/cmd/myinterpreter/environment"
+	"github.com/distolma/golox/cmd/myinterpreter/module"
+)
+
+// ModuleLoader scans, parses and resolves the module at path, reporting any
+// errors to the log. It returns false if the module has errors.
+type ModuleLoader func(path string) ([]ast.Stmt, bool)
+
+// Module is the namespace of a file. Its top-level names are read as
+// properties once it is imported.
+type Module struct {
+	name    string
+	path    string
+	globals *environment.Environment
+}
+
+func NewModule(path string, builtins *environment.Environment) *Module {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return &Module{name: name, path: path, globals: environment.NewEnvironment(builtins)}
+}
+
+func (m *Module) Get(name ast.Token) interface{} {
+	if value, ok := m.globals.Lookup(name.Lexeme); ok {
+		return value
+	}
+	panic(NewRuntimeError(name, fmt.Sprintf("Module '%s' has no export '%s'.", m.name, name.Lexeme)))
+}
+
+// Lookup finds a top-level name of the module without raising an error
+// when it is missing.
+func (m *Module) Lookup(name string) (interface{}, bool) {
+	return m.globals.Lookup(name)
+}
+
+// Names lists the top-level names of the module in sorted order.
+func (m *Module) Names() []string {
+	return m.globals.Names()
+}
+
+func (m *Module) String() string {
+	return "<module " + m.name + ">"
+}
+
+// SetScriptPath names the file being run, against which its imports are
+// resolved.
+func (i *Interpreter) SetScriptPath(path string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	i.module.path = path
+	return i.modules.Enter(path)
+}
+
+// SetSearchPath sets the directories searched for imports that aren't found
+// next to the importing file.
+func (i *Interpreter) SetSearchPath(directories []string) {
+	i.searchPath = directories
+}
+
+// Globals returns the environment holding the top-level names of the main
+// script. Natives live in the environment enclosing it.
+func (i *Interpreter) Globals() *environment.Environment {
+	return i.globals
+}
+
+func (i *Interpreter) SetModuleLoader(loader ModuleLoader) {
+	i.loader = loader
+}
+
+func (i *Interpreter) VisitImportStmt(stmt *ast.Import) interface{} {
+	imported := i.importModule(stmt.Path)
+
+	if stmt.Alias.Lexeme != "" {
+		i.environment.Define(stmt.Alias.Lexeme, imported)
+	}
+	for _, name := range stmt.Names {
+		i.environment.Define(name.Lexeme, imported.Get(name))
+	}
+	return nil
+}
+
+// importModule runs the module named by path the first time it is imported
+// and returns its namespace.
+func (i *Interpreter) importModule(path ast.Token) *Module {
+	resolved, err := module.Resolve(path.Literal.(string), i.module.path, i.searchPath)
+	if err != nil {
+		panic(NewRuntimeError(path, err.Error()))
+	}
+
+	if imported, ok := i.modules.Lookup(resolved); ok {
+		return imported.(*Module)
+	}
+
+	if err := i.modules.Enter(resolved); err != nil {
+		panic(NewRuntimeError(path, err.Error()))
+	}
+	var loaded interface{}
+	defer func() {
+		i.modules.Exit(loaded)
+	}()
+
+	if i.loader == nil {
+		panic(NewRuntimeError(path, "Can't import modules here."))
+	}
+	statements, ok := i.loader(resolved)
+	if !ok {
+		panic(NewRuntimeError(path, fmt.Sprintf("Could not load module '%s'.", path.Literal)))
+	}
+
+	imported := NewModule(resolved, i.builtins)
+	defer i.enterModule(imported)()
+
+	previous := i.environment
+	defer func() {
+		i.environment = previous
+	}()
+	i.environment = imported.globals
+	defer i.enterFrame(imported.String())()
+
+	for _, statement := range statements {
+		i.execute(statement)
+	}
+
+	loaded = imported
+	return imported
+}
+
+// enterModule makes m the module whose globals are in scope and returns a
+// function that restores the previous one.
+func (i *Interpreter) enterModule(m *Module) func() {
+	previous := i.module
+	i.module = m
+	i.globals = m.globals
+	return func() {
+		i.module = previous
+		i.globals = previous.globals
+	}
+}
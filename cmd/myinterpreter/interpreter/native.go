@@ -0,0 +1,72 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/distolma/golox/cmd/myinterpreter/ast"
+)
+
+// VariadicArity marks a native that accepts any number of arguments.
+const VariadicArity = -1
+
+type NativeFunction func(arguments []interface{}) (interface{}, error)
+
+type Native struct {
+	name          string
+	argumentCount int
+	function      NativeFunction
+}
+
+func (n *Native) arity() int {
+	return n.argumentCount
+}
+
+func (n *Native) call(_interpreter *Interpreter, paren ast.Token, arguments []interface{}) interface{} {
+	result, err := n.function(arguments)
+	if err != nil {
+		panic(NewNativeError(paren, err))
+	}
+	return result
+}
+
+func (n *Native) String() string {
+	return "<native fn>"
+}
+
+// DefineNative binds a Go function to a global name. Errors it returns are
+// raised as runtime errors at the call site.
+func (i *Interpreter) DefineNative(name string, arity int, function NativeFunction) {
+	i.builtins.Define(name, &Native{name: name, argumentCount: arity, function: function})
+}
+
+// IsCallable reports whether value can be called from Lox.
+func IsCallable(value interface{}) bool {
+	_, ok := value.(Callable)
+	return ok
+}
+
+// Call invokes a Lox callable from Go. Runtime errors raised by the callee are
+// returned instead of being reported to the log.
+func (i *Interpreter) Call(callee interface{}, arguments []interface{}) (result interface{}, err error) {
+	function, ok := callee.(Callable)
+	if !ok {
+		return nil, errors.New("Can only call functions and classes.")
+	}
+
+	if arity := function.arity(); arity != VariadicArity && len(arguments) != arity {
+		return nil, fmt.Errorf("Expected %d arguments but got %d.", arity, len(arguments))
+	}
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			if runtimeError, ok := recovered.(RuntimeError); ok {
+				result, err = nil, &runtimeError
+			} else {
+				panic(recovered)
+			}
+		}
+	}()
+
+	return function.call(i, ast.Token{}, arguments), nil
+}
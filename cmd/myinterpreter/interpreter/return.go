@@ -3,3 +3,8 @@ package interpreter
 type Return struct {
 	Value interface{}
 }
+
+// Break and Continue unwind a loop body like Return unwinds a call.
+type Break struct{}
+
+type Continue struct{}
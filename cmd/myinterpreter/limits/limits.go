@@ -0,0 +1,125 @@
+package limits
+
+import (
+	"context"
+	"errors"
+)
+
+// DefaultMaxCallDepth keeps deep recursion well inside the Go stack of the
+// tree-walking interpreter.
+const DefaultMaxCallDepth = 1 << 16
+
+// Codes identify which limit a runtime error reports.
+const (
+	CodeStepLimit   = "step-limit"
+	CodeCallDepth   = "call-depth"
+	CodeMemoryLimit = "memory-limit"
+	CodeCancelled   = "cancelled"
+	CodeTimeout     = "timeout"
+)
+
+// Approximate sizes, in bytes, charged for runtime allocations.
+const (
+	SizeEnvironment = 64
+	SizeFunction    = 64
+	SizeClass       = 64
+	SizeInstance    = 64
+	SizeField       = 32
+	SizeList        = 64
+	SizeElement     = 16
+	SizeMap         = 64
+	SizeEntry       = 48
+)
+
+// Limits bounds the resources a script may use. Zero values mean no limit,
+// except MaxCallDepth, which falls back to DefaultMaxCallDepth.
+type Limits struct {
+	// MaxSteps caps executed statements in the tree-walker and executed
+	// instructions in the VM.
+	MaxSteps int
+	// MaxCallDepth caps the number of nested calls.
+	MaxCallDepth int
+	// MaxMemory caps the approximate number of bytes allocated over a run.
+	// Memory freed by the garbage collector is not given back.
+	MaxMemory int
+}
+
+// Violation reports an exceeded limit. It is an error so that natives can
+// return it.
+type Violation struct {
+	Code    string
+	Message string
+}
+
+func (v *Violation) Error() string {
+	return v.Message
+}
+
+// Meter tracks the usage of a single run against Limits.
+type Meter struct {
+	limits Limits
+	steps  int
+	depth  int
+	memory int
+}
+
+func NewMeter(limits Limits) *Meter {
+	if limits.MaxCallDepth == 0 {
+		limits.MaxCallDepth = DefaultMaxCallDepth
+	}
+	return &Meter{limits: limits}
+}
+
+// Reset clears the usage counters before a new run.
+func (m *Meter) Reset() {
+	m.steps = 0
+	m.depth = 0
+	m.memory = 0
+}
+
+func (m *Meter) Step() *Violation {
+	m.steps++
+	if m.limits.MaxSteps > 0 && m.steps > m.limits.MaxSteps {
+		return &Violation{Code: CodeStepLimit, Message: "Step limit exceeded."}
+	}
+	return nil
+}
+
+// Enter records a call. Every call that returns no violation must be
+// matched by Exit.
+func (m *Meter) Enter() *Violation {
+	if m.depth >= m.limits.MaxCallDepth {
+		return &Violation{Code: CodeCallDepth, Message: "Stack overflow."}
+	}
+	m.depth++
+	return nil
+}
+
+func (m *Meter) Exit() {
+	m.depth--
+}
+
+func (m *Meter) Allocate(bytes int) *Violation {
+	m.memory += bytes
+	if m.limits.MaxMemory > 0 && m.memory > m.limits.MaxMemory {
+		return &Violation{Code: CodeMemoryLimit, Message: "Memory limit exceeded."}
+	}
+	return nil
+}
+
+// CheckDepth reports whether a call stack of depth frames may grow by one
+// more call. It is used by callers that track depth themselves.
+func (m *Meter) CheckDepth(depth int) *Violation {
+	if depth >= m.limits.MaxCallDepth {
+		return &Violation{Code: CodeCallDepth, Message: "Stack overflow."}
+	}
+	return nil
+}
+
+// Interrupted describes why a run stopped once its context is done.
+func Interrupted(err error) *Violation {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &Violation{Code: CodeTimeout, Message: "Execution timed out."}
+	}
+	return &Violation{Code: CodeCancelled, Message: "Execution cancelled."}
+}
@@ -0,0 +1,144 @@
+package lint
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/distolma/golox/cmd/myinterpreter/ast"
+)
+
+// terminates reports whether control never continues past stmt.
+func terminates(stmt ast.Stmt) bool {
+	switch stmt := stmt.(type) {
+	case *ast.Return, *ast.Throw, *ast.Break, *ast.Continue:
+		return true
+	case *ast.Block:
+		for _, inner := range stmt.Statements {
+			if terminates(inner) {
+				return true
+			}
+		}
+	case *ast.If:
+		return stmt.ElseBranch != nil && terminates(stmt.ThenBranch) && terminates(stmt.ElseBranch)
+	}
+	return false
+}
+
+// start is the token to report a statement at. Blocks are reported at their
+// first statement, or their closing brace when empty.
+func start(stmt ast.Stmt) ast.Token {
+	if block, ok := stmt.(*ast.Block); ok {
+		if len(block.Statements) == 0 {
+			return block.Brace
+		}
+		return start(block.Statements[0])
+	}
+	token, _ := ast.Start(stmt)
+	return token
+}
+
+// constant reports whether the value of expr is the same every time it is
+// evaluated.
+func constant(expr ast.Expr) bool {
+	switch expr := expr.(type) {
+	case *ast.Literal, *ast.Lambda, *ast.List, *ast.Map:
+		return true
+	case *ast.Grouping:
+		return constant(expr.Expression)
+	case *ast.Unary:
+		return constant(expr.Right)
+	case *ast.Binary:
+		return constant(expr.Left) && constant(expr.Right)
+	case *ast.Logical:
+		return constant(expr.Left) && constant(expr.Right)
+	}
+	return false
+}
+
+// same reports whether two expressions certainly read the same value.
+func same(a ast.Expr, b ast.Expr) bool {
+	if grouping, ok := a.(*ast.Grouping); ok {
+		return same(grouping.Expression, b)
+	}
+	if grouping, ok := b.(*ast.Grouping); ok {
+		return same(a, grouping.Expression)
+	}
+
+	switch a := a.(type) {
+	case *ast.Variable:
+		b, ok := b.(*ast.Variable)
+		return ok && a.Name.Lexeme == b.Name.Lexeme
+	case *ast.This:
+		_, ok := b.(*ast.This)
+		return ok
+	case *ast.Get:
+		b, ok := b.(*ast.Get)
+		return ok && a.Name.Lexeme == b.Name.Lexeme && same(a.Object, b.Object)
+	}
+	return false
+}
+
+// suppressions maps lines to the rules ignored on them. The empty rule
+// stands for all of them.
+type suppressions map[int]map[string]bool
+
+func (s suppressions) has(line int, rule string) bool {
+	return s[line][rule] || s[line][""]
+}
+
+// ignores reads '// lox:ignore RULE[, RULE...]' comments. One at the end of
+// a line covers that line; one on a line of its own covers the next. The rule
+// names are also returned as tokens locating them in the comments.
+func ignores(tokens []ast.Token) (suppressions, []ast.Token) {
+	s := make(suppressions)
+	var names []ast.Token
+	for _, token := range tokens {
+		for _, trivia := range token.Trivia {
+			text := strings.TrimSpace(strings.TrimPrefix(trivia.Text, "//"))
+			rest, ok := strings.CutPrefix(text, "lox:ignore")
+			if trivia.Kind != ast.TriviaComment || !ok {
+				continue
+			}
+
+			line := trivia.Line
+			if !trivia.Trailing {
+				line++
+			}
+			if s[line] == nil {
+				s[line] = make(map[string]bool)
+			}
+
+			rules := strings.FieldsFunc(rest, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+			if len(rules) == 0 {
+				s[line][""] = true
+			}
+			offset := trivia.Offset + strings.Index(trivia.Text, "lox:ignore") + len("lox:ignore")
+			for _, rule := range rules {
+				s[line][rule] = true
+
+				offset += strings.Index(trivia.Text[offset-trivia.Offset:], rule)
+				names = append(names, ast.Token{
+					Type:   ast.TIdentifier,
+					Lexeme: rule,
+					Line:   trivia.Line,
+					Column: column(token.Source, offset),
+					Offset: offset,
+					Length: len(rule),
+					Source: token.Source,
+				})
+				offset += len(rule)
+			}
+		}
+	}
+	return s, names
+}
+
+// column is the 1-based rune column of offset in source, or zero if there is
+// no source to count in.
+func column(source *ast.Source, offset int) int {
+	if source == nil || offset > len(source.Text) {
+		return 0
+	}
+	start := strings.LastIndexByte(source.Text[:offset], '\n') + 1
+	return utf8.RuneCountInString(source.Text[start:offset]) + 1
+}
@@ -0,0 +1,425 @@
+// Package lint finds likely mistakes in Lox programs that are not errors:
+// unused variables, shadowing, unreachable code and the like.
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/distolma/golox/cmd/myinterpreter/ast"
+	"github.com/distolma/golox/cmd/myinterpreter/collection"
+	logerror "github.com/distolma/golox/cmd/myinterpreter/log_error"
+)
+
+// binding is a local declaration and whether it has been read.
+type binding struct {
+	name ast.Token
+	kind string
+	used bool
+}
+
+type scope struct {
+	bindings map[string]*binding
+	order    []*binding
+}
+
+type finding struct {
+	token   ast.Token
+	rule    Rule
+	message string
+}
+
+type Linter struct {
+	log      *logerror.LogError
+	disabled map[string]bool
+	scopes   []*scope
+	globals  map[string]bool
+	findings []finding
+}
+
+func NewLinter(log *logerror.LogError) *Linter {
+	return &Linter{log: log, disabled: make(map[string]bool)}
+}
+
+// Disable turns off the rule with the given ID.
+func (l *Linter) Disable(id string) error {
+	if _, ok := findRule(id); !ok {
+		return fmt.Errorf("Unknown lint rule: %s", id)
+	}
+	l.disabled[id] = true
+	return nil
+}
+
+// Lint checks statements, parsed from tokens, and reports what it finds to
+// the log. It returns the number of findings that weren't disabled or
+// ignored.
+func (l *Linter) Lint(tokens []ast.Token, statements []ast.Stmt) int {
+	l.log.Phase = logerror.PhaseLint
+	l.scopes = nil
+	l.findings = nil
+	l.globals = globals(statements)
+
+	l.statements(statements)
+
+	ignored, names := ignores(tokens)
+	for _, name := range names {
+		if _, ok := findRule(name.Lexeme); !ok {
+			l.report(name, UnknownRule, fmt.Sprintf("Unknown lint rule '%s'.", name.Lexeme))
+		}
+	}
+
+	sort.SliceStable(l.findings, func(i, j int) bool {
+		return l.findings[i].token.Offset < l.findings[j].token.Offset
+	})
+
+	count := 0
+	for _, finding := range l.findings {
+		if l.disabled[finding.rule.ID] || ignored.has(finding.token.Line, finding.rule.ID) {
+			continue
+		}
+		l.log.Lint(finding.token, finding.rule.ID, finding.rule.Severity, finding.message)
+		count++
+	}
+	return count
+}
+
+// globals collects the names declared at the top level, which can be used
+// before their declaration from inside functions, along with the natives.
+func globals(statements []ast.Stmt) map[string]bool {
+	names := map[string]bool{"clock": true}
+	for _, native := range collection.Natives {
+		names[native.Name] = true
+	}
+
+	for _, stmt := range statements {
+		switch stmt := stmt.(type) {
+		case *ast.Var:
+			names[stmt.Name.Lexeme] = true
+		case *ast.Function:
+			names[stmt.Name.Lexeme] = true
+		case *ast.Class:
+			names[stmt.Name.Lexeme] = true
+		case *ast.Import:
+			names[stmt.Alias.Lexeme] = true
+			for _, name := range stmt.Names {
+				names[name.Lexeme] = true
+			}
+		}
+	}
+	return names
+}
+
+func (l *Linter) report(token ast.Token, id string, message string) {
+	rule, _ := findRule(id)
+	l.findings = append(l.findings, finding{token: token, rule: rule, message: message})
+}
+
+func (l *Linter) beginScope() {
+	l.scopes = append(l.scopes, &scope{bindings: make(map[string]*binding)})
+}
+
+// endScope reports the declarations of the innermost scope that were never
+// read. Names starting with an underscore are meant to be unused.
+func (l *Linter) endScope() {
+	scope := l.scopes[len(l.scopes)-1]
+	l.scopes = l.scopes[:len(l.scopes)-1]
+
+	for _, binding := range scope.order {
+		if binding.used || strings.HasPrefix(binding.name.Lexeme, "_") {
+			continue
+		}
+		if binding.kind == "parameter" {
+			l.report(binding.name, UnusedParameter, fmt.Sprintf("Parameter '%s' is never used.", binding.name.Lexeme))
+		} else {
+			l.report(binding.name, UnusedLocal, fmt.Sprintf("Local %s '%s' is never used.", binding.kind, binding.name.Lexeme))
+		}
+	}
+}
+
+// declare adds a local declaration of the given kind to the innermost scope.
+// Globals aren't tracked.
+func (l *Linter) declare(name ast.Token, kind string) *binding {
+	if len(l.scopes) == 0 {
+		return &binding{name: name, kind: kind}
+	}
+
+	for i := len(l.scopes) - 2; i >= 0; i-- {
+		if shadowed, ok := l.scopes[i].bindings[name.Lexeme]; ok {
+			l.report(name, ShadowedVariable, fmt.Sprintf("'%s' shadows the %s declared on line %d.", name.Lexeme, shadowed.kind, shadowed.name.Line))
+			break
+		}
+	}
+
+	binding := &binding{name: name, kind: kind}
+	scope := l.scopes[len(l.scopes)-1]
+	scope.bindings[name.Lexeme] = binding
+	scope.order = append(scope.order, binding)
+	return binding
+}
+
+// lookup finds the local declaration of name, if there is one.
+func (l *Linter) lookup(name string) (*binding, bool) {
+	for i := len(l.scopes) - 1; i >= 0; i-- {
+		if binding, ok := l.scopes[i].bindings[name]; ok {
+			return binding, true
+		}
+	}
+	return nil, false
+}
+
+// statements lints a list of statements, reporting the first one that can't
+// be reached.
+func (l *Linter) statements(statements []ast.Stmt) {
+	reported := false
+	for i, stmt := range statements {
+		l.stmt(stmt)
+		if !reported && i+1 < len(statements) && terminates(stmt) {
+			l.report(start(statements[i+1]), UnreachableCode, "Unreachable code.")
+			reported = true
+		}
+	}
+}
+
+func (l *Linter) function(function *ast.Function) {
+	l.beginScope()
+	for _, param := range function.Params {
+		l.declare(param, "parameter")
+	}
+	l.statements(function.Body)
+	l.endScope()
+}
+
+func (l *Linter) stmt(stmt ast.Stmt) {
+	stmt.Accept(l)
+}
+
+func (l *Linter) expr(expr ast.Expr) {
+	expr.Accept(l)
+}
+
+func (l *Linter) VisitBlockStmt(stmt *ast.Block) interface{} {
+	l.beginScope()
+	l.statements(stmt.Statements)
+	l.endScope()
+	return nil
+}
+
+func (l *Linter) VisitBreakStmt(stmt *ast.Break) interface{} {
+	return nil
+}
+
+func (l *Linter) VisitClassStmt(stmt *ast.Class) interface{} {
+	l.declare(stmt.Name, "class")
+	if stmt.Superclass != nil {
+		l.expr(stmt.Superclass)
+	}
+
+	for _, method := range stmt.Methods {
+		l.function(method)
+	}
+	return nil
+}
+
+func (l *Linter) VisitContinueStmt(stmt *ast.Continue) interface{} {
+	return nil
+}
+
+func (l *Linter) VisitExpressionStmt(stmt *ast.Expression) interface{} {
+	l.expr(stmt.Expression)
+	return nil
+}
+
+func (l *Linter) VisitFunctionStmt(stmt *ast.Function) interface{} {
+	l.declare(stmt.Name, "function")
+	l.function(stmt)
+	return nil
+}
+
+func (l *Linter) VisitIfStmt(stmt *ast.If) interface{} {
+	if constant(stmt.Condition) {
+		l.report(ast.First(stmt.Condition), ConstantCondition, "Condition is constant.")
+	}
+
+	l.expr(stmt.Condition)
+	l.stmt(stmt.ThenBranch)
+	if stmt.ElseBranch != nil {
+		l.stmt(stmt.ElseBranch)
+	}
+	return nil
+}
+
+func (l *Linter) VisitImportStmt(stmt *ast.Import) interface{} {
+	if stmt.Alias.Lexeme != "" {
+		l.declare(stmt.Alias, "module")
+	}
+	for _, name := range stmt.Names {
+		l.declare(name, "import")
+	}
+	return nil
+}
+
+func (l *Linter) VisitPrintStmt(stmt *ast.Print) interface{} {
+	l.expr(stmt.Expression)
+	return nil
+}
+
+func (l *Linter) VisitReturnStmt(stmt *ast.Return) interface{} {
+	if stmt.Value != nil {
+		l.expr(stmt.Value)
+	}
+	return nil
+}
+
+func (l *Linter) VisitThrowStmt(stmt *ast.Throw) interface{} {
+	l.expr(stmt.Value)
+	return nil
+}
+
+func (l *Linter) VisitTryStmt(stmt *ast.Try) interface{} {
+	l.stmt(stmt.Body)
+	if stmt.Catch != nil {
+		// Catching an error without looking at it is fine.
+		l.beginScope()
+		l.declare(stmt.Name, "variable").used = true
+		l.stmt(stmt.Catch)
+		l.endScope()
+	}
+	if stmt.Finally != nil {
+		l.stmt(stmt.Finally)
+	}
+	return nil
+}
+
+func (l *Linter) VisitVarStmt(stmt *ast.Var) interface{} {
+	if stmt.Initializer != nil {
+		l.expr(stmt.Initializer)
+	}
+	l.declare(stmt.Name, "variable")
+	return nil
+}
+
+func (l *Linter) VisitWhileStmt(stmt *ast.While) interface{} {
+	// 'while (true)' and 'for (;;)' loop until something breaks out, which
+	// is what they mean to do.
+	literal, ok := stmt.Condition.(*ast.Literal)
+	infinite := ok && (literal.Value == true || literal.Token.Type == ast.TFor)
+	if !infinite && constant(stmt.Condition) {
+		l.report(ast.First(stmt.Condition), ConstantCondition, "Condition is constant.")
+	}
+
+	l.expr(stmt.Condition)
+	l.stmt(stmt.Body)
+	if stmt.Increment != nil {
+		l.expr(stmt.Increment)
+	}
+	return nil
+}
+
+func (l *Linter) VisitAssignExpr(expr *ast.Assign) interface{} {
+	l.expr(expr.Value)
+	if _, ok := l.lookup(expr.Name.Lexeme); !ok && !l.globals[expr.Name.Lexeme] {
+		l.report(expr.Name, UndeclaredGlobal, fmt.Sprintf("Assignment to undeclared variable '%s'.", expr.Name.Lexeme))
+	}
+	return nil
+}
+
+func (l *Linter) VisitBinaryExpr(expr *ast.Binary) interface{} {
+	switch expr.Operator.Type {
+	case ast.TEqualEqual, ast.TBangEqual, ast.TLess, ast.TLessEqual, ast.TGreater, ast.TGreaterEqual:
+		if same(expr.Left, expr.Right) {
+			l.report(expr.Operator, SelfComparison, fmt.Sprintf("Both sides of '%s' are the same.", expr.Operator.Lexeme))
+		}
+	}
+
+	l.expr(expr.Left)
+	l.expr(expr.Right)
+	return nil
+}
+
+func (l *Linter) VisitCallExpr(expr *ast.Call) interface{} {
+	l.expr(expr.Callee)
+	for _, argument := range expr.Arguments {
+		l.expr(argument)
+	}
+	return nil
+}
+
+func (l *Linter) VisitGetExpr(expr *ast.Get) interface{} {
+	l.expr(expr.Object)
+	return nil
+}
+
+func (l *Linter) VisitGroupingExpr(expr *ast.Grouping) interface{} {
+	l.expr(expr.Expression)
+	return nil
+}
+
+func (l *Linter) VisitIndexExpr(expr *ast.Index) interface{} {
+	l.expr(expr.Object)
+	l.expr(expr.Index)
+	return nil
+}
+
+func (l *Linter) VisitLambdaExpr(expr *ast.Lambda) interface{} {
+	l.function(expr.Function)
+	return nil
+}
+
+func (l *Linter) VisitListExpr(expr *ast.List) interface{} {
+	for _, element := range expr.Elements {
+		l.expr(element)
+	}
+	return nil
+}
+
+func (l *Linter) VisitLiteralExpr(expr *ast.Literal) interface{} {
+	return nil
+}
+
+func (l *Linter) VisitLogicalExpr(expr *ast.Logical) interface{} {
+	l.expr(expr.Left)
+	l.expr(expr.Right)
+	return nil
+}
+
+func (l *Linter) VisitMapExpr(expr *ast.Map) interface{} {
+	for i := range expr.Keys {
+		l.expr(expr.Keys[i])
+		l.expr(expr.Values[i])
+	}
+	return nil
+}
+
+func (l *Linter) VisitSetExpr(expr *ast.Set) interface{} {
+	l.expr(expr.Object)
+	l.expr(expr.Value)
+	return nil
+}
+
+func (l *Linter) VisitSetIndexExpr(expr *ast.SetIndex) interface{} {
+	l.expr(expr.Object)
+	l.expr(expr.Index)
+	l.expr(expr.Value)
+	return nil
+}
+
+func (l *Linter) VisitSuperExpr(expr *ast.Super) interface{} {
+	return nil
+}
+
+func (l *Linter) VisitThisExpr(expr *ast.This) interface{} {
+	return nil
+}
+
+func (l *Linter) VisitUnaryExpr(expr *ast.Unary) interface{} {
+	l.expr(expr.Right)
+	return nil
+}
+
+func (l *Linter) VisitVariableExpr(expr *ast.Variable) interface{} {
+	if binding, ok := l.lookup(expr.Name.Lexeme); ok {
+		binding.used = true
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package lint
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"testing"
+
+	logerror "github.com/distolma/golox/cmd/myinterpreter/log_error"
+	"github.com/distolma/golox/cmd/myinterpreter/parser"
+	"github.com/distolma/golox/cmd/myinterpreter/scanner"
+)
+
+// lint returns the findings for source as "line:column rule".
+func lint(t *testing.T, source string) []string {
+	t.Helper()
+
+	log := &logerror.LogError{Output: io.Discard}
+	tokens := scanner.NewScanner(source, log).ScanTokens()
+	statements := parser.NewParser(tokens, log).Parse()
+	if log.HadError {
+		t.Fatalf("parsing %q failed: %v", source, log.Reports)
+	}
+
+	NewLinter(log).Lint(tokens, statements)
+	var findings []string
+	for _, report := range log.Reports {
+		findings = append(findings, fmt.Sprintf("%d:%d %s", report.Line, report.Column, report.Code))
+	}
+	return findings
+}
+
+func TestIgnoreComments(t *testing.T) {
+	tests := map[string]struct {
+		source string
+		want   []string
+	}{
+		"reported": {
+			"fun f() { var a = 1; }",
+			[]string{"1:15 unused-local"},
+		},
+		"trailing comment": {
+			"fun f() { var a = 1; } // lox:ignore unused-local",
+			nil,
+		},
+		"comment before the line": {
+			"// lox:ignore\nfun f() { var a = 1; }",
+			nil,
+		},
+		"other rule": {
+			"fun f() { var a = 1; } // lox:ignore unused-parameter",
+			[]string{"1:15 unused-local"},
+		},
+		"unknown rule": {
+			"fun f() { var a = 1; } // lox:ignore unused-local, unsed-local",
+			[]string{"1:52 unknown-rule"},
+		},
+		"unknown rule on a line of its own": {
+			"fun f(x) {\n  // lox:ignore  bogus\n  return x;\n}",
+			[]string{"2:18 unknown-rule"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := lint(t, test.source); !slices.Equal(got, test.want) {
+				t.Errorf("findings = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package lint
+
+import logerror "github.com/distolma/golox/cmd/myinterpreter/log_error"
+
+// Rule is a check the linter makes. Its ID names it in reports, in
+// '// lox:ignore' comments and on the command line.
+type Rule struct {
+	ID          string
+	Severity    string
+	Description string
+}
+
+const (
+	UnusedLocal       = "unused-local"
+	UnusedParameter   = "unused-parameter"
+	ShadowedVariable  = "shadowed-variable"
+	UnreachableCode   = "unreachable-code"
+	UndeclaredGlobal  = "undeclared-global"
+	ConstantCondition = "constant-condition"
+	SelfComparison    = "self-comparison"
+	UnknownRule       = "unknown-rule"
+)
+
+var Rules = []Rule{
+	{UnusedLocal, logerror.SeverityWarning, "A local variable, function or class is never read."},
+	{UnusedParameter, logerror.SeverityInfo, "A parameter is never read."},
+	{ShadowedVariable, logerror.SeverityWarning, "A local declaration hides a variable of an enclosing scope."},
+	{UnreachableCode, logerror.SeverityWarning, "A statement follows a return, throw, break or continue."},
+	{UndeclaredGlobal, logerror.SeverityError, "A variable that is declared nowhere is assigned to."},
+	{ConstantCondition, logerror.SeverityWarning, "An if or while condition doesn't depend on anything."},
+	{SelfComparison, logerror.SeverityWarning, "Something is compared with itself."},
+	{UnknownRule, logerror.SeverityWarning, "A '// lox:ignore' comment names a rule that doesn't exist."},
+}
+
+func findRule(id string) (Rule, bool) {
+	for _, rule := range Rules {
+		if rule.ID == id {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
@@ -0,0 +1,218 @@
+package logerror
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/distolma/golox/cmd/myinterpreter/ast"
+)
+
+// Locate fills in the file and exact extent of the error from token.
+func (r *Report) Locate(token ast.Token) {
+	if token.Source == nil {
+		return
+	}
+	r.File = token.Source.Name
+
+	text := token.Source.Text
+	if token.Column == 0 || token.Offset > len(text) {
+		return
+	}
+	r.Line, r.Column = position(text, token.Offset)
+	r.EndLine, r.EndColumn = position(text, min(token.Offset+token.Length, len(text)))
+}
+
+// position converts a byte offset into a 1-based line and rune column.
+func position(text string, offset int) (int, int) {
+	start := strings.LastIndexByte(text[:offset], '\n') + 1
+	return strings.Count(text[:start], "\n") + 1, utf8.RuneCountInString(text[start:offset]) + 1
+}
+
+// Kind names the kind of error for tools, falling back on the phase for
+// errors that don't have a code of their own.
+func (r Report) Kind() string {
+	if r.Code != "" {
+		return r.Code
+	}
+	switch r.Phase {
+	case PhaseScan, PhaseParse:
+		return "syntax-error"
+	case PhaseResolve, PhaseCompile:
+		return "semantic-error"
+	}
+	if r.Runtime {
+		return "runtime-error"
+	}
+	return "error"
+}
+
+// record is the JSON form of a report.
+type record struct {
+	Severity  string `json:"severity"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	File      string `json:"file,omitempty"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column,omitempty"`
+	EndLine   int    `json:"endLine,omitempty"`
+	EndColumn int    `json:"endColumn,omitempty"`
+	Phase     string `json:"phase,omitempty"`
+}
+
+// severity is how serious the report is, which is SeverityError unless it
+// is a lint finding.
+func (r Report) severity() string {
+	if r.Severity == "" {
+		return SeverityError
+	}
+	return r.Severity
+}
+
+func (r Report) record() record {
+	return record{
+		Severity:  r.severity(),
+		Code:      r.Kind(),
+		Message:   r.Message,
+		File:      r.File,
+		Line:      r.Line,
+		Column:    r.Column,
+		EndLine:   r.EndLine,
+		EndColumn: r.EndColumn,
+		Phase:     r.Phase,
+	}
+}
+
+// write outputs a report in a machine-readable format. JSON reports are
+// written one per line as they happen; SARIF needs all of them, so they wait
+// for Flush.
+func (l *LogError) write(report Report) {
+	if l.Format != FormatJSON {
+		return
+	}
+
+	line, err := json.Marshal(report.record())
+	if err != nil {
+		panic(err)
+	}
+	fmt.Fprintf(l.output(), "%s\n", line)
+}
+
+// Flush writes the errors that are held back until the end of the run,
+// which only the SARIF format does.
+func (l *LogError) Flush() {
+	if l.Format != FormatSARIF {
+		return
+	}
+
+	document, err := json.MarshalIndent(l.sarif(), "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Fprintf(l.output(), "%s\n", document)
+}
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleID     string            `json:"ruleId"`
+	Level      string            `json:"level"`
+	Message    sarifMessage      `json:"message"`
+	Locations  []sarifLocation   `json:"locations,omitempty"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation *sarifArtifactLocation `json:"artifactLocation,omitempty"`
+	Region           sarifRegion            `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndLine     int `json:"endLine,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+func (l *LogError) sarif() sarifLog {
+	run := sarifRun{
+		Tool:    sarifTool{Driver: sarifDriver{Name: "golox", Rules: []sarifRule{}}},
+		Results: []sarifResult{},
+	}
+
+	rules := make(map[string]bool)
+	for _, report := range l.Reports {
+		code := report.Kind()
+		if !rules[code] {
+			rules[code] = true
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: code})
+		}
+
+		location := sarifPhysicalLocation{Region: sarifRegion{
+			StartLine:   report.Line,
+			StartColumn: report.Column,
+			EndLine:     report.EndLine,
+			EndColumn:   report.EndColumn,
+		}}
+		if report.File != "" {
+			location.ArtifactLocation = &sarifArtifactLocation{URI: filepath.ToSlash(report.File)}
+		}
+
+		level := report.severity()
+		if level == SeverityInfo {
+			level = "note"
+		}
+		result := sarifResult{
+			RuleID:    code,
+			Level:     level,
+			Message:   sarifMessage{Text: report.Message},
+			Locations: []sarifLocation{{PhysicalLocation: location}},
+		}
+		if report.Phase != "" {
+			result.Properties = map[string]string{"phase": report.Phase}
+		}
+		run.Results = append(run.Results, result)
+	}
+
+	return sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{run},
+	}
+}
@@ -2,23 +2,113 @@ package logerror
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"strings"
 
 	"github.com/distolma/golox/cmd/myinterpreter/ast"
 )
 
+// Phases of running a script, recorded with each error.
+const (
+	PhaseScan    = "scan"
+	PhaseParse   = "parse"
+	PhaseResolve = "resolve"
+	PhaseCompile = "compile"
+	PhaseRuntime = "runtime"
+	PhaseLint    = "lint"
+)
+
+// Severities of lint findings. Errors found while running a script are
+// always SeverityError.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityInfo    = "info"
+)
+
+// Output formats. FormatText is meant for people; the others are read by
+// tools.
+const (
+	FormatText  = "text"
+	FormatJSON  = "json"
+	FormatSARIF = "sarif"
+)
+
+// Report is a single error as it was reported, kept so embedders can inspect
+// errors without parsing the text output.
+type Report struct {
+	Line    int
+	Where   string
+	Message string
+	Runtime bool
+	// Code identifies the kind of runtime error, if it has one.
+	Code  string
+	Phase string
+	// File is the name of the file the error is in, if it came from one.
+	File string
+	// Column, EndLine and EndColumn are zero when the position of the error
+	// is only known to the line. EndColumn is just past the last character.
+	Column    int
+	EndLine   int
+	EndColumn int
+	// Severity is empty for errors and set for lint findings.
+	Severity string
+}
+
 type LogError struct {
 	HadError        bool
 	HadRuntimeError bool
+	// Output receives the formatted errors. It defaults to os.Stderr.
+	Output io.Writer
+	// Color highlights errors with ANSI escape codes.
+	Color bool
+	// Format selects how errors are written to Output. It defaults to
+	// FormatText.
+	Format string
+	// Phase is the phase that errors reported from now on belong to. Each
+	// stage sets it when it starts.
+	Phase   string
+	Reports []Report
+}
+
+func (l *LogError) output() io.Writer {
+	if l.Output == nil {
+		return os.Stderr
+	}
+	return l.Output
+}
+
+// Reset clears the error state so the log can be reused for another run.
+func (l *LogError) Reset() {
+	l.HadError = false
+	l.HadRuntimeError = false
+	l.Reports = nil
 }
 
-func (l *LogError) report(line int, where string, message string) {
-	fmt.Fprintf(os.Stderr, "[line %d] Error%s: %s\n", line, where, message)
+// report prints an error followed by a snippet of the source at token.
+func (l *LogError) report(token ast.Token, where string, message string) {
+	report := Report{Line: token.Line, Where: where, Message: message, Phase: l.Phase}
+	report.Locate(token)
+	l.Reports = append(l.Reports, report)
 	l.HadError = true
+
+	if l.Format != "" && l.Format != FormatText {
+		l.write(report)
+		return
+	}
+	fmt.Fprintf(l.output(), "%s[line %d] Error%s:%s %s\n", l.style(ansiRed), token.Line, where, l.style(ansiReset), message)
+	fmt.Fprint(l.output(), l.snippet(token))
 }
 
 func (l *LogError) Error(line int, message string) {
-	l.report(line, "", message)
+	l.report(ast.Token{Line: line}, "", message)
+}
+
+// ErrorAt reports an error that is located at token but not about its text,
+// such as a malformed escape inside a string.
+func (l *LogError) ErrorAt(token ast.Token, message string) {
+	l.report(token, "", message)
 }
 
 func (l *LogError) TokenError(token ast.Token, message string) {
@@ -29,10 +119,41 @@ func (l *LogError) TokenError(token ast.Token, message string) {
 		where = fmt.Sprintf(" at '%s'", token.Lexeme)
 	}
 
-	l.report(token.Line, where, message)
+	l.report(token, where, message)
 }
 
 func (l *LogError) RuntimeError(token ast.Token, message string) {
-	fmt.Fprintf(os.Stderr, "%s \n[line: %d]", message, token.Line)
+	l.RuntimeErrorWithCode(token, "", message)
+}
+
+func (l *LogError) RuntimeErrorWithCode(token ast.Token, code string, message string) {
+	report := Report{Line: token.Line, Message: message, Runtime: true, Code: code, Phase: PhaseRuntime}
+	report.Locate(token)
+	l.Reports = append(l.Reports, report)
 	l.HadRuntimeError = true
+
+	if l.Format != "" && l.Format != FormatText {
+		l.write(report)
+		return
+	}
+	fmt.Fprintf(l.output(), "%s%s%s \n[line: %d]", l.style(ansiRed), message, l.style(ansiReset), token.Line)
+	if snippet := l.snippet(token); snippet != "" {
+		fmt.Fprint(l.output(), "\n"+snippet)
+	}
+}
+
+// Lint reports a finding of the lint rule named rule at token. Findings
+// don't count as errors; the caller decides what they mean for the run.
+func (l *LogError) Lint(token ast.Token, rule string, severity string, message string) {
+	report := Report{Line: token.Line, Message: message, Code: rule, Phase: PhaseLint, Severity: severity}
+	report.Locate(token)
+	l.Reports = append(l.Reports, report)
+
+	if l.Format != "" && l.Format != FormatText {
+		l.write(report)
+		return
+	}
+	label := strings.ToUpper(severity[:1]) + severity[1:]
+	fmt.Fprintf(l.output(), "%s[line %d] %s:%s %s [%s]\n", l.style(ansiRed), token.Line, label, l.style(ansiReset), message, rule)
+	fmt.Fprint(l.output(), l.snippet(token))
 }
@@ -0,0 +1,79 @@
+package logerror
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/distolma/golox/cmd/myinterpreter/ast"
+)
+
+const (
+	ansiReset = "\x1b[0m"
+	ansiBold  = "\x1b[1m"
+	ansiRed   = "\x1b[1;31m"
+	ansiBlue  = "\x1b[1;34m"
+)
+
+// snippet renders the source line token comes from with the token underlined:
+//
+//	 --> script.lox:3:7
+//	  |
+//	3 | print x
+//	  |       ^
+//
+// It returns an empty string for tokens without a position.
+func (l *LogError) snippet(token ast.Token) string {
+	if token.Source == nil || token.Column == 0 || token.Offset > len(token.Source.Text) {
+		return ""
+	}
+
+	text := token.Source.Text
+	start := strings.LastIndexByte(text[:token.Offset], '\n') + 1
+	end := strings.IndexByte(text[start:], '\n')
+	if end < 0 {
+		end = len(text)
+	} else {
+		end += start
+	}
+	line := strings.TrimRight(text[start:end], "\r")
+	number := strings.Count(text[:start], "\n") + 1
+
+	// Pad with the same tabs as the line so the caret lines up.
+	before := line[:min(token.Offset-start, len(line))]
+	padding := strings.Map(func(r rune) rune {
+		if r == '\t' {
+			return r
+		}
+		return ' '
+	}, before)
+
+	rest := line[len(before):]
+	width := utf8.RuneCountInString(rest[:min(token.Length, len(rest))])
+	underline := "^" + strings.Repeat("~", max(width-1, 0))
+
+	name := token.Source.Name
+	if name == "" {
+		name = "<input>"
+	}
+	column := utf8.RuneCountInString(before) + 1
+
+	gutter := strconv.Itoa(number)
+	blank := strings.Repeat(" ", len(gutter))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s%s-->%s %s:%d:%d\n", blank, l.style(ansiBlue), l.style(ansiReset), name, number, column)
+	fmt.Fprintf(&b, "%s %s|%s\n", blank, l.style(ansiBlue), l.style(ansiReset))
+	fmt.Fprintf(&b, "%s%s |%s %s\n", l.style(ansiBlue), gutter, l.style(ansiReset), line)
+	fmt.Fprintf(&b, "%s %s|%s %s%s%s%s\n", blank, l.style(ansiBlue), l.style(ansiReset), padding, l.style(ansiRed), underline, l.style(ansiReset))
+	return b.String()
+}
+
+// style returns the escape code when colour is enabled.
+func (l *LogError) style(code string) string {
+	if !l.Color {
+		return ""
+	}
+	return code
+}
@@ -0,0 +1,361 @@
+package lsp
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/distolma/golox/cmd/myinterpreter/ast"
+	"github.com/distolma/golox/cmd/myinterpreter/interpreter"
+	logerror "github.com/distolma/golox/cmd/myinterpreter/log_error"
+	"github.com/distolma/golox/cmd/myinterpreter/parser"
+	"github.com/distolma/golox/cmd/myinterpreter/resolver"
+	"github.com/distolma/golox/cmd/myinterpreter/scanner"
+)
+
+// text maps between byte offsets and LSP positions, whose characters are
+// counted in UTF-16 code units.
+type text struct {
+	content string
+	lines   []int
+}
+
+func newText(content string) text {
+	lines := []int{0}
+	for i := 0; i < len(content); i++ {
+		if content[i] == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	return text{content: content, lines: lines}
+}
+
+func (t text) position(offset int) position {
+	offset = max(0, min(offset, len(t.content)))
+	line := sort.Search(len(t.lines), func(i int) bool { return t.lines[i] > offset }) - 1
+
+	character := 0
+	for _, r := range t.content[t.lines[line]:offset] {
+		character += utf16Len(r)
+	}
+	return position{Line: line, Character: character}
+}
+
+// utf16Len is the number of UTF-16 code units that encode r.
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+func (t text) offset(p position) int {
+	if p.Line < 0 {
+		return 0
+	}
+	if p.Line >= len(t.lines) {
+		return len(t.content)
+	}
+
+	offset := t.lines[p.Line]
+	for character := 0; offset < len(t.content) && character < p.Character; {
+		r, size := utf8.DecodeRuneInString(t.content[offset:])
+		if r == '\n' {
+			break
+		}
+		character += utf16Len(r)
+		offset += size
+	}
+	return offset
+}
+
+// at converts a 1-based line and rune column, as diagnostics carry them,
+// into a position. Column zero is the start of the line.
+func (t text) at(line int, column int) position {
+	if line < 1 || line > len(t.lines) {
+		return t.position(len(t.content))
+	}
+
+	offset := t.lines[line-1]
+	for ; column > 1 && offset < len(t.content) && t.content[offset] != '\n'; column-- {
+		_, size := utf8.DecodeRuneInString(t.content[offset:])
+		offset += size
+	}
+	return t.position(offset)
+}
+
+func (t text) tokenRange(token ast.Token) textRange {
+	return textRange{Start: t.position(token.Offset), End: t.position(token.Offset + token.Length)}
+}
+
+// document is an open file and what is known about it.
+type document struct {
+	uri         string
+	text        text
+	diagnostics []diagnostic
+	// index describes the last version of the document that parsed, which
+	// is kept while the user is in the middle of an edit.
+	index *index
+}
+
+// analyze runs the front end over content and collects its errors and
+// names. previous supplies the index if content doesn't parse.
+func analyze(uri string, content string, previous *document) *document {
+	log := &logerror.LogError{Output: io.Discard}
+
+	tokens := scanner.NewFileScanner(filename(uri), content, log).ScanTokens()
+	statements := parser.NewParser(tokens, log).Parse()
+
+	doc := &document{uri: uri, text: newText(content)}
+	if !log.HadError {
+		index := newIndex(doc.text, statements)
+
+		resolver := resolver.NewResolver(interpreter.NewInterpreter(log), log)
+		resolver.SetListener(index)
+		resolver.ResolveStmts(statements)
+
+		index.finish()
+		doc.index = index
+	} else if previous != nil {
+		doc.index = previous.index
+	}
+
+	doc.diagnostics = []diagnostic{}
+	for _, report := range log.Reports {
+		start := doc.text.at(report.Line, report.Column)
+		end := start
+		if report.EndLine > 0 {
+			end = doc.text.at(report.EndLine, report.EndColumn)
+		}
+		doc.diagnostics = append(doc.diagnostics, diagnostic{
+			Range:    textRange{Start: start, End: end},
+			Severity: severityError,
+			Code:     report.Kind(),
+			Source:   "lox",
+			Message:  report.Message,
+		})
+	}
+	return doc
+}
+
+// filename turns a file URI into the path diagnostics refer to.
+func filename(uri string) string {
+	if parsed, err := url.Parse(uri); err == nil && parsed.Scheme == "file" {
+		return parsed.Path
+	}
+	return uri
+}
+
+// symbol is a declared name together with every place it is used.
+type symbol struct {
+	name       ast.Token
+	kind       int
+	detail     string
+	references []ast.Token
+}
+
+type occurrence struct {
+	token  ast.Token
+	symbol *symbol
+}
+
+// index records the declarations and uses reported by the resolver.
+type index struct {
+	text        text
+	statements  []ast.Stmt
+	symbols     map[int]*symbol
+	globals     map[string]*symbol
+	occurrences []occurrence
+	// globalUses wait for the end, since a function can use a global that
+	// is declared after it.
+	globalUses []ast.Token
+}
+
+func newIndex(text text, statements []ast.Stmt) *index {
+	return &index{
+		text:       text,
+		statements: statements,
+		symbols:    make(map[int]*symbol),
+		globals:    make(map[string]*symbol),
+	}
+}
+
+func (ix *index) Declare(name ast.Token, global bool) {
+	if global {
+		// Redeclaring a global assigns to the same variable.
+		if existing, ok := ix.globals[name.Lexeme]; ok {
+			ix.add(name, existing)
+			return
+		}
+	}
+
+	declared := &symbol{name: name, kind: symbolVariable, detail: name.Lexeme}
+	ix.symbols[name.Offset] = declared
+	if global {
+		ix.globals[name.Lexeme] = declared
+	}
+	ix.add(name, declared)
+}
+
+func (ix *index) Use(name ast.Token, declaration *ast.Token) {
+	// 'this' and 'super' aren't declared anywhere to jump to.
+	if name.Type != ast.TIdentifier {
+		return
+	}
+
+	if declaration == nil {
+		ix.globalUses = append(ix.globalUses, name)
+		return
+	}
+	if declared, ok := ix.symbols[declaration.Offset]; ok {
+		ix.add(name, declared)
+	}
+}
+
+func (ix *index) add(token ast.Token, declared *symbol) {
+	declared.references = append(declared.references, token)
+	ix.occurrences = append(ix.occurrences, occurrence{token: token, symbol: declared})
+}
+
+// finish links global uses to their declarations, leaving out natives, and
+// describes each symbol from its declaration.
+func (ix *index) finish() {
+	for _, name := range ix.globalUses {
+		if declared, ok := ix.globals[name.Lexeme]; ok {
+			ix.add(name, declared)
+		}
+	}
+	ix.globalUses = nil
+
+	sort.Slice(ix.occurrences, func(i, j int) bool {
+		return ix.occurrences[i].token.Offset < ix.occurrences[j].token.Offset
+	})
+	for _, declared := range ix.symbols {
+		sort.Slice(declared.references, func(i, j int) bool {
+			return declared.references[i].Offset < declared.references[j].Offset
+		})
+	}
+
+	ix.describe(ix.statements)
+}
+
+// describe walks declarations to give their symbols a kind and the text
+// shown on hover.
+func (ix *index) describe(statements []ast.Stmt) {
+	for _, statement := range statements {
+		switch stmt := statement.(type) {
+		case *ast.Block:
+			ix.describe(stmt.Statements)
+		case *ast.Class:
+			detail := "class " + stmt.Name.Lexeme
+			if stmt.Superclass != nil {
+				detail += " < " + stmt.Superclass.Name.Lexeme
+			}
+			ix.set(stmt.Name, symbolClass, detail)
+			for _, method := range stmt.Methods {
+				ix.describeFunction(method)
+			}
+		case *ast.Function:
+			ix.set(stmt.Name, symbolFunction, signature(stmt.Name.Lexeme, stmt.Params))
+			ix.describeFunction(stmt)
+		case *ast.If:
+			ix.describe([]ast.Stmt{stmt.ThenBranch})
+			if stmt.ElseBranch != nil {
+				ix.describe([]ast.Stmt{stmt.ElseBranch})
+			}
+		case *ast.Import:
+			path := stmt.Path.Lexeme
+			if stmt.Alias.Lexeme != "" {
+				ix.set(stmt.Alias, symbolVariable, fmt.Sprintf("import %s as %s", path, stmt.Alias.Lexeme))
+			}
+			for _, name := range stmt.Names {
+				ix.set(name, symbolVariable, fmt.Sprintf("from %s import %s", path, name.Lexeme))
+			}
+		case *ast.Try:
+			ix.describe(stmt.Body.Statements)
+			if stmt.Catch != nil {
+				ix.set(stmt.Name, symbolVariable, fmt.Sprintf("catch (%s)", stmt.Name.Lexeme))
+				ix.describe(stmt.Catch.Statements)
+			}
+			if stmt.Finally != nil {
+				ix.describe(stmt.Finally.Statements)
+			}
+		case *ast.Var:
+			if lambda, ok := stmt.Initializer.(*ast.Lambda); ok {
+				ix.set(stmt.Name, symbolFunction, signature(stmt.Name.Lexeme, lambda.Function.Params))
+				ix.describeFunction(lambda.Function)
+			} else {
+				ix.set(stmt.Name, symbolVariable, "var "+stmt.Name.Lexeme)
+			}
+		case *ast.While:
+			ix.describe([]ast.Stmt{stmt.Body})
+		}
+	}
+}
+
+func (ix *index) describeFunction(function *ast.Function) {
+	for _, param := range function.Params {
+		ix.set(param, symbolVariable, "(parameter) "+param.Lexeme)
+	}
+	ix.describe(function.Body)
+}
+
+func (ix *index) set(name ast.Token, kind int, detail string) {
+	if declared, ok := ix.symbols[name.Offset]; ok {
+		declared.kind = kind
+		declared.detail = detail
+	}
+}
+
+func signature(name string, params []ast.Token) string {
+	names := make([]string, len(params))
+	for i, param := range params {
+		names[i] = param.Lexeme
+	}
+	return fmt.Sprintf("fun %s(%s)", name, strings.Join(names, ", "))
+}
+
+// lookup finds the name at offset, including when the cursor sits just
+// after it.
+func (ix *index) lookup(offset int) (occurrence, bool) {
+	i := sort.Search(len(ix.occurrences), func(i int) bool {
+		token := ix.occurrences[i].token
+		return token.Offset+token.Length >= offset
+	})
+	if i < len(ix.occurrences) && ix.occurrences[i].token.Offset <= offset {
+		return ix.occurrences[i], true
+	}
+	return occurrence{}, false
+}
+
+// documentSymbols lists the top-level declarations for the outline.
+func (ix *index) documentSymbols() []documentSymbol {
+	symbols := []documentSymbol{}
+	for _, statement := range ix.statements {
+		switch stmt := statement.(type) {
+		case *ast.Class:
+			class := ix.documentSymbol(stmt.Name, symbolClass, "")
+			for _, method := range stmt.Methods {
+				class.Children = append(class.Children, ix.documentSymbol(method.Name, symbolMethod, signature(method.Name.Lexeme, method.Params)))
+			}
+			symbols = append(symbols, class)
+		case *ast.Function:
+			symbols = append(symbols, ix.documentSymbol(stmt.Name, symbolFunction, signature(stmt.Name.Lexeme, stmt.Params)))
+		case *ast.Var:
+			if lambda, ok := stmt.Initializer.(*ast.Lambda); ok {
+				symbols = append(symbols, ix.documentSymbol(stmt.Name, symbolFunction, signature(stmt.Name.Lexeme, lambda.Function.Params)))
+			} else {
+				symbols = append(symbols, ix.documentSymbol(stmt.Name, symbolVariable, ""))
+			}
+		}
+	}
+	return symbols
+}
+
+func (ix *index) documentSymbol(name ast.Token, kind int, detail string) documentSymbol {
+	r := ix.text.tokenRange(name)
+	return documentSymbol{Name: name.Lexeme, Detail: detail, Kind: kind, Range: r, SelectionRange: r}
+}
@@ -0,0 +1,267 @@
+// Package lsp implements a Language Server Protocol server for Lox over a
+// pair of streams, normally stdin and stdout.
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/distolma/golox/cmd/myinterpreter/ast"
+	"github.com/distolma/golox/cmd/myinterpreter/framing"
+	logerror "github.com/distolma/golox/cmd/myinterpreter/log_error"
+	"github.com/distolma/golox/cmd/myinterpreter/scanner"
+)
+
+type Server struct {
+	in          *framing.Reader
+	out         io.Writer
+	documents   map[string]*document
+	initialized bool
+	shutdown    bool
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{in: framing.NewReader(in), out: out, documents: make(map[string]*document)}
+}
+
+// errExitWithoutShutdown is returned by Run when the client exits without
+// shutting the server down first, which the protocol treats as a failure.
+var errExitWithoutShutdown = errors.New("exit before shutdown")
+
+// Run serves requests until the client sends exit or closes the input.
+func (s *Server) Run() error {
+	for {
+		body, err := s.in.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			s.reply(nil, nil, &responseError{Code: codeParseError, Message: err.Error()})
+			continue
+		}
+
+		if req.Method == "exit" {
+			if !s.shutdown {
+				return errExitWithoutShutdown
+			}
+			return nil
+		}
+		s.handle(&req)
+	}
+}
+
+func (s *Server) write(message interface{}) {
+	body, err := json.Marshal(message)
+	if err != nil {
+		panic(err)
+	}
+	framing.Write(s.out, body)
+}
+
+func (s *Server) reply(id *json.RawMessage, result interface{}, err *responseError) {
+	if err != nil {
+		s.write(errorResponse{JSONRPC: "2.0", ID: id, Error: err})
+		return
+	}
+	s.write(response{JSONRPC: "2.0", ID: id, Result: result})
+}
+
+func (s *Server) notify(method string, params interface{}) {
+	s.write(notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func (s *Server) handle(req *request) {
+	// Notifications have no id and get no reply.
+	if req.ID == nil {
+		s.handleNotification(req)
+		return
+	}
+
+	if !s.initialized && req.Method != "initialize" {
+		s.reply(req.ID, nil, &responseError{Code: codeServerNotInitialized, Message: "Server not initialized."})
+		return
+	}
+
+	var result interface{}
+	var err *responseError
+	switch req.Method {
+	case "initialize":
+		s.initialized = true
+		result = initializeResult{
+			Capabilities: serverCapabilities{
+				TextDocumentSync:       syncFull,
+				DefinitionProvider:     true,
+				ReferencesProvider:     true,
+				HoverProvider:          true,
+				DocumentSymbolProvider: true,
+				RenameProvider:         true,
+			},
+			ServerInfo: serverInfo{Name: "golox"},
+		}
+	case "shutdown":
+		s.shutdown = true
+	case "textDocument/definition":
+		var params textDocumentPositionParams
+		if err = decode(req.Params, &params); err == nil {
+			result = s.definition(params)
+		}
+	case "textDocument/references":
+		var params referenceParams
+		if err = decode(req.Params, &params); err == nil {
+			result = s.references(params)
+		}
+	case "textDocument/hover":
+		var params textDocumentPositionParams
+		if err = decode(req.Params, &params); err == nil {
+			result = s.hover(params)
+		}
+	case "textDocument/documentSymbol":
+		var params documentSymbolParams
+		if err = decode(req.Params, &params); err == nil {
+			result = s.documentSymbols(params)
+		}
+	case "textDocument/rename":
+		var params renameParams
+		if err = decode(req.Params, &params); err == nil {
+			result, err = s.rename(params)
+		}
+	default:
+		err = &responseError{Code: codeMethodNotFound, Message: fmt.Sprintf("Method not found: %s.", req.Method)}
+	}
+
+	s.reply(req.ID, result, err)
+}
+
+func (s *Server) handleNotification(req *request) {
+	switch req.Method {
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if decode(req.Params, &params) == nil {
+			s.update(params.TextDocument.URI, params.TextDocument.Text)
+		}
+	case "textDocument/didChange":
+		var params didChangeParams
+		if decode(req.Params, &params) == nil && len(params.ContentChanges) > 0 {
+			// With full sync the last change holds the whole document.
+			s.update(params.TextDocument.URI, params.ContentChanges[len(params.ContentChanges)-1].Text)
+		}
+	case "textDocument/didClose":
+		var params didCloseParams
+		if decode(req.Params, &params) == nil {
+			delete(s.documents, params.TextDocument.URI)
+			s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: params.TextDocument.URI, Diagnostics: []diagnostic{}})
+		}
+	}
+}
+
+func decode(params json.RawMessage, v interface{}) *responseError {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// update reanalyzes a document and publishes its diagnostics.
+func (s *Server) update(uri string, content string) {
+	doc := analyze(uri, content, s.documents[uri])
+	s.documents[uri] = doc
+	s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: uri, Diagnostics: doc.diagnostics})
+}
+
+// lookup finds the name at a position in an open document.
+func (s *Server) lookup(params textDocumentPositionParams) (*index, occurrence, bool) {
+	doc, ok := s.documents[params.TextDocument.URI]
+	if !ok || doc.index == nil {
+		return nil, occurrence{}, false
+	}
+
+	found, ok := doc.index.lookup(doc.index.text.offset(params.Position))
+	return doc.index, found, ok
+}
+
+func (s *Server) definition(params textDocumentPositionParams) interface{} {
+	ix, found, ok := s.lookup(params)
+	if !ok {
+		return nil
+	}
+	return location{URI: params.TextDocument.URI, Range: ix.text.tokenRange(found.symbol.name)}
+}
+
+func (s *Server) references(params referenceParams) []location {
+	locations := []location{}
+	ix, found, ok := s.lookup(params.textDocumentPositionParams)
+	if !ok {
+		return locations
+	}
+
+	for _, reference := range found.symbol.references {
+		if !params.Context.IncludeDeclaration && reference.Offset == found.symbol.name.Offset {
+			continue
+		}
+		locations = append(locations, location{URI: params.TextDocument.URI, Range: ix.text.tokenRange(reference)})
+	}
+	return locations
+}
+
+func (s *Server) hover(params textDocumentPositionParams) interface{} {
+	ix, found, ok := s.lookup(params)
+	if !ok {
+		return nil
+	}
+
+	r := ix.text.tokenRange(found.token)
+	return hover{
+		Contents: markupContent{Kind: "markdown", Value: "```lox\n" + found.symbol.detail + "\n```"},
+		Range:    &r,
+	}
+}
+
+func (s *Server) documentSymbols(params documentSymbolParams) []documentSymbol {
+	doc, ok := s.documents[params.TextDocument.URI]
+	if !ok || doc.index == nil {
+		return []documentSymbol{}
+	}
+	return doc.index.documentSymbols()
+}
+
+func (s *Server) rename(params renameParams) (interface{}, *responseError) {
+	if !isIdentifier(params.NewName) {
+		return nil, &responseError{Code: codeRequestFailed, Message: fmt.Sprintf("'%s' is not a valid name.", params.NewName)}
+	}
+
+	// Renaming by the positions of an older version would corrupt the file.
+	if doc, ok := s.documents[params.TextDocument.URI]; ok && doc.index != nil && doc.index.text.content != doc.text.content {
+		return nil, &responseError{Code: codeRequestFailed, Message: "Can't rename while the file has syntax errors."}
+	}
+
+	ix, found, ok := s.lookup(params.textDocumentPositionParams)
+	if !ok {
+		return nil, nil
+	}
+
+	edits := []textEdit{}
+	for _, reference := range found.symbol.references {
+		edits = append(edits, textEdit{Range: ix.text.tokenRange(reference), NewText: params.NewName})
+	}
+	return workspaceEdit{Changes: map[string][]textEdit{params.TextDocument.URI: edits}}, nil
+}
+
+// isIdentifier reports whether name scans as a single identifier, which
+// rules out keywords.
+func isIdentifier(name string) bool {
+	if strings.TrimSpace(name) != name {
+		return false
+	}
+
+	log := &logerror.LogError{Output: io.Discard}
+	tokens := scanner.NewScanner(name, log).ScanTokens()
+	return !log.HadError && len(tokens) == 2 && tokens[0].Type == ast.TIdentifier && tokens[0].Lexeme == name
+}
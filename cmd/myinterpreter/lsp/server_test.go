@@ -0,0 +1,121 @@
+package lsp
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/distolma/golox/cmd/myinterpreter/framing"
+)
+
+// frame wraps each message in the Content-Length header the protocol uses.
+func frame(messages ...string) io.Reader {
+	var b bytes.Buffer
+	for _, message := range messages {
+		framing.Write(&b, []byte(message))
+	}
+	return &b
+}
+
+// serve runs a server over messages and returns what it wrote, one message
+// per element.
+func serve(t *testing.T, messages ...string) []map[string]json.RawMessage {
+	t.Helper()
+
+	var out bytes.Buffer
+	if err := NewServer(frame(messages...), &out).Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+
+	var replies []map[string]json.RawMessage
+	reader := framing.NewReader(&out)
+	for {
+		body, err := reader.Read()
+		if err == io.EOF {
+			return replies
+		}
+		if err != nil {
+			t.Fatalf("reading reply: %v", err)
+		}
+
+		var reply map[string]json.RawMessage
+		if err := json.Unmarshal(body, &reply); err != nil {
+			t.Fatalf("reply %s: %v", body, err)
+		}
+		replies = append(replies, reply)
+	}
+}
+
+const (
+	initialize = `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`
+	shutdown   = `{"jsonrpc":"2.0","id":99,"method":"shutdown"}`
+	exit       = `{"jsonrpc":"2.0","method":"exit"}`
+)
+
+func TestErrorResponseHasNoResult(t *testing.T) {
+	replies := serve(t,
+		`{"jsonrpc":"2.0","id":1,"method":"textDocument/hover","params":{}}`,
+		initialize,
+		`{"jsonrpc":"2.0","id":2,"method":"unknown/method"}`,
+		`{not json`,
+		shutdown,
+		exit,
+	)
+	if len(replies) != 5 {
+		t.Fatalf("got %d replies, want 5", len(replies))
+	}
+
+	for _, i := range []int{0, 2, 3} {
+		if _, ok := replies[i]["result"]; ok {
+			t.Errorf("error reply %d has a result: %v", i, replies[i])
+		}
+		if _, ok := replies[i]["error"]; !ok {
+			t.Errorf("error reply %d has no error: %v", i, replies[i])
+		}
+	}
+	for _, i := range []int{1, 4} {
+		if _, ok := replies[i]["error"]; ok {
+			t.Errorf("reply %d has an error: %v", i, replies[i])
+		}
+		if _, ok := replies[i]["result"]; !ok {
+			t.Errorf("reply %d has no result: %v", i, replies[i])
+		}
+	}
+}
+
+func TestDiagnosticsAndDefinition(t *testing.T) {
+	replies := serve(t,
+		initialize,
+		`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":"file:///a.lox","version":1,"text":"var x = 1;\nprint x;\n"}}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":"file:///b.lox","version":1,"text":"var y = 1;\n\nprint (;\n"}}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"textDocument/definition","params":{"textDocument":{"uri":"file:///a.lox"},"position":{"line":1,"character":6}}}`,
+		shutdown,
+		exit,
+	)
+	if len(replies) != 5 {
+		t.Fatalf("got %d replies, want 5", len(replies))
+	}
+
+	for i, want := range []int{0, 1} {
+		var diagnostics publishDiagnosticsParams
+		if err := json.Unmarshal(replies[1+i]["params"], &diagnostics); err != nil {
+			t.Fatal(err)
+		}
+		if len(diagnostics.Diagnostics) != want {
+			t.Errorf("diagnostics for %s = %+v, want %d", diagnostics.URI, diagnostics.Diagnostics, want)
+		}
+		if want > 0 && diagnostics.Diagnostics[0].Range.Start.Line != 2 {
+			t.Errorf("diagnostic for %s on line %d, want 2", diagnostics.URI, diagnostics.Diagnostics[0].Range.Start.Line)
+		}
+	}
+
+	var definition location
+	if err := json.Unmarshal(replies[3]["result"], &definition); err != nil {
+		t.Fatal(err)
+	}
+	want := textRange{Start: position{Line: 0, Character: 4}, End: position{Line: 0, Character: 5}}
+	if definition.Range != want {
+		t.Errorf("definition = %+v, want %+v", definition.Range, want)
+	}
+}
@@ -1,17 +1,27 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"fmt"
+	"net"
 	"os"
+	"path/filepath"
 	"slices"
+	"strings"
+	"time"
 
 	"github.com/distolma/golox/cmd/myinterpreter/ast"
+	"github.com/distolma/golox/cmd/myinterpreter/compiler"
+	"github.com/distolma/golox/cmd/myinterpreter/dap"
+	"github.com/distolma/golox/cmd/myinterpreter/formatter"
 	"github.com/distolma/golox/cmd/myinterpreter/interpreter"
+	"github.com/distolma/golox/cmd/myinterpreter/lint"
 	logerror "github.com/distolma/golox/cmd/myinterpreter/log_error"
+	"github.com/distolma/golox/cmd/myinterpreter/lsp"
 	"github.com/distolma/golox/cmd/myinterpreter/parser"
 	"github.com/distolma/golox/cmd/myinterpreter/resolver"
 	"github.com/distolma/golox/cmd/myinterpreter/scanner"
+	"github.com/distolma/golox/cmd/myinterpreter/vm"
 )
 
 const (
@@ -21,43 +31,152 @@ const (
 	ExitCodeRuntimeError = 70
 )
 
+const (
+	BackendTreeWalker = "tree"
+	BackendVM         = "vm"
+)
+
+// Formats of the syntax trees the parse command prints.
+const (
+	FormatSExpr = "sexpr"
+	FormatJSON  = "json"
+)
+
 type Lox struct {
 	log         *logerror.LogError
 	interpreter *interpreter.Interpreter
+	vm          *vm.VM
+	backend     string
+	searchPath  []string
+	ctx         context.Context
 }
 
-func NewLox() *Lox {
-	log := &logerror.LogError{}
-	interpreter := interpreter.NewInterpreter(log)
-
-	return &Lox{
-		log:         log,
-		interpreter: interpreter,
+func NewLox(backend string) *Lox {
+	l := &Lox{
+		log:     &logerror.LogError{},
+		backend: backend,
+		ctx:     context.Background(),
 	}
+	l.reset()
+	return l
+}
+
+// reset replaces both backends with fresh ones, forgetting every global
+// defined so far.
+func (l *Lox) reset() {
+	l.interpreter = interpreter.NewInterpreter(l.log)
+	l.interpreter.SetModuleLoader(l.loadModule)
+	l.interpreter.SetSearchPath(l.searchPath)
+
+	l.vm = vm.NewVM(l.log)
+	l.vm.SetModuleLoader(l.compileModule)
+	l.vm.SetSearchPath(l.searchPath)
 }
 
 func main() {
-	lox := NewLox()
+	args, options := parseArgs(os.Args[1:])
+
+	backend := BackendTreeWalker
+	if value, ok := options["backend"]; ok {
+		backend = value
+	}
+	if backend != BackendTreeWalker && backend != BackendVM {
+		fmt.Fprintf(os.Stderr, "Unknown backend: %s\n", backend)
+		os.Exit(ExitCodeUsage)
+	}
+
+	lox := NewLox(backend)
+
+	switch color := options["color"]; color {
+	case "", "auto":
+		lox.log.Color = isTerminal(os.Stderr)
+	case "always":
+		lox.log.Color = true
+	case "never":
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown color mode: %s\n", color)
+		os.Exit(ExitCodeUsage)
+	}
+
+	if value, ok := options["timeout"]; ok {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			fmt.Fprintf(os.Stderr, "Invalid timeout: %s\n", value)
+			os.Exit(ExitCodeUsage)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		lox.ctx = ctx
+	}
+
+	switch format := options["diagnostics"]; format {
+	case "", logerror.FormatText:
+	case logerror.FormatJSON, logerror.FormatSARIF:
+		lox.log.Format = format
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown diagnostics format: %s\n", format)
+		os.Exit(ExitCodeUsage)
+	}
+
+	if value, ok := options["path"]; ok {
+		lox.searchPath = filepath.SplitList(value)
+		lox.interpreter.SetSearchPath(lox.searchPath)
+		lox.vm.SetSearchPath(lox.searchPath)
+	}
+
+	// Errors held back by the diagnostics format are written on the way out.
+	defer lox.log.Flush()
+
+	if len(args) == 1 && args[0] == "lsp" {
+		if err := lsp.NewServer(os.Stdin, os.Stdout).Run(); err != nil {
+			fmt.Fprintf(os.Stderr, "lsp: %v\n", err)
+			lox.exitWith(ExitError)
+		}
+		return
+	}
+
+	if len(args) == 1 && args[0] == "debug" {
+		if err := debug(options); err != nil {
+			fmt.Fprintf(os.Stderr, "debug: %v\n", err)
+			lox.exitWith(ExitError)
+		}
+		return
+	}
 
-	if len(os.Args) < 3 {
+	if len(args) < 2 {
 		lox.runPrompt()
 		return
 	}
 
-	command := os.Args[1]
-	filename := os.Args[2]
+	command := args[0]
+	filename := args[1]
 
-	validCommands := []string{"tokenize", "parse", "evaluate", "run"}
+	validCommands := []string{"tokenize", "parse", "evaluate", "run", "disassemble", "compile", "fmt", "lint"}
 	if slices.Contains(validCommands, command) {
 		switch command {
 		case "tokenize":
 			lox.tokenize(filename)
 		case "parse":
-			lox.parse(filename)
+			lox.parse(filename, options["format"])
 		case "evaluate":
 			lox.evaluate(filename)
 		case "run":
 			lox.runFile(filename)
+		case "disassemble":
+			lox.disassemble(filename)
+		case "compile":
+			lox.compileFile(filename, options["o"])
+		case "fmt":
+			_, check := options["check"]
+			_, write := options["write"]
+			lox.format(args[1:], check, write)
+		case "lint":
+			var disabled []string
+			if value, ok := options["disable"]; ok {
+				disabled = strings.Split(value, ",")
+			}
+			lox.lint(args[1:], disabled)
 		default:
 			lox.runFile(filename)
 		}
@@ -65,38 +184,125 @@ func main() {
 	}
 }
 
-func (l *Lox) runPrompt() {
-	inputScanner := bufio.NewScanner(os.Stdin)
-	for {
-		fmt.Print("> ")
-		if !inputScanner.Scan() {
-			break
+// isTerminal reports whether colour output to f would be seen on a terminal
+// that wants it, honouring the NO_COLOR convention.
+func isTerminal(f *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// debug serves the Debug Adapter Protocol on stdin and stdout, or with
+// --port on a single connection to that port on the loopback interface.
+func debug(options map[string]string) error {
+	var searchPath []string
+	if value, ok := options["path"]; ok {
+		searchPath = filepath.SplitList(value)
+	}
+
+	port, ok := options["port"]
+	if !ok {
+		server := dap.NewServer(os.Stdin, os.Stdout)
+		server.SetSearchPath(searchPath)
+		return server.Run()
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", port))
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	fmt.Fprintf(os.Stderr, "Listening on %s\n", listener.Addr())
+
+	conn, err := listener.Accept()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	server := dap.NewServer(conn, conn)
+	server.SetSearchPath(searchPath)
+	return server.Run()
+}
+
+// parseArgs splits the command line into positional arguments,
+// "--name=value" options and short "-n value" options.
+func parseArgs(arguments []string) ([]string, map[string]string) {
+	var args []string
+	options := make(map[string]string)
+
+	for i := 0; i < len(arguments); i++ {
+		argument := arguments[i]
+
+		switch {
+		case strings.HasPrefix(argument, "--"):
+			name, value, _ := strings.Cut(strings.TrimPrefix(argument, "--"), "=")
+			options[name] = value
+		case strings.HasPrefix(argument, "-") && len(argument) > 1 && i+1 < len(arguments):
+			options[strings.TrimPrefix(argument, "-")] = arguments[i+1]
+			i++
+		default:
+			args = append(args, argument)
 		}
-		line := inputScanner.Text()
-		l.run(line)
-		l.log.HadError = false
 	}
+
+	return args, options
 }
 
 func (l *Lox) runFile(path string) {
+	if err := l.setScriptPath(path); err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
+		os.Exit(ExitError)
+	}
+
+	if filepath.Ext(path) == CompiledExt {
+		l.runCompiled(path)
+		return
+	}
+
 	file, err := os.ReadFile(path)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
 		os.Exit(ExitError)
 	}
-	l.run(string(file))
 
+	if l.backend == BackendVM {
+		if function := l.compileCached(path, string(file)); function != nil {
+			l.vm.Interpret(l.ctx, function)
+		}
+		l.exit()
+		return
+	}
+
+	l.run(path, string(file))
+
+	l.exit()
+}
+
+// exit terminates the process with the status matching the errors reported
+// so far, if any.
+func (l *Lox) exit() {
 	if l.log.HadError {
-		os.Exit(ExitCodeSyntaxError)
+		l.exitWith(ExitCodeSyntaxError)
 	}
 
 	if l.log.HadRuntimeError {
-		os.Exit(ExitCodeRuntimeError)
+		l.exitWith(ExitCodeRuntimeError)
 	}
 }
 
-func (l *Lox) run(source string) {
-	scanner := scanner.NewScanner(source, l.log)
+// exitWith writes any held back errors and terminates the process.
+func (l *Lox) exitWith(code int) {
+	l.log.Flush()
+	os.Exit(code)
+}
+
+// run runs source, which was read from the file name if it isn't empty.
+func (l *Lox) run(name string, source string) {
+	scanner := scanner.NewFileScanner(name, source, l.log)
 	tokens := scanner.ScanTokens()
 
 	parser := parser.NewParser(tokens, l.log)
@@ -113,7 +319,63 @@ func (l *Lox) run(source string) {
 		return
 	}
 
-	l.interpreter.Interpret(statements)
+	if l.backend == BackendVM {
+		function := compiler.NewCompiler(l.log).Compile(statements)
+
+		if l.log.HadError {
+			return
+		}
+
+		l.vm.Interpret(l.ctx, function)
+		return
+	}
+
+	l.interpreter.Interpret(l.ctx, statements)
+}
+
+// setScriptPath tells the backend in use which file imports are resolved
+// against.
+func (l *Lox) setScriptPath(path string) error {
+	if l.backend == BackendVM {
+		return l.vm.SetScriptPath(path)
+	}
+	return l.interpreter.SetScriptPath(path)
+}
+
+// loadModule scans, parses and resolves an imported file for the tree-walker.
+func (l *Lox) loadModule(path string) ([]ast.Stmt, bool) {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
+		return nil, false
+	}
+
+	scanner := scanner.NewFileScanner(path, string(file), l.log)
+	tokens := scanner.ScanTokens()
+
+	parser := parser.NewParser(tokens, l.log)
+	statements := parser.Parse()
+
+	if l.log.HadError {
+		return nil, false
+	}
+
+	resolver := resolver.NewResolver(l.interpreter, l.log)
+	resolver.ResolveStmts(statements)
+
+	return statements, !l.log.HadError
+}
+
+// compileModule compiles an imported file for the VM.
+func (l *Lox) compileModule(path string) (*compiler.Function, bool) {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
+		return nil, false
+	}
+
+	function := l.compileSource(path, string(file))
+	return function, function != nil
 }
 
 func (l *Lox) tokenize(path string) {
@@ -124,7 +386,7 @@ func (l *Lox) tokenize(path string) {
 	}
 	source := string(file)
 
-	scan := scanner.NewScanner(source, l.log)
+	scan := scanner.NewFileScanner(path, source, l.log)
 	tokens := scan.ScanTokens()
 
 	for _, token := range tokens {
@@ -132,11 +394,24 @@ func (l *Lox) tokenize(path string) {
 	}
 
 	if l.log.HadError {
-		os.Exit(ExitCodeSyntaxError)
+		l.exitWith(ExitCodeSyntaxError)
 	}
 }
 
-func (l *Lox) parse(path string) {
+// parse prints the syntax tree of the file, as an S-expression or with format
+// "json" as JSON. A file holding a single expression prints just that
+// expression; anything else is parsed as a program and resolved, so that
+// JSON output carries the scope depth of each local variable.
+func (l *Lox) parse(path string, format string) {
+	switch format {
+	case "":
+		format = FormatSExpr
+	case FormatSExpr, FormatJSON:
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown format: %s\n", format)
+		os.Exit(ExitCodeUsage)
+	}
+
 	file, err := os.ReadFile(path)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
@@ -144,23 +419,142 @@ func (l *Lox) parse(path string) {
 	}
 	source := string(file)
 
-	scan := scanner.NewScanner(source, l.log)
+	scan := scanner.NewFileScanner(path, source, l.log)
 	tokens := scan.ScanTokens()
 
 	if l.log.HadError {
-		os.Exit(ExitCodeSyntaxError)
+		l.exitWith(ExitCodeSyntaxError)
 	}
 
 	parser := parser.NewParser(tokens, l.log)
-	expression := parser.ParseExpression()
+	if parser.IsExpression() {
+		expression := parser.ParseExpression()
+		if format == FormatJSON {
+			printer := ast.JSONPrinter{}
+			l.printJSON(printer.PrintExpression(expression))
+			return
+		}
 
+		printer := ast.AstPrinter{}
+		result := printer.PrintExpression(expression)
+		fmt.Println(result)
+		return
+	}
+
+	statements := parser.Parse()
 	if l.log.HadError {
-		os.Exit(ExitCodeSyntaxError)
+		l.exitWith(ExitCodeSyntaxError)
+	}
+
+	resolver := resolver.NewResolver(l.interpreter, l.log)
+	resolver.ResolveStmts(statements)
+	if l.log.HadError {
+		l.exitWith(ExitCodeSyntaxError)
+	}
+
+	if format == FormatJSON {
+		printer := ast.JSONPrinter{Depth: l.interpreter.Depth}
+		l.printJSON(printer.Print(path, statements))
+		return
 	}
 
 	printer := ast.AstPrinter{}
-	result := printer.PrintExpression(expression)
-	fmt.Println(result)
+	fmt.Print(printer.Print(statements))
+}
+
+func (l *Lox) printJSON(data []byte, err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error encoding syntax tree: %v\n", err)
+		l.exitWith(ExitError)
+	}
+	fmt.Println(string(data))
+}
+
+// format prints each file in canonical layout. With check it lists the files
+// whose layout differs instead and fails if there are any; with write it
+// rewrites them in place.
+func (l *Lox) format(paths []string, check bool, write bool) {
+	unformatted := false
+	for _, path := range paths {
+		file, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
+			os.Exit(ExitError)
+		}
+		source := string(file)
+
+		tokens := scanner.NewFileScanner(path, source, l.log).ScanTokens()
+		statements := parser.NewParser(tokens, l.log).Parse()
+		if l.log.HadError {
+			continue
+		}
+
+		formatted := formatter.Format(tokens, statements)
+		switch {
+		case check:
+			if formatted != source {
+				fmt.Println(path)
+				unformatted = true
+			}
+		case write:
+			if formatted != source {
+				if err := os.WriteFile(path, []byte(formatted), 0o644); err != nil {
+					fmt.Fprintf(os.Stderr, "Error writing file: %v\n", err)
+					os.Exit(ExitError)
+				}
+			}
+		default:
+			fmt.Print(formatted)
+		}
+	}
+
+	if l.log.HadError {
+		l.exitWith(ExitCodeSyntaxError)
+	}
+	if unformatted {
+		l.exitWith(ExitError)
+	}
+}
+
+// lint reports likely mistakes in each file, failing if it finds any that
+// aren't ignored. The rules named in disabled are skipped.
+func (l *Lox) lint(paths []string, disabled []string) {
+	linter := lint.NewLinter(l.log)
+	for _, id := range disabled {
+		if err := linter.Disable(strings.TrimSpace(id)); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(ExitCodeUsage)
+		}
+	}
+
+	findings := 0
+	for _, path := range paths {
+		file, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
+			os.Exit(ExitError)
+		}
+
+		tokens := scanner.NewFileScanner(path, string(file), l.log).ScanTokens()
+		statements := parser.NewParser(tokens, l.log).Parse()
+		if l.log.HadError {
+			continue
+		}
+
+		resolver.NewResolver(l.interpreter, l.log).ResolveStmts(statements)
+		if l.log.HadError {
+			continue
+		}
+
+		findings += linter.Lint(tokens, statements)
+	}
+
+	if l.log.HadError {
+		l.exitWith(ExitCodeSyntaxError)
+	}
+	if findings > 0 {
+		l.exitWith(ExitError)
+	}
 }
 
 func (l *Lox) evaluate(path string) {
@@ -171,11 +565,11 @@ func (l *Lox) evaluate(path string) {
 	}
 	source := string(file)
 
-	scan := scanner.NewScanner(source, l.log)
+	scan := scanner.NewFileScanner(path, source, l.log)
 	tokens := scan.ScanTokens()
 
 	if l.log.HadError {
-		os.Exit(ExitCodeSyntaxError)
+		l.exitWith(ExitCodeSyntaxError)
 	}
 
 	parser := parser.NewParser(tokens, l.log)
@@ -183,7 +577,28 @@ func (l *Lox) evaluate(path string) {
 
 	value := l.interpreter.InterpretExpression(expression)
 	if l.log.HadRuntimeError {
-		os.Exit(ExitCodeRuntimeError)
+		l.exitWith(ExitCodeRuntimeError)
 	}
 	fmt.Println(value)
 }
+
+func (l *Lox) disassemble(path string) {
+	var function *compiler.Function
+
+	if filepath.Ext(path) == CompiledExt {
+		function = l.readArtifact(path).Function
+	} else {
+		file, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
+			os.Exit(ExitError)
+		}
+
+		function = l.compileSource(path, string(file))
+		if function == nil {
+			l.exitWith(ExitCodeSyntaxError)
+		}
+	}
+
+	fmt.Print(compiler.Disassemble(function))
+}
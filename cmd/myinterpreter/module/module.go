@@ -0,0 +1,79 @@
+package module
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Resolve finds the file named by an import in importer, which is empty for
+// code that doesn't come from a file. Relative paths are tried against the
+// directory of importer and then each directory of searchPath in order.
+func Resolve(path, importer string, searchPath []string) (string, error) {
+	var candidates []string
+	if filepath.IsAbs(path) {
+		candidates = []string{path}
+	} else {
+		base := "."
+		if importer != "" {
+			base = filepath.Dir(importer)
+		}
+		candidates = append(candidates, filepath.Join(base, path))
+		for _, directory := range searchPath {
+			candidates = append(candidates, filepath.Join(directory, path))
+		}
+	}
+
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return filepath.Abs(candidate)
+		}
+	}
+	return "", fmt.Errorf("Can't find module '%s'.", path)
+}
+
+// Registry runs each module once and detects import cycles. Modules are kept
+// as interface{} so that both backends can store their own representation.
+type Registry struct {
+	modules map[string]interface{}
+	loading []string
+}
+
+func NewRegistry() *Registry {
+	return &Registry{modules: make(map[string]interface{})}
+}
+
+// Enter marks the file at path, which must be absolute, as loading. The main
+// script is entered before it runs so that importing it is reported as a
+// cycle.
+func (r *Registry) Enter(path string) error {
+	for i, loading := range r.loading {
+		if loading == path {
+			cycle := append(append([]string(nil), r.loading[i:]...), path)
+			for j := range cycle {
+				cycle[j] = filepath.Base(cycle[j])
+			}
+			return fmt.Errorf("Import cycle: %s.", strings.Join(cycle, " -> "))
+		}
+	}
+
+	r.loading = append(r.loading, path)
+	return nil
+}
+
+// Exit leaves the file entered last and records module as its result, unless
+// module is nil because loading failed.
+func (r *Registry) Exit(module interface{}) {
+	path := r.loading[len(r.loading)-1]
+	r.loading = r.loading[:len(r.loading)-1]
+	if module != nil {
+		r.modules[path] = module
+	}
+}
+
+// Lookup returns the module already loaded from path.
+func (r *Registry) Lookup(path string) (interface{}, bool) {
+	module, ok := r.modules[path]
+	return module, ok
+}
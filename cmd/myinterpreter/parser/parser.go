@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"io"
 
 	"github.com/distolma/golox/cmd/myinterpreter/ast"
 	logerror "github.com/distolma/golox/cmd/myinterpreter/log_error"
@@ -18,6 +19,7 @@ func NewParser(tokens []ast.Token, log *logerror.LogError) *Parser {
 }
 
 func (p *Parser) Parse() []ast.Stmt {
+	p.log.Phase = logerror.PhaseParse
 	var statements []ast.Stmt
 
 	defer func() {
@@ -37,7 +39,21 @@ func (p *Parser) Parse() []ast.Stmt {
 	return statements
 }
 
+// IsExpression reports whether the tokens hold a single expression rather
+// than a program. Nothing is reported to the log.
+func (p *Parser) IsExpression() bool {
+	log, current := p.log, p.current
+	defer func() {
+		p.log, p.current = log, current
+	}()
+
+	p.log = &logerror.LogError{Output: io.Discard}
+	p.ParseExpression()
+	return !p.log.HadError && p.isAtEnd()
+}
+
 func (p *Parser) ParseExpression() ast.Expr {
+	p.log.Phase = logerror.PhaseParse
 	defer func() {
 		if err := recover(); err != nil {
 			if _, ok := err.(ParseError); ok {
@@ -66,7 +82,13 @@ func (p *Parser) declaration() ast.Stmt {
 		}
 	}()
 
-	if p.match(ast.TFun) {
+	if p.match(ast.TClass) {
+		return p.classDeclaration()
+	}
+
+	// A 'fun' without a name starts an anonymous function expression.
+	if p.check(ast.TFun) && !p.checkNext(ast.TLeftParen) {
+		p.advance()
 		return p.function("function")
 	}
 
@@ -74,10 +96,47 @@ func (p *Parser) declaration() ast.Stmt {
 		return p.varDeclaration()
 	}
 
+	if p.match(ast.TImport) {
+		return p.importDeclaration()
+	}
+
+	// 'from' is only a keyword at the start of a selective import, so it
+	// stays usable as a name elsewhere.
+	if p.checkContextual("from") && p.checkNext(ast.TString) {
+		return p.fromImportDeclaration()
+	}
+
 	return p.statement()
 }
 
+func (p *Parser) classDeclaration() ast.Stmt {
+	name := p.consume(ast.TIdentifier, "Expect class name.")
+
+	var superclass *ast.Variable
+	if p.match(ast.TLess) {
+		p.consume(ast.TIdentifier, "Expect superclass name.")
+		superclass = &ast.Variable{Name: p.previous()}
+	}
+
+	p.consume(ast.TLeftBrace, "Expect '{' before class body.")
+
+	var methods []*ast.Function
+	for !p.check(ast.TRightBrace) && !p.isAtEnd() {
+		methods = append(methods, p.function("method"))
+	}
+
+	brace := p.consume(ast.TRightBrace, "Expect '}' after class body.")
+
+	return &ast.Class{Name: name, Superclass: superclass, Methods: methods, Brace: brace}
+}
+
 func (p *Parser) statement() ast.Stmt {
+	if p.match(ast.TBreak) {
+		return p.breakStatement()
+	}
+	if p.match(ast.TContinue) {
+		return p.continueStatement()
+	}
 	if p.match(ast.TFor) {
 		return p.forStatement()
 	}
@@ -90,16 +149,23 @@ func (p *Parser) statement() ast.Stmt {
 	if p.match(ast.TReturn) {
 		return p.returnStatement()
 	}
+	if p.match(ast.TThrow) {
+		return p.throwStatement()
+	}
+	if p.match(ast.TTry) {
+		return p.tryStatement()
+	}
 	if p.match(ast.TWhile) {
 		return p.whileStatement()
 	}
 	if p.match(ast.TLeftBrace) {
-		return &ast.Block{Statements: p.block()}
+		return p.blockStatement()
 	}
 	return p.expressionStatement()
 }
 
 func (p *Parser) forStatement() ast.Stmt {
+	keyword := p.previous()
 	p.consume(ast.TLeftParen, "Expect '(' after 'for'.")
 
 	var initializer ast.Stmt
@@ -125,14 +191,12 @@ func (p *Parser) forStatement() ast.Stmt {
 
 	body := p.statement()
 
-	if increment != nil {
-		body = &ast.Block{Statements: []ast.Stmt{body, &ast.Expression{Expression: increment}}}
-	}
-
+	// The increment is kept apart from the body so that 'continue' still
+	// runs it.
 	if condition == nil {
-		condition = &ast.Literal{Value: true}
+		condition = &ast.Literal{Token: keyword, Value: true}
 	}
-	body = &ast.While{Condition: condition, Body: body}
+	body = &ast.While{Keyword: keyword, Condition: condition, Body: body, Increment: increment}
 
 	if initializer != nil {
 		body = &ast.Block{Statements: []ast.Stmt{initializer, body}}
@@ -141,6 +205,18 @@ func (p *Parser) forStatement() ast.Stmt {
 	return body
 }
 
+func (p *Parser) breakStatement() ast.Stmt {
+	keyword := p.previous()
+	p.consume(ast.TSemicolon, "Expect ';' after 'break'.")
+	return &ast.Break{Keyword: keyword}
+}
+
+func (p *Parser) continueStatement() ast.Stmt {
+	keyword := p.previous()
+	p.consume(ast.TSemicolon, "Expect ';' after 'continue'.")
+	return &ast.Continue{Keyword: keyword}
+}
+
 func (p *Parser) ifStatement() ast.Stmt {
 	p.consume(ast.TLeftParen, "Expect '(' after 'if'.")
 	condition := p.expression()
@@ -156,9 +232,10 @@ func (p *Parser) ifStatement() ast.Stmt {
 }
 
 func (p *Parser) printStatement() ast.Stmt {
+	keyword := p.previous()
 	expr := p.expression()
 	p.consume(ast.TSemicolon, "Expect ';' after value.")
-	return &ast.Print{Expression: expr}
+	return &ast.Print{Keyword: keyword, Expression: expr}
 }
 
 func (p *Parser) returnStatement() ast.Stmt {
@@ -171,6 +248,39 @@ func (p *Parser) returnStatement() ast.Stmt {
 	return &ast.Return{Keyword: keyword, Value: value}
 }
 
+func (p *Parser) throwStatement() ast.Stmt {
+	keyword := p.previous()
+	value := p.expression()
+	p.consume(ast.TSemicolon, "Expect ';' after thrown value.")
+	return &ast.Throw{Keyword: keyword, Value: value}
+}
+
+func (p *Parser) tryStatement() ast.Stmt {
+	stmt := &ast.Try{Keyword: p.previous()}
+
+	p.consume(ast.TLeftBrace, "Expect '{' after 'try'.")
+	stmt.Body = p.blockStatement()
+
+	if p.match(ast.TCatch) {
+		p.consume(ast.TLeftParen, "Expect '(' after 'catch'.")
+		stmt.Name = p.consume(ast.TIdentifier, "Expect exception variable name.")
+		p.consume(ast.TRightParen, "Expect ')' after exception variable name.")
+		p.consume(ast.TLeftBrace, "Expect '{' before catch body.")
+		stmt.Catch = p.blockStatement()
+	}
+
+	if p.match(ast.TFinally) {
+		p.consume(ast.TLeftBrace, "Expect '{' after 'finally'.")
+		stmt.Finally = p.blockStatement()
+	}
+
+	if stmt.Catch == nil && stmt.Finally == nil {
+		p.error(p.peek(), "Expect 'catch' or 'finally' after try block.")
+	}
+
+	return stmt
+}
+
 func (p *Parser) varDeclaration() ast.Stmt {
 	name := p.consume(ast.TIdentifier, "Expect variable name.")
 
@@ -183,13 +293,45 @@ func (p *Parser) varDeclaration() ast.Stmt {
 	return &ast.Var{Name: name, Initializer: initializer}
 }
 
+func (p *Parser) importDeclaration() ast.Stmt {
+	keyword := p.previous()
+	path := p.consume(ast.TString, "Expect module path after 'import'.")
+
+	if !p.checkContextual("as") {
+		p.error(p.peek(), "Expect 'as' after module path.")
+	}
+	p.advance()
+
+	alias := p.consume(ast.TIdentifier, "Expect module name after 'as'.")
+	p.consume(ast.TSemicolon, "Expect ';' after import.")
+	return &ast.Import{Keyword: keyword, Path: path, Alias: alias}
+}
+
+func (p *Parser) fromImportDeclaration() ast.Stmt {
+	keyword := p.advance()
+	path := p.consume(ast.TString, "Expect module path after 'from'.")
+	p.consume(ast.TImport, "Expect 'import' after module path.")
+
+	var names []ast.Token
+	for {
+		names = append(names, p.consume(ast.TIdentifier, "Expect name to import."))
+		if !p.match(ast.TComma) {
+			break
+		}
+	}
+
+	p.consume(ast.TSemicolon, "Expect ';' after import.")
+	return &ast.Import{Keyword: keyword, Path: path, Names: names}
+}
+
 func (p *Parser) whileStatement() ast.Stmt {
+	keyword := p.previous()
 	p.consume(ast.TLeftParen, "Expect '(' after 'while'.")
 	condition := p.expression()
 	p.consume(ast.TRightParen, "Expect ')' after condition.")
 	body := p.statement()
 
-	return &ast.While{Condition: condition, Body: body}
+	return &ast.While{Keyword: keyword, Condition: condition, Body: body}
 }
 
 func (p *Parser) expressionStatement() ast.Stmt {
@@ -202,7 +344,16 @@ func (p *Parser) function(kind string) *ast.Function {
 	name := p.consume(ast.TIdentifier, fmt.Sprintf("Expect %s name.", kind))
 
 	p.consume(ast.TLeftParen, fmt.Sprintf("Expact '(' after %s name.", kind))
+	parameters := p.parameters()
 
+	p.consume(ast.TLeftBrace, fmt.Sprintf("Expect '{' before %s body.", kind))
+	body := p.block()
+
+	return &ast.Function{Name: name, Params: parameters, Body: body, Brace: p.previous()}
+}
+
+// parameters parses a parameter list up to and including the closing paren.
+func (p *Parser) parameters() []ast.Token {
 	var parameters []ast.Token
 	if !p.check(ast.TRightParen) {
 		for {
@@ -222,10 +373,7 @@ func (p *Parser) function(kind string) *ast.Function {
 
 	p.consume(ast.TRightParen, "Expect ')' after parameters.")
 
-	p.consume(ast.TLeftBrace, fmt.Sprintf("Expect '{' before %s body.", kind))
-	body := p.block()
-
-	return &ast.Function{Name: name, Params: parameters, Body: body}
+	return parameters
 }
 
 func (p *Parser) block() []ast.Stmt {
@@ -240,6 +388,12 @@ func (p *Parser) block() []ast.Stmt {
 	return statements
 }
 
+// blockStatement parses the statements of a block after its '{'.
+func (p *Parser) blockStatement() *ast.Block {
+	statements := p.block()
+	return &ast.Block{Statements: statements, Brace: p.previous()}
+}
+
 func (p *Parser) assignment() ast.Expr {
 	expr := p.or()
 
@@ -251,6 +405,14 @@ func (p *Parser) assignment() ast.Expr {
 			return &ast.Assign{Name: varExpr.Name, Value: value}
 		}
 
+		if getExpr, ok := expr.(*ast.Get); ok {
+			return &ast.Set{Object: getExpr.Object, Name: getExpr.Name, Value: value}
+		}
+
+		if indexExpr, ok := expr.(*ast.Index); ok {
+			return &ast.SetIndex{Object: indexExpr.Object, Bracket: indexExpr.Bracket, Index: indexExpr.Index, Value: value}
+		}
+
 		p.error(equals, "Invalid assignment target.")
 	}
 
@@ -372,6 +534,13 @@ func (p *Parser) call() ast.Expr {
 	for {
 		if p.match(ast.TLeftParen) {
 			expr = p.finishCall(expr)
+		} else if p.match(ast.TDot) {
+			name := p.consume(ast.TIdentifier, "Expect property name after '.'.")
+			expr = &ast.Get{Object: expr, Name: name}
+		} else if p.match(ast.TLeftBracket) {
+			index := p.expression()
+			bracket := p.consume(ast.TRightBracket, "Expect ']' after index.")
+			expr = &ast.Index{Object: expr, Bracket: bracket, Index: index}
 		} else {
 			break
 		}
@@ -382,25 +551,128 @@ func (p *Parser) call() ast.Expr {
 
 func (p *Parser) primary() ast.Expr {
 	if p.match(ast.TFalse) {
-		return &ast.Literal{Value: false}
+		return &ast.Literal{Token: p.previous(), Value: false}
 	} else if p.match(ast.TTrue) {
-		return &ast.Literal{Value: true}
+		return &ast.Literal{Token: p.previous(), Value: true}
 	} else if p.match(ast.TNil) {
-		return &ast.Literal{Value: nil}
+		return &ast.Literal{Token: p.previous(), Value: nil}
 	} else if p.match(ast.TNumber, ast.TString) {
-		return &ast.Literal{Value: p.previous().Literal}
+		return &ast.Literal{Token: p.previous(), Value: p.previous().Literal}
+	} else if p.match(ast.TSuper) {
+		keyword := p.previous()
+		p.consume(ast.TDot, "Expect '.' after 'super'.")
+		method := p.consume(ast.TIdentifier, "Expect superclass method name.")
+		return &ast.Super{Keyword: keyword, Method: method}
+	} else if p.match(ast.TThis) {
+		return &ast.This{Keyword: p.previous()}
 	} else if p.match(ast.TIdentifier) {
 		return &ast.Variable{Name: p.previous()}
+	} else if p.match(ast.TFun) {
+		return p.lambda()
+	} else if p.check(ast.TLeftParen) && p.isArrowFunction() {
+		return p.arrowFunction()
 	} else if p.match(ast.TLeftParen) {
 		expr := p.expression()
 		p.consume(ast.TRightParen, "Expect ')' after expression.")
 		return &ast.Grouping{Expression: expr}
+	} else if p.match(ast.TLeftBracket) {
+		return p.list()
+	} else if p.match(ast.TLeftBrace) {
+		// A brace starting a statement is a block, so one reaching an
+		// expression must start a map.
+		return p.mapLiteral()
 	}
 
 	p.error(p.peek(), "Expect expression.")
 	return nil
 }
 
+// lambda parses an anonymous function after its 'fun' keyword. Its name
+// token is empty but keeps the line for error reporting.
+func (p *Parser) lambda() ast.Expr {
+	keyword := p.previous()
+	p.consume(ast.TLeftParen, "Expect '(' after 'fun'.")
+	parameters := p.parameters()
+
+	p.consume(ast.TLeftBrace, "Expect '{' before function body.")
+	body := p.block()
+	brace := p.previous()
+
+	name := ast.Token{Line: keyword.Line}
+	return &ast.Lambda{Keyword: keyword, Function: &ast.Function{Name: name, Params: parameters, Body: body, Brace: brace}}
+}
+
+// isArrowFunction looks past a parenthesized list of names for '=>', which
+// tells an arrow function apart from a grouping.
+func (p *Parser) isArrowFunction() bool {
+	i := p.current + 1
+	for p.tokens[i].Type == ast.TIdentifier {
+		i++
+		if p.tokens[i].Type != ast.TComma {
+			break
+		}
+		i++
+	}
+	return p.tokens[i].Type == ast.TRightParen && p.tokens[i+1].Type == ast.TArrow
+}
+
+// arrowFunction parses '(a, b) => expr', whose body returns expr. A brace
+// after the arrow starts a block body, as with 'fun', so a map has to be
+// parenthesized to be returned.
+func (p *Parser) arrowFunction() ast.Expr {
+	paren := p.advance()
+	parameters := p.parameters()
+	arrow := p.consume(ast.TArrow, "Expect '=>' after parameters.")
+
+	var body []ast.Stmt
+	var brace ast.Token
+	if p.match(ast.TLeftBrace) {
+		body = p.block()
+		brace = p.previous()
+	} else {
+		body = []ast.Stmt{&ast.Return{Keyword: arrow, Value: p.assignment()}}
+	}
+
+	name := ast.Token{Line: paren.Line}
+	return &ast.Lambda{Keyword: arrow, Function: &ast.Function{Name: name, Params: parameters, Body: body, Brace: brace}}
+}
+
+func (p *Parser) list() ast.Expr {
+	var elements []ast.Expr
+
+	if !p.check(ast.TRightBracket) {
+		for {
+			elements = append(elements, p.expression())
+
+			if !p.match(ast.TComma) || p.check(ast.TRightBracket) {
+				break
+			}
+		}
+	}
+
+	bracket := p.consume(ast.TRightBracket, "Expect ']' after list elements.")
+	return &ast.List{Bracket: bracket, Elements: elements}
+}
+
+func (p *Parser) mapLiteral() ast.Expr {
+	var keys, values []ast.Expr
+
+	if !p.check(ast.TRightBrace) {
+		for {
+			keys = append(keys, p.expression())
+			p.consume(ast.TColon, "Expect ':' after map key.")
+			values = append(values, p.expression())
+
+			if !p.match(ast.TComma) || p.check(ast.TRightBrace) {
+				break
+			}
+		}
+	}
+
+	brace := p.consume(ast.TRightBrace, "Expect '}' after map entries.")
+	return &ast.Map{Brace: brace, Keys: keys, Values: values}
+}
+
 func (p *Parser) synchronize() {
 	p.advance()
 
@@ -22,6 +22,19 @@ func (p *Parser) check(t ast.TokenType) bool {
 	return p.peek().Type == t
 }
 
+// checkContextual reports whether the next token is the identifier word,
+// which acts as a keyword only in some positions.
+func (p *Parser) checkContextual(word string) bool {
+	return p.check(ast.TIdentifier) && p.peek().Lexeme == word
+}
+
+func (p *Parser) checkNext(t ast.TokenType) bool {
+	if p.isAtEnd() {
+		return false
+	}
+	return p.tokens[p.current+1].Type == t
+}
+
 func (p *Parser) advance() ast.Token {
 	if !p.isAtEnd() {
 		p.current++
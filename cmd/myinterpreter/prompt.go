@@ -0,0 +1,343 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/distolma/golox/cmd/myinterpreter/ast"
+	"github.com/distolma/golox/cmd/myinterpreter/compiler"
+	"github.com/distolma/golox/cmd/myinterpreter/interpreter"
+	"github.com/distolma/golox/cmd/myinterpreter/parser"
+	"github.com/distolma/golox/cmd/myinterpreter/repl"
+	"github.com/distolma/golox/cmd/myinterpreter/resolver"
+	"github.com/distolma/golox/cmd/myinterpreter/scanner"
+	"github.com/distolma/golox/cmd/myinterpreter/vm"
+)
+
+const (
+	prompt             = "> "
+	continuationPrompt = "... "
+)
+
+const promptHelp = `:env           List the globals defined so far
+:load FILE     Run FILE in this session
+:ast CODE      Print the syntax tree of CODE
+:tokens CODE   Print the tokens of CODE
+:reset         Forget every global defined so far
+:help          Show this list
+:quit          Leave the prompt
+`
+
+// runPrompt reads code from the user until the input ends. Lines are
+// gathered while brackets or a string are left open, and the value of a
+// trailing expression is printed. Lines starting with ':' are commands to
+// the prompt itself.
+func (l *Lox) runPrompt() {
+	editor := repl.NewEditor(os.Stdin, os.Stdout)
+	editor.Complete = l.complete
+	if path := repl.HistoryPath(); path != "" && editor.Interactive() {
+		if err := editor.LoadHistory(path); err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading history: %v\n", err)
+		}
+	}
+
+	var entry strings.Builder
+	for {
+		current := prompt
+		if entry.Len() > 0 {
+			current = continuationPrompt
+		}
+
+		line, err := editor.ReadLine(current)
+		if errors.Is(err, repl.ErrInterrupt) {
+			entry.Reset()
+			continue
+		}
+		if err != nil {
+			return
+		}
+		// The history is a convenience; failing to save it isn't worth
+		// interrupting the session for.
+		_ = editor.AddHistory(line)
+
+		if entry.Len() == 0 && strings.HasPrefix(strings.TrimSpace(line), ":") {
+			if !l.promptCommand(strings.TrimSpace(line)) {
+				return
+			}
+			continue
+		}
+
+		entry.WriteString(line)
+		entry.WriteString("\n")
+		if repl.Incomplete(entry.String()) {
+			continue
+		}
+
+		l.runEntry(entry.String())
+		entry.Reset()
+	}
+}
+
+// runEntry runs code typed at the prompt and prints the value of its last
+// statement if that is an expression. A lone expression doesn't need a
+// semicolon.
+func (l *Lox) runEntry(source string) {
+	defer l.log.Reset()
+
+	tokens := scanner.NewScanner(source, l.log).ScanTokens()
+	if l.log.HadError {
+		return
+	}
+
+	parser := parser.NewParser(tokens, l.log)
+	var statements []ast.Stmt
+	if parser.IsExpression() {
+		statements = []ast.Stmt{&ast.Expression{Expression: parser.ParseExpression()}}
+	} else {
+		statements = parser.Parse()
+	}
+	if l.log.HadError || len(statements) == 0 {
+		return
+	}
+
+	resolver := resolver.NewResolver(l.interpreter, l.log)
+	resolver.ResolveStmts(statements)
+	if l.log.HadError {
+		return
+	}
+
+	var result interface{}
+	if l.backend == BackendVM {
+		function := compiler.NewCompiler(l.log).CompileEval(statements)
+		if l.log.HadError {
+			return
+		}
+		result = l.vm.Interpret(l.ctx, function)
+	} else {
+		result = l.interpreter.Eval(l.ctx, statements)
+	}
+
+	if l.log.HadError || l.log.HadRuntimeError {
+		return
+	}
+	if _, ok := statements[len(statements)-1].(*ast.Expression); ok {
+		fmt.Println(display(result))
+	}
+}
+
+// promptCommand carries out a command to the prompt. It returns false if
+// the session should end.
+func (l *Lox) promptCommand(line string) bool {
+	defer l.log.Reset()
+
+	command, argument, _ := strings.Cut(line, " ")
+	argument = strings.TrimSpace(argument)
+
+	switch command {
+	case ":quit", ":exit":
+		return false
+	case ":help":
+		fmt.Print(promptHelp)
+	case ":env":
+		l.printGlobals()
+	case ":reset":
+		l.reset()
+	case ":load":
+		if argument == "" {
+			fmt.Fprintln(os.Stderr, "Usage: :load FILE")
+			break
+		}
+		file, err := os.ReadFile(argument)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
+			break
+		}
+		l.run(argument, string(file))
+	case ":ast":
+		if argument == "" {
+			fmt.Fprintln(os.Stderr, "Usage: :ast CODE")
+			break
+		}
+		parser := parser.NewParser(scanner.NewScanner(argument, l.log).ScanTokens(), l.log)
+		if parser.IsExpression() {
+			printer := ast.AstPrinter{}
+			fmt.Println(printer.PrintExpression(parser.ParseExpression()))
+			break
+		}
+		statements := parser.Parse()
+		if !l.log.HadError {
+			printer := ast.AstPrinter{}
+			fmt.Print(printer.Print(statements))
+		}
+	case ":tokens":
+		if argument == "" {
+			fmt.Fprintln(os.Stderr, "Usage: :tokens CODE")
+			break
+		}
+		for _, token := range scanner.NewScanner(argument, l.log).ScanTokens() {
+			fmt.Println(token.String())
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command: %s. Type :help for a list.\n", command)
+	}
+	return true
+}
+
+// printGlobals lists the globals of the session with their values.
+// Natives are left out.
+func (l *Lox) printGlobals() {
+	if l.backend == BackendVM {
+		globals := l.vm.Globals()
+		names := make([]string, 0, len(globals))
+		for name := range globals {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+
+		for _, name := range names {
+			fmt.Printf("%s = %s\n", name, display(globals[name]))
+		}
+		return
+	}
+
+	globals := l.interpreter.Globals()
+	for _, name := range globals.Names() {
+		value, _ := globals.Lookup(name)
+		fmt.Printf("%s = %s\n", name, display(value))
+	}
+}
+
+// display formats a value the way print statements do.
+func display(value interface{}) string {
+	if value == nil {
+		return "nil"
+	}
+	return fmt.Sprint(value)
+}
+
+// complete lists the keywords and globals that the word at the end of before
+// could be completed to. After a dot it lists the properties of the value
+// named by the variable and properties left of it instead.
+func (l *Lox) complete(before string) []string {
+	word := trailingWord(before)
+	rest := before[:len(before)-len(word)]
+
+	var names []string
+	if rest, ok := strings.CutSuffix(rest, "."); ok {
+		names = l.propertyNames(trailingPath(rest))
+	} else {
+		names = append(scanner.Keywords(), l.globalNames()...)
+	}
+
+	var candidates []string
+	for _, name := range names {
+		if strings.HasPrefix(name, word) {
+			candidates = append(candidates, name)
+		}
+	}
+	slices.Sort(candidates)
+	return slices.Compact(candidates)
+}
+
+// globalNames lists the globals of the session, natives included.
+func (l *Lox) globalNames() []string {
+	if l.backend == BackendVM {
+		return append(l.vm.NativeNames(), l.vm.GlobalNames()...)
+	}
+
+	globals := l.interpreter.Globals()
+	return append(globals.Names(), globals.Enclosing.Names()...)
+}
+
+// propertyNames lists the properties of the instance or module reached by
+// reading path, a global followed by properties, without calling anything.
+func (l *Lox) propertyNames(path []string) []string {
+	if len(path) == 0 {
+		return nil
+	}
+
+	if l.backend == BackendVM {
+		value, ok := l.vm.Globals()[path[0]]
+		for _, name := range path[1:] {
+			switch object := value.(type) {
+			case *vm.Instance:
+				value, ok = object.Property(name)
+			case *vm.Module:
+				value, ok = object.Lookup(name)
+			default:
+				ok = false
+			}
+			if !ok {
+				return nil
+			}
+		}
+
+		switch object := value.(type) {
+		case *vm.Instance:
+			return object.PropertyNames()
+		case *vm.Module:
+			return object.Names()
+		}
+		return nil
+	}
+
+	value, ok := l.interpreter.Globals().Lookup(path[0])
+	for _, name := range path[1:] {
+		switch object := value.(type) {
+		case *interpreter.Instance:
+			value, ok = object.Property(name)
+		case *interpreter.Module:
+			value, ok = object.Lookup(name)
+		default:
+			ok = false
+		}
+		if !ok {
+			return nil
+		}
+	}
+
+	switch object := value.(type) {
+	case *interpreter.Instance:
+		return object.PropertyNames()
+	case *interpreter.Module:
+		return object.Names()
+	}
+	return nil
+}
+
+// trailingWord returns the identifier characters at the end of s.
+func trailingWord(s string) string {
+	start := len(s)
+	for start > 0 {
+		r, size := utf8.DecodeLastRuneInString(s[:start])
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			break
+		}
+		start -= size
+	}
+	return s[start:]
+}
+
+// trailingPath splits a chain of names joined by dots at the end of s, such
+// as "a.b" in "print a.b", into its names.
+func trailingPath(s string) []string {
+	var path []string
+	for {
+		name := trailingWord(s)
+		if name == "" {
+			return nil
+		}
+		path = append([]string{name}, path...)
+
+		var ok bool
+		s, ok = strings.CutSuffix(s[:len(s)-len(name)], ".")
+		if !ok {
+			return path
+		}
+	}
+}
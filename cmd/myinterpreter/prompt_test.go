@@ -0,0 +1,38 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestComplete(t *testing.T) {
+	source := `
+class Shape { area() { return 0; } }
+class Square < Shape { init(side) { this.side = side; } scale() {} }
+var square = Square(2);
+var shapes = 1;
+`
+	tests := map[string][]string{
+		"sq":           {"square"},
+		"print sh":     {"shapes"},
+		"cl":           {"class", "clock"},
+		"square.":      {"area", "init", "scale", "side"},
+		"square.s":     {"scale", "side"},
+		"square.side.": nil,
+		"missing.":     nil,
+	}
+
+	for _, backend := range []string{BackendTreeWalker, BackendVM} {
+		l := NewLox(backend)
+		l.run("", source)
+		if l.log.HadError || l.log.HadRuntimeError {
+			t.Fatalf("%s: running the program failed: %v", backend, l.log.Reports)
+		}
+
+		for before, want := range tests {
+			if got := l.complete(before); !slices.Equal(got, want) {
+				t.Errorf("%s: complete(%q) = %q, want %q", backend, before, got, want)
+			}
+		}
+	}
+}
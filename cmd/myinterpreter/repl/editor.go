@@ -0,0 +1,327 @@
+// Package repl reads the lines typed at the interactive prompt. On a
+// terminal they can be edited in place and recalled from the history.
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrInterrupt is returned by ReadLine when Ctrl-C abandons the line.
+var ErrInterrupt = errors.New("interrupt")
+
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlH     = 8
+	keyTab       = 9
+	keyLineFeed  = 10
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyEnter     = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyBackspace = 127
+)
+
+type Editor struct {
+	// Complete, if set, lists the words that the word before the cursor
+	// could be completed to when Tab is pressed. It is given the line up to
+	// the cursor.
+	Complete func(before string) []string
+
+	out         io.Writer
+	reader      *bufio.Reader
+	history     []string
+	historyPath string
+
+	// terminal is set when lines are edited in place. fd is the terminal
+	// that is put into raw mode meanwhile.
+	terminal bool
+	fd       uintptr
+
+	// The line being edited, the cursor position in it, and which entry of
+	// the history is shown. Browsing the history keeps what was typed in
+	// draft.
+	line   []rune
+	cursor int
+	entry  int
+	draft  []rune
+}
+
+// NewEditor reads lines from in, echoing them to out. Lines are only
+// edited in place when both are terminals.
+func NewEditor(in io.Reader, out io.Writer) *Editor {
+	e := &Editor{out: out, reader: bufio.NewReader(in)}
+
+	inFile, inOk := in.(*os.File)
+	outFile, outOk := out.(*os.File)
+	if inOk && outOk && isTerminal(inFile.Fd()) && isTerminal(outFile.Fd()) {
+		e.terminal, e.fd = true, inFile.Fd()
+	}
+	return e
+}
+
+// Interactive reports whether lines are typed at a terminal, and so can be
+// edited.
+func (e *Editor) Interactive() bool {
+	return e.terminal
+}
+
+// ReadLine shows prompt and returns the line typed after it, without the
+// newline. It returns io.EOF once the input ends, or Ctrl-D is pressed on
+// an empty line, and ErrInterrupt if Ctrl-C is pressed.
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	fmt.Fprint(e.out, prompt)
+	if !e.terminal {
+		return e.readPlain()
+	}
+
+	restore, err := makeRaw(e.fd)
+	if err != nil {
+		return e.readPlain()
+	}
+	defer restore()
+
+	return e.edit(prompt)
+}
+
+// edit reads keys until the line is finished, redrawing it after each one.
+func (e *Editor) edit(prompt string) (string, error) {
+	e.line, e.cursor = nil, 0
+	e.entry, e.draft = len(e.history), nil
+	for {
+		r, _, err := e.reader.ReadRune()
+		if err != nil {
+			fmt.Fprint(e.out, "\r\n")
+			return "", err
+		}
+
+		switch r {
+		case keyEnter, keyLineFeed:
+			fmt.Fprint(e.out, "\r\n")
+			return string(e.line), nil
+		case keyCtrlC:
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", ErrInterrupt
+		case keyCtrlD:
+			if len(e.line) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			e.delete(e.cursor, e.cursor+1)
+		case keyCtrlA:
+			e.cursor = 0
+		case keyCtrlE:
+			e.cursor = len(e.line)
+		case keyCtrlB:
+			e.cursor = max(e.cursor-1, 0)
+		case keyCtrlF:
+			e.cursor = min(e.cursor+1, len(e.line))
+		case keyBackspace, keyCtrlH:
+			e.delete(e.cursor-1, e.cursor)
+		case keyCtrlK:
+			e.delete(e.cursor, len(e.line))
+		case keyCtrlU:
+			e.delete(0, e.cursor)
+		case keyCtrlW:
+			e.delete(e.wordStart(), e.cursor)
+		case keyCtrlP:
+			e.recall(e.entry - 1)
+		case keyCtrlN:
+			e.recall(e.entry + 1)
+		case keyCtrlL:
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+		case keyEscape:
+			e.escape()
+		case keyTab:
+			e.complete(prompt)
+		default:
+			if unicode.IsPrint(r) {
+				e.insert(string(r))
+			}
+		}
+		e.refresh(prompt)
+	}
+}
+
+// complete extends the word before the cursor as far as the candidates
+// agree, and lists them if they don't. Before anything but a word or a dot,
+// Tab indents instead.
+func (e *Editor) complete(prompt string) {
+	start := e.cursor
+	for start > 0 && isWordRune(e.line[start-1]) {
+		start--
+	}
+	if e.Complete == nil || (start == e.cursor && (start == 0 || e.line[start-1] != '.')) {
+		e.insert("  ")
+		return
+	}
+
+	word := string(e.line[start:e.cursor])
+	candidates := e.Complete(string(e.line[:e.cursor]))
+	if len(candidates) == 0 {
+		fmt.Fprint(e.out, "\a")
+		return
+	}
+
+	prefix := candidates[0]
+	for _, candidate := range candidates[1:] {
+		for !strings.HasPrefix(candidate, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	for !utf8.ValidString(prefix) {
+		prefix = prefix[:len(prefix)-1]
+	}
+	if len(prefix) > len(word) {
+		e.insert(prefix[len(word):])
+		return
+	}
+	if len(candidates) > 1 {
+		fmt.Fprintf(e.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+	}
+}
+
+// readPlain reads a line as the terminal driver delivers it, for input
+// that isn't typed at a terminal.
+func (e *Editor) readPlain() (string, error) {
+	line, err := e.reader.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// escape handles the escape sequences sent by the arrow, home, end and
+// delete keys.
+func (e *Editor) escape() {
+	next, err := e.reader.ReadByte()
+	if err != nil || (next != '[' && next != 'O') {
+		return
+	}
+
+	var parameter []byte
+	for {
+		b, err := e.reader.ReadByte()
+		if err != nil {
+			return
+		}
+		if b < '0' || b > '9' {
+			if b == ';' {
+				continue
+			}
+			next = b
+			break
+		}
+		parameter = append(parameter, b)
+	}
+
+	switch next {
+	case 'A':
+		e.recall(e.entry - 1)
+	case 'B':
+		e.recall(e.entry + 1)
+	case 'C':
+		e.cursor = min(e.cursor+1, len(e.line))
+	case 'D':
+		e.cursor = max(e.cursor-1, 0)
+	case 'H':
+		e.cursor = 0
+	case 'F':
+		e.cursor = len(e.line)
+	case '~':
+		switch string(parameter) {
+		case "1", "7":
+			e.cursor = 0
+		case "4", "8":
+			e.cursor = len(e.line)
+		case "3":
+			e.delete(e.cursor, e.cursor+1)
+		}
+	}
+}
+
+func (e *Editor) insert(text string) {
+	runes := []rune(text)
+	line := make([]rune, 0, len(e.line)+len(runes))
+	line = append(line, e.line[:e.cursor]...)
+	line = append(line, runes...)
+	e.line = append(line, e.line[e.cursor:]...)
+	e.cursor += len(runes)
+}
+
+// delete removes the characters from start up to end, clamped to the line.
+func (e *Editor) delete(start int, end int) {
+	start, end = max(start, 0), min(end, len(e.line))
+	if start >= end {
+		return
+	}
+
+	e.line = append(e.line[:start], e.line[end:]...)
+	if e.cursor > end {
+		e.cursor -= end - start
+	} else if e.cursor > start {
+		e.cursor = start
+	}
+}
+
+// wordStart is where the word before the cursor starts, skipping the
+// spaces between them.
+func (e *Editor) wordStart() int {
+	start := e.cursor
+	for start > 0 && unicode.IsSpace(e.line[start-1]) {
+		start--
+	}
+	for start > 0 && !unicode.IsSpace(e.line[start-1]) {
+		start--
+	}
+	return start
+}
+
+// recall shows the history entry at index, where one past the last entry
+// is the line that was being typed.
+func (e *Editor) recall(index int) {
+	if index < 0 || index > len(e.history) || index == e.entry {
+		return
+	}
+
+	if e.entry == len(e.history) {
+		e.draft = e.line
+	}
+	e.entry = index
+
+	if index == len(e.history) {
+		e.line = e.draft
+	} else {
+		e.line = []rune(e.history[index])
+	}
+	e.cursor = len(e.line)
+}
+
+// isWordRune reports whether r can be part of an identifier.
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// refresh redraws the prompt and line and puts the cursor back in place.
+func (e *Editor) refresh(prompt string) {
+	column := len([]rune(prompt)) + e.cursor
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K\r", prompt, string(e.line))
+	if column > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dC", column)
+	}
+}
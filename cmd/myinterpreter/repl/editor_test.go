@@ -0,0 +1,174 @@
+package repl
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// edit runs the line editor on the keys typed, as if at a terminal.
+func edit(keys string, history []string, complete func(string) []string) (string, error) {
+	e := NewEditor(strings.NewReader(keys), io.Discard)
+	e.history = history
+	e.Complete = complete
+	return e.edit("> ")
+}
+
+func TestEditingKeys(t *testing.T) {
+	tests := map[string]struct {
+		keys string
+		want string
+	}{
+		"typing":                  {"print 1;\r", "print 1;"},
+		"line feed":               {"abc\n", "abc"},
+		"multi-byte characters":   {"\"héllo\"\r", `"héllo"`},
+		"backspace":               {"abx\x7fc\r", "abc"},
+		"ctrl-h":                  {"abx\x08c\r", "abc"},
+		"ctrl-a and ctrl-e":       {"bc\x01a\x05d\r", "abcd"},
+		"ctrl-b and ctrl-f":       {"ac\x02b\x06d\r", "abcd"},
+		"arrow keys":              {"ac\x1b[Db\x1b[Cd\r", "abcd"},
+		"home and end":            {"bc\x1b[Ha\x1b[Fd\r", "abcd"},
+		"home and end with tilde": {"bc\x1b[1~a\x1b[4~d\r", "abcd"},
+		"application mode keys":   {"bc\x1bOHa\r", "abc"},
+		"delete":                  {"abxc\x1b[D\x1b[D\x1b[3~\r", "abc"},
+		"ctrl-d inside the line":  {"abxc\x02\x02\x04\r", "abc"},
+		"ctrl-k":                  {"abcdef\x01\x06\x06\x06\x0b\r", "abc"},
+		"ctrl-u":                  {"xyzabc\x01\x06\x06\x06\x15\r", "abc"},
+		"ctrl-w":                  {"var a = 1;  \x17\x17\r", "var a "},
+		"cursor stays in line":    {"ab\x02\x02\x02c\x06\x06\x06d\r", "cabd"},
+		"control keys ignored":    {"a\x00\x1fb\r", "ab"},
+		"tab indents":             {"\tx\r", "  x"},
+		"unknown escape":          {"a\x1b[Zb\r", "ab"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := edit(test.keys, nil, nil)
+			if err != nil {
+				t.Fatalf("edit(%q) = %v", test.keys, err)
+			}
+			if got != test.want {
+				t.Errorf("edit(%q) = %q, want %q", test.keys, got, test.want)
+			}
+		})
+	}
+}
+
+func TestEditingEnds(t *testing.T) {
+	tests := map[string]struct {
+		keys string
+		want error
+	}{
+		"ctrl-c":                    {"abc\x03", ErrInterrupt},
+		"ctrl-d on an empty line":   {"\x04", io.EOF},
+		"input ends inside a line":  {"abc", io.EOF},
+		"ctrl-d after clearing out": {"a\x7f\x04", io.EOF},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := edit(test.keys, nil, nil); !errors.Is(err, test.want) {
+				t.Errorf("edit(%q) = %v, want %v", test.keys, err, test.want)
+			}
+		})
+	}
+}
+
+func TestHistoryRecall(t *testing.T) {
+	history := []string{"one", "two"}
+	tests := map[string]struct {
+		keys string
+		want string
+	}{
+		"up":                  {"\x1b[A\r", "two"},
+		"up twice":            {"\x1b[A\x1b[A\r", "one"},
+		"past the oldest":     {"\x1b[A\x1b[A\x1b[A\r", "one"},
+		"ctrl-p and ctrl-n":   {"\x10\x10\x0e\r", "two"},
+		"back to the draft":   {"dr\x1b[A\x1b[A\x1b[B\x1b[Baft\r", "draft"},
+		"down past the draft": {"dr\x1b[B\r", "dr"},
+		"edit a recalled line": {
+			"\x1b[A\x7f\x7f\x7fthree\r", "three",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := edit(test.keys, history, nil)
+			if err != nil {
+				t.Fatalf("edit(%q) = %v", test.keys, err)
+			}
+			if got != test.want {
+				t.Errorf("edit(%q) = %q, want %q", test.keys, got, test.want)
+			}
+		})
+	}
+	if len(history) != 2 || history[0] != "one" || history[1] != "two" {
+		t.Errorf("history = %q, editing changed it", history)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	words := []string{"print", "prime", "private", "length"}
+	complete := func(before string) []string {
+		start := strings.LastIndexAny(before, " .(") + 1
+		var candidates []string
+		for _, word := range words {
+			if strings.HasPrefix(word, before[start:]) {
+				candidates = append(candidates, word)
+			}
+		}
+		return candidates
+	}
+
+	tests := map[string]struct {
+		keys string
+		want string
+	}{
+		"single candidate":     {"le\t\r", "length"},
+		"common prefix":        {"pr\t\r", "pri"},
+		"after a dot":          {"x.le\t\r", "x.length"},
+		"no candidates":        {"zz\t\r", "zz"},
+		"inside a line":        {"le(x)\x02\x02\x02\t\r", "length(x)"},
+		"indents at the start": {"\tle\t\r", "  length"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := edit(test.keys, nil, complete)
+			if err != nil {
+				t.Fatalf("edit(%q) = %v", test.keys, err)
+			}
+			if got != test.want {
+				t.Errorf("edit(%q) = %q, want %q", test.keys, got, test.want)
+			}
+		})
+	}
+}
+
+// TestReadLineWithoutTerminal checks that input that isn't typed at a
+// terminal is read line by line, without editing.
+func TestReadLineWithoutTerminal(t *testing.T) {
+	var out bytes.Buffer
+	e := NewEditor(strings.NewReader("print 1;\r\nab\x7fc\nlast"), &out)
+	if e.Interactive() {
+		t.Fatal("Interactive() = true for a reader")
+	}
+
+	for _, want := range []string{"print 1;", "ab\x7fc", "last"} {
+		got, err := e.ReadLine("> ")
+		if err != nil {
+			t.Fatalf("ReadLine() = %v, want %q", err, want)
+		}
+		if got != want {
+			t.Errorf("ReadLine() = %q, want %q", got, want)
+		}
+	}
+	if _, err := e.ReadLine("> "); err != io.EOF {
+		t.Errorf("ReadLine() at the end = %v, want %v", err, io.EOF)
+	}
+	if got := out.String(); got != "> > > > " {
+		t.Errorf("output = %q, want one prompt per line", got)
+	}
+}
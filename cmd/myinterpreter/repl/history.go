@@ -0,0 +1,81 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MaxHistory is the number of lines kept in the history.
+const MaxHistory = 1000
+
+// HistoryPath is the file the history is kept in: $LOX_HISTORY if it is
+// set, otherwise .lox_history in the home directory. It is empty if there
+// is neither.
+func HistoryPath() string {
+	if path, ok := os.LookupEnv("LOX_HISTORY"); ok {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".lox_history")
+}
+
+// LoadHistory reads the lines entered in earlier sessions from path and
+// appends every line entered from now on to it. A missing file is created
+// with the first line.
+func (e *Editor) LoadHistory(path string) error {
+	e.historyPath = path
+
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	lines := bufio.NewScanner(file)
+	for lines.Scan() {
+		e.remember(lines.Text())
+	}
+	return lines.Err()
+}
+
+// AddHistory records a line so it can be recalled with the up arrow, in
+// this session and, with LoadHistory, the ones after it. Blank lines and
+// repeats of the previous line are skipped.
+func (e *Editor) AddHistory(line string) error {
+	if !e.remember(line) || e.historyPath == "" {
+		return nil
+	}
+
+	file, err := os.OpenFile(e.historyPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteString(line + "\n"); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (e *Editor) remember(line string) bool {
+	if strings.TrimSpace(line) == "" || (len(e.history) > 0 && e.history[len(e.history)-1] == line) {
+		return false
+	}
+
+	e.history = append(e.history, line)
+	if len(e.history) > MaxHistory {
+		e.history = e.history[len(e.history)-MaxHistory:]
+	}
+	return true
+}
@@ -0,0 +1,104 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLoadHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	if err := os.WriteFile(path, []byte("one\ntwo\ntwo\n\n  \nthree\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	e := NewEditor(strings.NewReader(""), io.Discard)
+	if err := e.LoadHistory(path); err != nil {
+		t.Fatalf("LoadHistory() = %v", err)
+	}
+	if want := []string{"one", "two", "three"}; !slices.Equal(e.history, want) {
+		t.Errorf("history = %q, want %q", e.history, want)
+	}
+}
+
+func TestLoadHistoryKeepsTheLatest(t *testing.T) {
+	var lines strings.Builder
+	for i := 0; i < MaxHistory+10; i++ {
+		fmt.Fprintf(&lines, "print %d;\n", i)
+	}
+	path := filepath.Join(t.TempDir(), "history")
+	if err := os.WriteFile(path, []byte(lines.String()), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	e := NewEditor(strings.NewReader(""), io.Discard)
+	if err := e.LoadHistory(path); err != nil {
+		t.Fatalf("LoadHistory() = %v", err)
+	}
+	if len(e.history) != MaxHistory || e.history[0] != "print 10;" {
+		t.Errorf("history has %d lines starting at %q, want %d starting at %q", len(e.history), e.history[0], MaxHistory, "print 10;")
+	}
+}
+
+func TestAddHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+
+	e := NewEditor(strings.NewReader(""), io.Discard)
+	if err := e.LoadHistory(path); err != nil {
+		t.Fatalf("LoadHistory() of a missing file = %v", err)
+	}
+	for _, line := range []string{"one", "one", "", "   ", "two", "one"} {
+		if err := e.AddHistory(line); err != nil {
+			t.Fatalf("AddHistory(%q) = %v", line, err)
+		}
+	}
+
+	want := []string{"one", "two", "one"}
+	if !slices.Equal(e.history, want) {
+		t.Errorf("history = %q, want %q", e.history, want)
+	}
+
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(saved); got != "one\ntwo\none\n" {
+		t.Errorf("saved history = %q, want %q", got, "one\ntwo\none\n")
+	}
+
+	// The next session starts with the lines saved by this one.
+	next := NewEditor(strings.NewReader("\x1b[A\x1b[A\r"), io.Discard)
+	if err := next.LoadHistory(path); err != nil {
+		t.Fatalf("LoadHistory() = %v", err)
+	}
+	if got, err := next.edit("> "); err != nil || got != "two" {
+		t.Errorf("recalling two lines back = %q, %v, want %q", got, err, "two")
+	}
+}
+
+func TestAddHistoryWithoutFile(t *testing.T) {
+	e := NewEditor(strings.NewReader(""), io.Discard)
+	if err := e.AddHistory("one"); err != nil {
+		t.Fatalf("AddHistory() = %v", err)
+	}
+	if want := []string{"one"}; !slices.Equal(e.history, want) {
+		t.Errorf("history = %q, want %q", e.history, want)
+	}
+}
+
+func TestHistoryPath(t *testing.T) {
+	t.Setenv("LOX_HISTORY", "/tmp/lox-history")
+	if got := HistoryPath(); got != "/tmp/lox-history" {
+		t.Errorf("HistoryPath() = %q, want $LOX_HISTORY", got)
+	}
+
+	os.Unsetenv("LOX_HISTORY")
+	t.Setenv("HOME", "/home/lox")
+	if got, want := HistoryPath(), filepath.Join("/home/lox", ".lox_history"); got != want {
+		t.Errorf("HistoryPath() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,33 @@
+package repl
+
+import (
+	"io"
+
+	"github.com/distolma/golox/cmd/myinterpreter/ast"
+	logerror "github.com/distolma/golox/cmd/myinterpreter/log_error"
+	"github.com/distolma/golox/cmd/myinterpreter/scanner"
+)
+
+// Incomplete reports whether source stops inside a string or with brackets
+// left open, so that the prompt should read another line before running it.
+func Incomplete(source string) bool {
+	log := &logerror.LogError{Output: io.Discard}
+	tokens := scanner.NewScanner(source, log).ScanTokens()
+
+	for _, report := range log.Reports {
+		if report.Message == "Unterminated string." {
+			return true
+		}
+	}
+
+	depth := 0
+	for _, token := range tokens {
+		switch token.Type {
+		case ast.TLeftParen, ast.TLeftBrace, ast.TLeftBracket:
+			depth++
+		case ast.TRightParen, ast.TRightBrace, ast.TRightBracket:
+			depth--
+		}
+	}
+	return depth > 0
+}
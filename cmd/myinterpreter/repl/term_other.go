@@ -0,0 +1,15 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package repl
+
+import "errors"
+
+// Line editing needs a Unix terminal. Elsewhere lines are read as they are
+// typed.
+func isTerminal(fd uintptr) bool {
+	return false
+}
+
+func makeRaw(fd uintptr) (func(), error) {
+	return nil, errors.New("raw mode is not supported")
+}
@@ -0,0 +1,21 @@
+package resolver
+
+import "github.com/distolma/golox/cmd/myinterpreter/ast"
+
+// Listener is told about every name the resolver declares or looks up, for
+// tools that need to know more than the scope depths the interpreter uses.
+type Listener interface {
+	// Declare is called for each variable, function, class, parameter,
+	// catch variable and imported name. global is true at the top level.
+	Declare(name ast.Token, global bool)
+	// Use is called for each variable read or assigned and for 'this' and
+	// 'super'. declaration is the name that introduced the variable. It is
+	// nil for globals, which are looked up at runtime, and for 'this' and
+	// 'super', which no name introduces.
+	Use(name ast.Token, declaration *ast.Token)
+}
+
+// SetListener makes the resolver report names to listener as it goes.
+func (r *Resolver) SetListener(listener Listener) {
+	r.listener = listener
+}
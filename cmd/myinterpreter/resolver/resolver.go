@@ -48,6 +48,14 @@ func (s *Stack) IsEmpty() bool {
 const (
 	FunctionTypeNone = iota
 	FunctionTypeFunction
+	FunctionTypeInitializer
+	FunctionTypeMethod
+)
+
+const (
+	ClassTypeNone = iota
+	ClassTypeClass
+	ClassTypeSubclass
 )
 
 type Resolver struct {
@@ -55,13 +63,20 @@ type Resolver struct {
 	interpreter     *interpreter.Interpreter
 	scopes          Stack
 	currentFunction int
+	currentClass    int
+	loopDepth       int
+	listener        Listener
+	// declarations holds the name tokens of each scope when there is a
+	// listener to report them to.
+	declarations []map[string]ast.Token
 }
 
 func NewResolver(interpreter *interpreter.Interpreter, log *logerror.LogError) *Resolver {
-	return &Resolver{interpreter: interpreter, log: log, currentFunction: FunctionTypeNone}
+	return &Resolver{interpreter: interpreter, log: log, currentFunction: FunctionTypeNone, currentClass: ClassTypeNone}
 }
 
 func (r *Resolver) ResolveStmts(statements []ast.Stmt) {
+	r.log.Phase = logerror.PhaseResolve
 	for _, statement := range statements {
 		r.resolveStmt(statement)
 	}
@@ -77,11 +92,14 @@ func (r *Resolver) resolveExpr(expr ast.Expr) {
 
 func (r *Resolver) resolveFunction(function *ast.Function, functionType int) {
 	enclosingFunction := r.currentFunction
+	enclosingLoopDepth := r.loopDepth
 	r.beginScope()
 	r.currentFunction = functionType
+	r.loopDepth = 0
 	defer func() {
 		r.endScope()
 		r.currentFunction = enclosingFunction
+		r.loopDepth = enclosingLoopDepth
 	}()
 
 	for _, param := range function.Params {
@@ -94,13 +112,26 @@ func (r *Resolver) resolveFunction(function *ast.Function, functionType int) {
 
 func (r *Resolver) beginScope() {
 	r.scopes.Push(make(Scope))
+	if r.listener != nil {
+		r.declarations = append(r.declarations, make(map[string]ast.Token))
+	}
 }
 
 func (r *Resolver) endScope() {
 	r.scopes.Pop()
+	if r.listener != nil {
+		r.declarations = r.declarations[:len(r.declarations)-1]
+	}
 }
 
 func (r *Resolver) declare(name ast.Token) {
+	if r.listener != nil {
+		r.listener.Declare(name, r.scopes.IsEmpty())
+		if !r.scopes.IsEmpty() {
+			r.declarations[len(r.declarations)-1][name.Lexeme] = name
+		}
+	}
+
 	if r.scopes.IsEmpty() {
 		return
 	}
@@ -122,9 +153,21 @@ func (r *Resolver) resolveLocal(expr ast.Expr, name ast.Token) {
 	for i := r.scopes.Size() - 1; i >= 0; i-- {
 		if _, defined := r.scopes[i].Has(name.Lexeme); defined {
 			r.interpreter.Resolve(expr, r.scopes.Size()-1-i)
+			if r.listener != nil {
+				declaration, ok := r.declarations[i][name.Lexeme]
+				if ok {
+					r.listener.Use(name, &declaration)
+				} else {
+					r.listener.Use(name, nil)
+				}
+			}
 			return
 		}
 	}
+
+	if r.listener != nil {
+		r.listener.Use(name, nil)
+	}
 }
 
 func (r *Resolver) VisitBlockStmt(stmt *ast.Block) interface{} {
@@ -134,6 +177,47 @@ func (r *Resolver) VisitBlockStmt(stmt *ast.Block) interface{} {
 	return nil
 }
 
+func (r *Resolver) VisitClassStmt(stmt *ast.Class) interface{} {
+	enclosingClass := r.currentClass
+	r.currentClass = ClassTypeClass
+	defer func() {
+		r.currentClass = enclosingClass
+	}()
+
+	r.declare(stmt.Name)
+	r.define(stmt.Name)
+
+	if stmt.Superclass != nil {
+		if stmt.Name.Lexeme == stmt.Superclass.Name.Lexeme {
+			r.log.TokenError(stmt.Superclass.Name, "A class can't inherit from itself.")
+		}
+
+		r.currentClass = ClassTypeSubclass
+		r.resolveExpr(stmt.Superclass)
+
+		r.beginScope()
+		r.scopes.Peek().Define("super")
+	}
+
+	r.beginScope()
+	r.scopes.Peek().Define("this")
+
+	for _, method := range stmt.Methods {
+		declaration := FunctionTypeMethod
+		if method.Name.Lexeme == "init" {
+			declaration = FunctionTypeInitializer
+		}
+		r.resolveFunction(method, declaration)
+	}
+
+	r.endScope()
+
+	if stmt.Superclass != nil {
+		r.endScope()
+	}
+	return nil
+}
+
 func (r *Resolver) VisitExpressionStmt(stmt *ast.Expression) interface{} {
 	r.resolveExpr(stmt.Expression)
 	return nil
@@ -166,6 +250,9 @@ func (r *Resolver) VisitReturnStmt(stmt *ast.Return) interface{} {
 		r.log.TokenError(stmt.Keyword, "Can't return from top-level code.")
 	}
 	if stmt.Value != nil {
+		if r.currentFunction == FunctionTypeInitializer {
+			r.log.TokenError(stmt.Keyword, "Can't return a value from an initializer.")
+		}
 		r.resolveExpr(stmt.Value)
 	}
 	return nil
@@ -182,11 +269,67 @@ func (r *Resolver) VisitVarStmt(stmt *ast.Var) interface{} {
 
 func (r *Resolver) VisitWhileStmt(stmt *ast.While) interface{} {
 	r.resolveExpr(stmt.Condition)
+
+	r.loopDepth++
 	r.resolveStmt(stmt.Body)
+	r.loopDepth--
+
+	if stmt.Increment != nil {
+		r.resolveExpr(stmt.Increment)
+	}
 
 	return nil
 }
 
+func (r *Resolver) VisitImportStmt(stmt *ast.Import) interface{} {
+	if stmt.Alias.Lexeme != "" {
+		r.declare(stmt.Alias)
+		r.define(stmt.Alias)
+	}
+	for _, name := range stmt.Names {
+		r.declare(name)
+		r.define(name)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitThrowStmt(stmt *ast.Throw) interface{} {
+	r.resolveExpr(stmt.Value)
+	return nil
+}
+
+func (r *Resolver) VisitTryStmt(stmt *ast.Try) interface{} {
+	r.resolveStmt(stmt.Body)
+
+	if stmt.Catch != nil {
+		// The exception variable shares a scope with the catch body.
+		r.beginScope()
+		r.declare(stmt.Name)
+		r.define(stmt.Name)
+		r.ResolveStmts(stmt.Catch.Statements)
+		r.endScope()
+	}
+
+	if stmt.Finally != nil {
+		r.resolveStmt(stmt.Finally)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitBreakStmt(stmt *ast.Break) interface{} {
+	if r.loopDepth == 0 {
+		r.log.TokenError(stmt.Keyword, "Can't use 'break' outside of a loop.")
+	}
+	return nil
+}
+
+func (r *Resolver) VisitContinueStmt(stmt *ast.Continue) interface{} {
+	if r.loopDepth == 0 {
+		r.log.TokenError(stmt.Keyword, "Can't use 'continue' outside of a loop.")
+	}
+	return nil
+}
+
 func (r *Resolver) VisitAssignExpr(expr *ast.Assign) interface{} {
 	r.resolveExpr(expr.Value)
 	r.resolveLocal(expr, expr.Name)
@@ -209,11 +352,76 @@ func (r *Resolver) VisitCallExpr(expr *ast.Call) interface{} {
 	return nil
 }
 
+func (r *Resolver) VisitGetExpr(expr *ast.Get) interface{} {
+	r.resolveExpr(expr.Object)
+	return nil
+}
+
+func (r *Resolver) VisitSetExpr(expr *ast.Set) interface{} {
+	r.resolveExpr(expr.Value)
+	r.resolveExpr(expr.Object)
+	return nil
+}
+
+func (r *Resolver) VisitListExpr(expr *ast.List) interface{} {
+	for _, element := range expr.Elements {
+		r.resolveExpr(element)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitMapExpr(expr *ast.Map) interface{} {
+	for i, key := range expr.Keys {
+		r.resolveExpr(key)
+		r.resolveExpr(expr.Values[i])
+	}
+	return nil
+}
+
+func (r *Resolver) VisitIndexExpr(expr *ast.Index) interface{} {
+	r.resolveExpr(expr.Object)
+	r.resolveExpr(expr.Index)
+	return nil
+}
+
+func (r *Resolver) VisitSetIndexExpr(expr *ast.SetIndex) interface{} {
+	r.resolveExpr(expr.Object)
+	r.resolveExpr(expr.Index)
+	r.resolveExpr(expr.Value)
+	return nil
+}
+
+func (r *Resolver) VisitSuperExpr(expr *ast.Super) interface{} {
+	if r.currentClass == ClassTypeNone {
+		r.log.TokenError(expr.Keyword, "Can't use 'super' outside of a class.")
+	} else if r.currentClass != ClassTypeSubclass {
+		r.log.TokenError(expr.Keyword, "Can't use 'super' in a class with no superclass.")
+	}
+
+	r.resolveLocal(expr, expr.Keyword)
+	return nil
+}
+
+func (r *Resolver) VisitThisExpr(expr *ast.This) interface{} {
+	if r.currentClass == ClassTypeNone {
+		r.log.TokenError(expr.Keyword, "Can't use 'this' outside of a class.")
+		return nil
+	}
+
+	r.resolveLocal(expr, expr.Keyword)
+	return nil
+}
+
 func (r *Resolver) VisitGroupingExpr(expr *ast.Grouping) interface{} {
 	r.resolveExpr(expr.Expression)
 	return nil
 }
 
+func (r *Resolver) VisitLambdaExpr(expr *ast.Lambda) interface{} {
+	r.resolveFunction(expr.Function, FunctionTypeFunction)
+	return nil
+}
+
 func (r *Resolver) VisitLiteralExpr(expr *ast.Literal) interface{} {
 	return nil
 }
@@ -2,32 +2,54 @@ package scanner
 
 import (
 	"fmt"
+	"slices"
 	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/distolma/golox/cmd/myinterpreter/ast"
 	logerror "github.com/distolma/golox/cmd/myinterpreter/log_error"
 )
 
+// Scanner splits UTF-8 source into tokens. Offsets into source are in bytes
+// while columns count runes.
 type Scanner struct {
-	source  string
-	log     *logerror.LogError
-	tokens  []ast.Token
-	current int
-	line    int
-	start   int
+	source      string
+	file        *ast.Source
+	log         *logerror.LogError
+	tokens      []ast.Token
+	current     int
+	line        int
+	start       int
+	column      int
+	startColumn int
+	// trivia is waiting to be attached to the next token. blank is set
+	// while nothing but whitespace has been seen on the current line.
+	trivia []ast.Trivia
+	blank  bool
 }
 
 func NewScanner(source string, log *logerror.LogError) *Scanner {
-	return &Scanner{source: source, line: 1, log: log}
+	return NewFileScanner("", source, log)
+}
+
+// NewFileScanner scans source read from the file name, which tokens and
+// diagnostics refer back to.
+func NewFileScanner(name string, source string, log *logerror.LogError) *Scanner {
+	file := &ast.Source{Name: name, Text: source}
+	return &Scanner{source: source, file: file, line: 1, log: log, blank: true}
 }
 
 func (s *Scanner) ScanTokens() []ast.Token {
+	s.log.Phase = logerror.PhaseScan
 	for !s.isAtEnd() {
 		s.start = s.current
+		s.startColumn = s.column + 1
 		s.scanToken()
 	}
 
-	s.tokens = append(s.tokens, ast.Token{Type: ast.EOF, Line: s.line})
+	s.tokens = append(s.tokens, ast.Token{Type: ast.EOF, Line: s.line, Column: s.column + 1, Offset: s.current, Source: s.file, Trivia: s.trivia})
 	return s.tokens
 }
 
@@ -42,8 +64,14 @@ func (s *Scanner) scanToken() {
 		s.addToken(ast.TLeftBrace)
 	case '}':
 		s.addToken(ast.TRightBrace)
+	case '[':
+		s.addToken(ast.TLeftBracket)
+	case ']':
+		s.addToken(ast.TRightBracket)
 	case ',':
 		s.addToken(ast.TComma)
+	case ':':
+		s.addToken(ast.TColon)
 	case '.':
 		s.addToken(ast.TDot)
 	case '-':
@@ -63,6 +91,8 @@ func (s *Scanner) scanToken() {
 	case '=':
 		if s.match('=') {
 			s.addToken(ast.TEqualEqual)
+		} else if s.match('>') {
+			s.addToken(ast.TArrow)
 		} else {
 			s.addToken(ast.TEqual)
 		}
@@ -83,6 +113,7 @@ func (s *Scanner) scanToken() {
 			for s.peek() != '\n' && !s.isAtEnd() {
 				s.advance()
 			}
+			s.comment()
 		} else {
 			s.addToken(ast.TSlash)
 		}
@@ -91,7 +122,11 @@ func (s *Scanner) scanToken() {
 	case '\t':
 		break
 	case '\n':
-		s.line++
+		// advance has already moved to the next line.
+		if s.blank {
+			s.trivia = append(s.trivia, ast.Trivia{Kind: ast.TriviaBlankLine, Line: s.line - 1, Offset: s.start})
+		}
+		s.blank = true
 	case '"':
 		s.string()
 	default:
@@ -99,30 +134,98 @@ func (s *Scanner) scanToken() {
 			s.number()
 		} else if s.isAlpha(char) {
 			s.identifier()
+		} else if char == utf8.RuneError && s.current-s.start == 1 {
+			// Already reported by advance.
 		} else {
-			s.log.Error(s.line, fmt.Sprintf("Unexpected character: %s", string(char)))
+			s.error(s.start, s.startColumn, fmt.Sprintf("Unexpected character: %s", string(char)))
 		}
 	}
 }
 
+// comment keeps the comment just scanned as trivia for the next token.
+func (s *Scanner) comment() {
+	s.trivia = append(s.trivia, ast.Trivia{
+		Kind:     ast.TriviaComment,
+		Text:     strings.TrimRight(s.source[s.start:s.current], " \t\r"),
+		Line:     s.line,
+		Offset:   s.start,
+		Trailing: !s.blank,
+	})
+	s.blank = false
+}
+
 func (s *Scanner) string() {
+	var value strings.Builder
 	for s.peek() != '"' && !s.isAtEnd() {
-		if s.peek() == '\n' {
-			s.line++
+		start, column := s.current, s.column+1
+		char := s.advance()
+		if char == '\\' {
+			s.escape(&value, start, column)
+		} else if char != utf8.RuneError || s.current-start > 1 {
+			value.WriteRune(char)
 		}
-		s.advance()
 	}
 
 	if s.isAtEnd() {
-		s.log.Error(s.line, "Unterminated string.")
+		s.error(s.start, s.startColumn, "Unterminated string.")
 		return
 	}
 
 	// The closing "
 	s.advance()
 
-	value := s.source[s.start+1 : s.current-1]
-	s.addTokenWithLiteral(ast.TString, value)
+	s.addTokenWithLiteral(ast.TString, value.String())
+}
+
+// escape decodes the escape sequence following the backslash at start in a
+// string.
+func (s *Scanner) escape(value *strings.Builder, start int, column int) {
+	if s.isAtEnd() {
+		return
+	}
+
+	char := s.advance()
+	switch char {
+	case 'n':
+		value.WriteByte('\n')
+	case 't':
+		value.WriteByte('\t')
+	case 'r':
+		value.WriteByte('\r')
+	case '\\', '"':
+		value.WriteRune(char)
+	case 'u':
+		s.unicodeEscape(value, start, column)
+	default:
+		s.error(start, column, fmt.Sprintf("Invalid escape sequence '\\%c'.", char))
+	}
+}
+
+// unicodeEscape decodes the '{XXXX}' of a '\u{XXXX}' escape, which holds one
+// to six hex digits naming a Unicode scalar value.
+func (s *Scanner) unicodeEscape(value *strings.Builder, start int, column int) {
+	if !s.match('{') {
+		s.error(start, column, "Expect '{' after '\\u'.")
+		return
+	}
+
+	digitsStart := s.current
+	for s.peek() != '}' && s.peek() != '"' && !s.isAtEnd() {
+		s.advance()
+	}
+	digits := s.source[digitsStart:s.current]
+
+	if !s.match('}') {
+		s.error(start, column, "Unterminated Unicode escape sequence.")
+		return
+	}
+
+	code, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil || len(digits) > 6 || !utf8.ValidRune(rune(code)) {
+		s.error(start, column, fmt.Sprintf("Invalid Unicode escape sequence '\\u{%s}'.", digits))
+		return
+	}
+	value.WriteRune(rune(code))
 }
 
 func (s *Scanner) number() {
@@ -166,48 +269,89 @@ func (s *Scanner) addTokenWithLiteral(tokenType ast.TokenType, literal interface
 		Type:    tokenType,
 		Literal: literal,
 		Line:    s.line,
+		Column:  s.startColumn,
+		Offset:  s.start,
+		Length:  s.current - s.start,
+		Source:  s.file,
+		Trivia:  s.trivia,
 	}
 	s.tokens = append(s.tokens, token)
+	s.trivia = nil
+	s.blank = false
+}
+
+// error reports a scanning error about the source from offset, at column,
+// up to the current position. The error is on the line offset is on, which
+// is earlier than the current one for a string that spans lines.
+func (s *Scanner) error(offset int, column int, message string) {
+	line := s.line - strings.Count(s.source[offset:s.current], "\n")
+	s.log.ErrorAt(ast.Token{Line: line, Column: column, Offset: offset, Length: s.current - offset, Source: s.file}, message)
+}
+
+// Keywords lists the reserved words in sorted order.
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
+	}
+	slices.Sort(words)
+	return words
 }
 
 var keywords = map[string]ast.TokenType{
-	"and":    ast.TAnd,
-	"class":  ast.TClass,
-	"else":   ast.TElse,
-	"false":  ast.TFalse,
-	"for":    ast.TFor,
-	"fun":    ast.TFun,
-	"if":     ast.TIf,
-	"nil":    ast.TNil,
-	"or":     ast.TOr,
-	"print":  ast.TPrint,
-	"return": ast.TReturn,
-	"super":  ast.TSuper,
-	"this":   ast.TThis,
-	"true":   ast.TTrue,
-	"var":    ast.TVar,
-	"while":  ast.TWhile,
+	"and":      ast.TAnd,
+	"break":    ast.TBreak,
+	"catch":    ast.TCatch,
+	"class":    ast.TClass,
+	"continue": ast.TContinue,
+	"else":     ast.TElse,
+	"false":    ast.TFalse,
+	"finally":  ast.TFinally,
+	"for":      ast.TFor,
+	"fun":      ast.TFun,
+	"if":       ast.TIf,
+	"import":   ast.TImport,
+	"nil":      ast.TNil,
+	"or":       ast.TOr,
+	"print":    ast.TPrint,
+	"return":   ast.TReturn,
+	"super":    ast.TSuper,
+	"this":     ast.TThis,
+	"throw":    ast.TThrow,
+	"true":     ast.TTrue,
+	"try":      ast.TTry,
+	"var":      ast.TVar,
+	"while":    ast.TWhile,
 }
 
 func (s *Scanner) isAtEnd() bool {
 	return s.current >= len(s.source)
 }
 
+// advance consumes one rune, keeping the line and column up to date. A byte
+// that isn't valid UTF-8 is reported and consumed as utf8.RuneError.
 func (s *Scanner) advance() rune {
-	char := rune(s.source[s.current])
-	s.current++
+	char, size := utf8.DecodeRuneInString(s.source[s.current:])
+	s.current += size
+	if char == utf8.RuneError && size == 1 {
+		s.error(s.current-1, s.column+1, "Invalid UTF-8 encoding.")
+	}
+
+	if char == '\n' {
+		s.line++
+		s.column = 0
+	} else {
+		s.column++
+	}
 	return char
 }
 
 func (s *Scanner) match(expected rune) bool {
-	if s.isAtEnd() {
-		return false
-	}
-	if rune(s.source[s.current]) != expected {
+	if s.isAtEnd() || s.peek() != expected {
 		return false
 	}
 
-	s.current++
+	s.advance()
 	return true
 }
 
@@ -215,22 +359,30 @@ func (s *Scanner) peek() rune {
 	if s.isAtEnd() {
 		return '\000'
 	}
-	return rune(s.source[s.current])
+	char, _ := utf8.DecodeRuneInString(s.source[s.current:])
+	return char
 }
 
 func (s *Scanner) peekNext() rune {
-	if s.current+1 >= len(s.source) {
+	if s.isAtEnd() {
 		return '\000'
 	}
-	return rune(s.source[s.current+1])
+	_, size := utf8.DecodeRuneInString(s.source[s.current:])
+	if s.current+size >= len(s.source) {
+		return '\000'
+	}
+	char, _ := utf8.DecodeRuneInString(s.source[s.current+size:])
+	return char
 }
 
 func (s *Scanner) isDigit(char rune) bool {
 	return char >= '0' && char <= '9'
 }
 
+// isAlpha accepts letters from any script so identifiers needn't be ASCII.
 func (s *Scanner) isAlpha(char rune) bool {
-	return (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z') || char == '_'
+	return (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z') || char == '_' ||
+		(char >= utf8.RuneSelf && unicode.IsLetter(char))
 }
 
 func (s *Scanner) isAlphaNumeric(char rune) bool {
@@ -0,0 +1,31 @@
+package scanner
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	logerror "github.com/distolma/golox/cmd/myinterpreter/log_error"
+)
+
+func TestErrorLine(t *testing.T) {
+	tests := map[string]struct {
+		source string
+		want   string
+	}{
+		"unterminated string":  {"var a = 1;\nvar s = \"abc\ndef\n", "[line 2] Error: Unterminated string."},
+		"escape after newline": {"var s = \"a\n\\q\";\n", "[line 2] Error: Invalid escape sequence '\\q'."},
+		"invalid utf-8":        {"var a = 1;\n\xff;\n", "[line 2] Error: Invalid UTF-8 encoding."},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var output bytes.Buffer
+			NewScanner(test.source, &logerror.LogError{Output: &output}).ScanTokens()
+
+			if first, _, _ := strings.Cut(output.String(), "\n"); first != test.want {
+				t.Errorf("first error = %q, want %q", first, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package vm
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/distolma/golox/cmd/myinterpreter/compiler"
+	"github.com/distolma/golox/cmd/myinterpreter/module"
+)
+
+// ModuleLoader scans, parses and compiles the module at path, reporting any
+// errors to the log. It returns false if the module has errors.
+type ModuleLoader func(path string) (*compiler.Function, bool)
+
+// Module holds the globals of a file. Its top-level names are read as
+// properties once it is imported.
+type Module struct {
+	Name    string
+	Path    string
+	Globals map[string]interface{}
+}
+
+func NewModule(path string) *Module {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return &Module{Name: name, Path: path, Globals: make(map[string]interface{})}
+}
+
+// Lookup finds a top-level name of the module.
+func (m *Module) Lookup(name string) (interface{}, bool) {
+	value, ok := m.Globals[name]
+	return value, ok
+}
+
+// Names lists the top-level names of the module in sorted order.
+func (m *Module) Names() []string {
+	names := make([]string, 0, len(m.Globals))
+	for name := range m.Globals {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func (m *Module) String() string {
+	return "<module " + m.Name + ">"
+}
+
+// SetScriptPath names the file being run, against which its imports are
+// resolved.
+func (vm *VM) SetScriptPath(path string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	vm.main.Path = path
+	return vm.modules.Enter(path)
+}
+
+// Globals returns the top-level names of the main script. Natives aren't
+// among them.
+func (vm *VM) Globals() map[string]interface{} {
+	return vm.main.Globals
+}
+
+// GlobalNames lists the top-level names of the main script in sorted order.
+// Natives aren't among them.
+func (vm *VM) GlobalNames() []string {
+	return vm.main.Names()
+}
+
+// SetSearchPath sets the directories searched for imports that aren't found
+// next to the importing file.
+func (vm *VM) SetSearchPath(directories []string) {
+	vm.searchPath = directories
+}
+
+func (vm *VM) SetModuleLoader(loader ModuleLoader) {
+	vm.loader = loader
+}
+
+// importModule runs the module named by path the first time it is imported
+// and returns it. The module's script runs to completion in frames above the
+// current one.
+func (vm *VM) importModule(frame *CallFrame, path string) *Module {
+	resolved, err := module.Resolve(path, frame.closure.Module.Path, vm.searchPath)
+	if err != nil {
+		vm.runtimeError(frame, err.Error())
+	}
+
+	if imported, ok := vm.modules.Lookup(resolved); ok {
+		return imported.(*Module)
+	}
+
+	if err := vm.modules.Enter(resolved); err != nil {
+		vm.runtimeError(frame, err.Error())
+	}
+	var loaded interface{}
+	defer func() {
+		vm.modules.Exit(loaded)
+	}()
+
+	if vm.loader == nil {
+		vm.runtimeError(frame, "Can't import modules here.")
+	}
+	function, ok := vm.loader(resolved)
+	if !ok {
+		vm.runtimeError(frame, fmt.Sprintf("Could not load module '%s'.", path))
+	}
+
+	imported := NewModule(resolved)
+	closure := NewClosure(function, imported)
+	base := len(vm.frames)
+	vm.push(closure)
+	vm.call(closure, 0)
+	vm.run(base)
+
+	loaded = imported
+	return imported
+}
+
+// getModuleProperty reads the top-level name of a module, reporting a
+// runtime error if the module doesn't define it.
+func (vm *VM) getModuleProperty(frame *CallFrame, m *Module, name string) interface{} {
+	value, ok := m.Globals[name]
+	if !ok {
+		vm.runtimeError(frame, fmt.Sprintf("Module '%s' has no export '%s'.", m.Name, name))
+	}
+	return value
+}
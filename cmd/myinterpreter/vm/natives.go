@@ -0,0 +1,36 @@
+package vm
+
+import (
+	"slices"
+	"time"
+
+	"github.com/distolma/golox/cmd/myinterpreter/collection"
+)
+
+func (vm *VM) defineNatives() {
+	vm.DefineNative("clock", 0, func(_arguments []interface{}) (interface{}, error) {
+		return float64(time.Now().UnixMilli() / 1000), nil
+	})
+	for _, native := range collection.Natives {
+		function := native.Function
+		vm.DefineNative(native.Name, native.Arity, func(arguments []interface{}) (interface{}, error) {
+			return function(vm.meter, arguments)
+		})
+	}
+}
+
+// NativeNames lists the names of the natives in sorted order.
+func (vm *VM) NativeNames() []string {
+	names := make([]string, 0, len(vm.builtins))
+	for name := range vm.builtins {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// DefineNative binds a Go function to a global name. Errors it returns are
+// raised as runtime errors at the call site.
+func (vm *VM) DefineNative(name string, arity int, function NativeFunction) {
+	vm.builtins[name] = &Native{Name: name, Arity: arity, Function: function}
+}
@@ -0,0 +1,112 @@
+package vm
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/distolma/golox/cmd/myinterpreter/ast"
+	"github.com/distolma/golox/cmd/myinterpreter/compiler"
+)
+
+// Closure is a function together with its captured variables and the module
+// whose globals it reads.
+type Closure struct {
+	Function *compiler.Function
+	Upvalues []*Upvalue
+	Module   *Module
+}
+
+func NewClosure(function *compiler.Function, module *Module) *Closure {
+	return &Closure{Function: function, Upvalues: make([]*Upvalue, function.UpvalueCount), Module: module}
+}
+
+func (c *Closure) String() string {
+	return c.Function.String()
+}
+
+// Upvalue refers to a variable captured by a closure. While the variable is
+// still on the stack it is addressed by slot; once the slot is popped the
+// value moves into the upvalue itself.
+type Upvalue struct {
+	slot   int
+	closed interface{}
+	isOpen bool
+	next   *Upvalue
+}
+
+// VariadicArity marks a native that accepts any number of arguments.
+const VariadicArity = -1
+
+type NativeFunction func(arguments []interface{}) (interface{}, error)
+
+type Native struct {
+	Name     string
+	Arity    int
+	Function NativeFunction
+}
+
+func (n *Native) String() string {
+	return "<native fn>"
+}
+
+type Class struct {
+	Name    string
+	Methods map[string]*Closure
+}
+
+func NewClass(name string) *Class {
+	return &Class{Name: name, Methods: make(map[string]*Closure)}
+}
+
+func (c *Class) String() string {
+	return c.Name
+}
+
+type Instance struct {
+	Class  *Class
+	Fields map[string]interface{}
+	// origin is where an error object was raised, so that throwing it again
+	// reports the same position.
+	origin ast.Token
+}
+
+func NewInstance(class *Class) *Instance {
+	return &Instance{Class: class, Fields: make(map[string]interface{})}
+}
+
+func (i *Instance) String() string {
+	return fmt.Sprintf("%s instance", i.Class.Name)
+}
+
+// Property finds a field of the instance, or else a method of its class,
+// without binding it.
+func (i *Instance) Property(name string) (interface{}, bool) {
+	if field, ok := i.Fields[name]; ok {
+		return field, true
+	}
+	method, ok := i.Class.Methods[name]
+	return method, ok
+}
+
+// PropertyNames lists the fields of the instance and the methods of its
+// class, including inherited ones, in sorted order.
+func (i *Instance) PropertyNames() []string {
+	names := make([]string, 0, len(i.Fields)+len(i.Class.Methods))
+	for name := range i.Fields {
+		names = append(names, name)
+	}
+	for name := range i.Class.Methods {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+type BoundMethod struct {
+	Receiver interface{}
+	Method   *Closure
+}
+
+func (b *BoundMethod) String() string {
+	return b.Method.String()
+}
@@ -0,0 +1,67 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/distolma/golox/cmd/myinterpreter/ast"
+	"github.com/distolma/golox/cmd/myinterpreter/limits"
+)
+
+type RuntimeError struct {
+	Message string
+	Token   ast.Token
+	// Code is set when the error reports an exceeded resource limit. Such
+	// errors can't be caught.
+	Code string
+	// Value is the value of the throw statement that raised the error.
+	Value interface{}
+}
+
+func NewRuntimeError(token ast.Token, message string) RuntimeError {
+	return RuntimeError{Token: token, Message: message}
+}
+
+func NewLimitError(token ast.Token, violation *limits.Violation) RuntimeError {
+	return RuntimeError{Token: token, Message: violation.Message, Code: violation.Code}
+}
+
+// NewNativeError raises an error returned by Go code at token. Exceeded
+// limits keep their code.
+func NewNativeError(token ast.Token, err error) RuntimeError {
+	var violation *limits.Violation
+	if errors.As(err, &violation) {
+		return NewLimitError(token, violation)
+	}
+	return NewRuntimeError(token, err.Error())
+}
+
+// errorClass is the class of the objects bound by catch clauses.
+var errorClass = NewClass("Error")
+
+// newErrorObject describes a caught error to the catch clause.
+func newErrorObject(err RuntimeError) *Instance {
+	instance := NewInstance(errorClass)
+	instance.origin = err.Token
+	instance.Fields["message"] = err.Message
+	instance.Fields["line"] = float64(err.Token.Line)
+	instance.Fields["value"] = err.Value
+	return instance
+}
+
+// NewThrowError raises value from a throw statement. Throwing a caught error
+// object raises the original error again.
+func NewThrowError(token ast.Token, value interface{}) RuntimeError {
+	if instance, ok := value.(*Instance); ok && instance.Class == errorClass {
+		message, _ := instance.Fields["message"].(string)
+		line, _ := instance.Fields["line"].(float64)
+		origin := instance.origin
+		origin.Line = int(line)
+		return RuntimeError{Token: origin, Message: message, Value: instance.Fields["value"]}
+	}
+	return RuntimeError{Token: token, Message: stringify(value), Value: value}
+}
+
+func (re *RuntimeError) Error() string {
+	return fmt.Sprintf("%s\n[line %d]", re.Message, re.Token.Line)
+}
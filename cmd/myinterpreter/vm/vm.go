@@ -0,0 +1,754 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/distolma/golox/cmd/myinterpreter/ast"
+	"github.com/distolma/golox/cmd/myinterpreter/collection"
+	"github.com/distolma/golox/cmd/myinterpreter/compiler"
+	"github.com/distolma/golox/cmd/myinterpreter/limits"
+	logerror "github.com/distolma/golox/cmd/myinterpreter/log_error"
+	"github.com/distolma/golox/cmd/myinterpreter/module"
+)
+
+type CallFrame struct {
+	closure *Closure
+	chunk   *compiler.Chunk
+	ip      int
+	slots   int
+}
+
+// handler is the catch or finally code installed by a try statement.
+type handler struct {
+	frames int
+	stack  int
+	ip     int
+}
+
+type VM struct {
+	log          *logerror.LogError
+	stack        []interface{}
+	frames       []CallFrame
+	handlers     []handler
+	builtins     map[string]interface{}
+	main         *Module
+	modules      *module.Registry
+	loader       ModuleLoader
+	searchPath   []string
+	openUpvalues *Upvalue
+	stdout       io.Writer
+	meter        *limits.Meter
+	ctx          context.Context
+}
+
+func NewVM(log *logerror.LogError) *VM {
+	vm := &VM{
+		log:   log,
+		stack: make([]interface{}, 0, 256),
+		// Natives are visible from the globals of every module.
+		builtins: make(map[string]interface{}),
+		main:     &Module{Name: "main", Globals: make(map[string]interface{})},
+		modules:  module.NewRegistry(),
+		stdout:   os.Stdout,
+		meter:    limits.NewMeter(limits.Limits{}),
+		ctx:      context.Background(),
+	}
+	vm.defineNatives()
+
+	return vm
+}
+
+// SetStdout redirects the output of print statements.
+func (vm *VM) SetStdout(w io.Writer) {
+	vm.stdout = w
+}
+
+// SetLimits bounds the resources used by each subsequent run.
+func (vm *VM) SetLimits(l limits.Limits) {
+	vm.meter = limits.NewMeter(l)
+}
+
+// Interpret runs a compiled script and returns the value the script
+// function returned, which is nil unless it was compiled with CompileEval.
+// The run stops with a runtime error once ctx is done.
+func (vm *VM) Interpret(ctx context.Context, function *compiler.Function) (result interface{}) {
+	vm.ctx = ctx
+	defer func() {
+		vm.ctx = context.Background()
+	}()
+	defer func() {
+		if err := recover(); err != nil {
+			if runtimeError, ok := err.(RuntimeError); ok {
+				vm.log.RuntimeErrorWithCode(runtimeError.Token, runtimeError.Code, runtimeError.Message)
+				vm.resetStack()
+				result = nil
+			} else {
+				panic(err)
+			}
+		}
+	}()
+
+	vm.meter.Reset()
+
+	closure := NewClosure(function, vm.main)
+	vm.push(closure)
+	vm.call(closure, 0)
+
+	return vm.run(0)
+}
+
+// Call invokes a Lox callable from Go, running it to completion. Runtime
+// errors raised by the callee are returned instead of being reported to the
+// log.
+func (vm *VM) Call(callee interface{}, arguments []interface{}) (result interface{}, err error) {
+	frames := len(vm.frames)
+	stack := len(vm.stack)
+	handlers := len(vm.handlers)
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			if runtimeError, ok := recovered.(RuntimeError); ok {
+				vm.closeUpvalues(stack)
+				vm.frames = vm.frames[:frames]
+				vm.stack = vm.stack[:stack]
+				vm.handlers = vm.handlers[:handlers]
+				result, err = nil, &runtimeError
+			} else {
+				panic(recovered)
+			}
+		}
+	}()
+
+	vm.push(callee)
+	for _, argument := range arguments {
+		vm.push(argument)
+	}
+
+	var frame *CallFrame
+	if frames > 0 {
+		frame = &vm.frames[frames-1]
+	}
+	vm.callValue(frame, callee, len(arguments))
+
+	if len(vm.frames) > frames {
+		return vm.run(frames), nil
+	}
+
+	// Natives and classes without an initializer complete immediately.
+	return vm.pop(), nil
+}
+
+// IsCallable reports whether value can be called from Lox.
+func IsCallable(value interface{}) bool {
+	switch value.(type) {
+	case *Closure, *BoundMethod, *Class, *Native:
+		return true
+	}
+	return false
+}
+
+// run executes instructions until the frame stack unwinds back to base
+// frames. Runtime errors resume execution at the innermost handler installed
+// above base, if there is one.
+func (vm *VM) run(base int) interface{} {
+	for {
+		if result, finished := vm.runUntilCaught(base); finished {
+			return result
+		}
+	}
+}
+
+func (vm *VM) runUntilCaught(base int) (result interface{}, finished bool) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			runtimeError, ok := recovered.(RuntimeError)
+			if !ok || !vm.catch(base, runtimeError) {
+				panic(recovered)
+			}
+		}
+	}()
+
+	return vm.execute(base), true
+}
+
+// catch unwinds to the innermost handler installed above base and pushes the
+// error object for it. Errors from exceeded limits are never caught.
+func (vm *VM) catch(base int, err RuntimeError) bool {
+	if err.Code != "" || len(vm.handlers) == 0 {
+		return false
+	}
+
+	handler := vm.handlers[len(vm.handlers)-1]
+	if handler.frames <= base {
+		return false
+	}
+
+	vm.handlers = vm.handlers[:len(vm.handlers)-1]
+	vm.closeUpvalues(handler.stack)
+	vm.frames = vm.frames[:handler.frames]
+	vm.stack = vm.stack[:handler.stack]
+	vm.push(newErrorObject(err))
+	vm.frames[handler.frames-1].ip = handler.ip
+	return true
+}
+
+func (vm *VM) execute(base int) interface{} {
+	frame := &vm.frames[len(vm.frames)-1]
+
+	for {
+		instruction := compiler.OpCode(vm.readByte(frame))
+
+		if violation := vm.meter.Step(); violation != nil {
+			panic(NewLimitError(vm.frameToken(frame), violation))
+		}
+
+		switch instruction {
+		case compiler.OpConstant:
+			vm.push(vm.readConstant(frame))
+		case compiler.OpNil:
+			vm.push(nil)
+		case compiler.OpTrue:
+			vm.push(true)
+		case compiler.OpFalse:
+			vm.push(false)
+		case compiler.OpPop:
+			vm.pop()
+		case compiler.OpGetLocal:
+			slot := int(vm.readByte(frame))
+			vm.push(vm.stack[frame.slots+slot])
+		case compiler.OpSetLocal:
+			slot := int(vm.readByte(frame))
+			vm.stack[frame.slots+slot] = vm.peek(0)
+		case compiler.OpGetGlobal:
+			name := vm.readString(frame)
+			value, ok := frame.closure.Module.Globals[name]
+			if !ok {
+				value, ok = vm.builtins[name]
+			}
+			if !ok {
+				vm.runtimeError(frame, fmt.Sprintf("Undefined variable '%s'.", name))
+			}
+			vm.push(value)
+		case compiler.OpDefineGlobal:
+			name := vm.readString(frame)
+			frame.closure.Module.Globals[name] = vm.pop()
+		case compiler.OpSetGlobal:
+			name := vm.readString(frame)
+			globals := frame.closure.Module.Globals
+			if _, ok := globals[name]; !ok {
+				if _, ok := vm.builtins[name]; !ok {
+					vm.runtimeError(frame, fmt.Sprintf("Undefined variable '%s'.", name))
+				}
+				globals = vm.builtins
+			}
+			globals[name] = vm.peek(0)
+		case compiler.OpGetUpvalue:
+			slot := vm.readByte(frame)
+			vm.push(vm.getUpvalue(frame.closure.Upvalues[slot]))
+		case compiler.OpSetUpvalue:
+			slot := vm.readByte(frame)
+			vm.setUpvalue(frame.closure.Upvalues[slot], vm.peek(0))
+		case compiler.OpGetProperty:
+			name := vm.readString(frame)
+			if module, ok := vm.peek(0).(*Module); ok {
+				value := vm.getModuleProperty(frame, module, name)
+				vm.pop()
+				vm.push(value)
+				break
+			}
+
+			instance, ok := vm.peek(0).(*Instance)
+			if !ok {
+				vm.runtimeError(frame, "Only instances have properties.")
+			}
+
+			if value, ok := instance.Fields[name]; ok {
+				vm.pop()
+				vm.push(value)
+				break
+			}
+
+			vm.bindMethod(frame, instance.Class, name)
+		case compiler.OpSetProperty:
+			instance, ok := vm.peek(1).(*Instance)
+			name := vm.readString(frame)
+			if !ok {
+				vm.runtimeError(frame, "Only instances have fields.")
+			}
+
+			if _, ok := instance.Fields[name]; !ok {
+				vm.allocate(frame, limits.SizeField)
+			}
+
+			value := vm.pop()
+			instance.Fields[name] = value
+			vm.pop()
+			vm.push(value)
+		case compiler.OpList:
+			count := vm.readShort(frame)
+			vm.allocate(frame, limits.SizeList+count*limits.SizeElement)
+
+			elements := make([]interface{}, count)
+			copy(elements, vm.stack[len(vm.stack)-count:])
+			vm.popN(count)
+			vm.push(collection.NewList(elements))
+		case compiler.OpMap:
+			count := vm.readShort(frame)
+			entries := vm.stack[len(vm.stack)-2*count:]
+
+			result := collection.NewMap()
+			for i := 0; i < len(entries); i += 2 {
+				if err := result.Set(entries[i], entries[i+1]); err != nil {
+					vm.runtimeError(frame, err.Error())
+				}
+			}
+			vm.allocate(frame, limits.SizeMap+result.Len()*limits.SizeEntry)
+
+			vm.popN(2 * count)
+			vm.push(result)
+		case compiler.OpGetIndex:
+			value, err := collection.Index(vm.peek(1), vm.peek(0))
+			if err != nil {
+				vm.runtimeError(frame, err.Error())
+			}
+			vm.popN(2)
+			vm.push(value)
+		case compiler.OpSetIndex:
+			value := vm.peek(0)
+			if err := collection.SetIndex(vm.meter, vm.peek(2), vm.peek(1), value); err != nil {
+				panic(NewNativeError(vm.frameToken(frame), err))
+			}
+			vm.popN(3)
+			vm.push(value)
+		case compiler.OpTry:
+			offset := vm.readShort(frame)
+			vm.handlers = append(vm.handlers, handler{frames: len(vm.frames), stack: len(vm.stack), ip: frame.ip + offset})
+		case compiler.OpEndTry:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+		case compiler.OpImport:
+			path := vm.readString(frame)
+			imported := vm.importModule(frame, path)
+			frame = &vm.frames[len(vm.frames)-1]
+			vm.push(imported)
+		case compiler.OpThrow:
+			panic(NewThrowError(vm.frameToken(frame), vm.pop()))
+		case compiler.OpGetSuper:
+			name := vm.readString(frame)
+			superclass, ok := vm.pop().(*Class)
+			if !ok {
+				vm.runtimeError(frame, "Superclass must be a class.")
+			}
+			vm.bindMethod(frame, superclass, name)
+		case compiler.OpEqual:
+			b := vm.pop()
+			a := vm.pop()
+			vm.push(a == b)
+		case compiler.OpNotEqual:
+			b := vm.pop()
+			a := vm.pop()
+			vm.push(a != b)
+		case compiler.OpGreater:
+			a, b := vm.popNumberOperands(frame)
+			vm.push(a > b)
+		case compiler.OpGreaterEqual:
+			a, b := vm.popNumberOperands(frame)
+			vm.push(a >= b)
+		case compiler.OpLess:
+			a, b := vm.popNumberOperands(frame)
+			vm.push(a < b)
+		case compiler.OpLessEqual:
+			a, b := vm.popNumberOperands(frame)
+			vm.push(a <= b)
+		case compiler.OpAdd:
+			leftFloat, leftOk := vm.peek(1).(float64)
+			rightFloat, rightOk := vm.peek(0).(float64)
+			if leftOk && rightOk {
+				vm.popN(2)
+				vm.push(leftFloat + rightFloat)
+				break
+			}
+
+			leftString, leftOk := vm.peek(1).(string)
+			rightString, rightOk := vm.peek(0).(string)
+			if leftOk && rightOk {
+				vm.allocate(frame, len(leftString)+len(rightString))
+				vm.popN(2)
+				vm.push(leftString + rightString)
+				break
+			}
+
+			vm.runtimeError(frame, "Operands must be two numbers or two strings.")
+		case compiler.OpSubtract:
+			a, b := vm.popNumberOperands(frame)
+			vm.push(a - b)
+		case compiler.OpMultiply:
+			a, b := vm.popNumberOperands(frame)
+			// Matches the tree-walker, which rejects a zero right operand.
+			if b == 0 {
+				vm.runtimeError(frame, "Division by zero.")
+			}
+			vm.push(a * b)
+		case compiler.OpDivide:
+			a, b := vm.popNumberOperands(frame)
+			vm.push(a / b)
+		case compiler.OpNot:
+			vm.push(!isTruthy(vm.pop()))
+		case compiler.OpNegate:
+			value, ok := vm.peek(0).(float64)
+			if !ok {
+				vm.runtimeError(frame, "Operand must be a number.")
+			}
+			vm.pop()
+			vm.push(-value)
+		case compiler.OpPrint:
+			fmt.Fprintln(vm.stdout, stringify(vm.pop()))
+		case compiler.OpJump:
+			offset := vm.readShort(frame)
+			frame.ip += offset
+		case compiler.OpJumpIfFalse:
+			offset := vm.readShort(frame)
+			if !isTruthy(vm.peek(0)) {
+				frame.ip += offset
+			}
+		case compiler.OpLoop:
+			offset := vm.readShort(frame)
+			vm.checkContext(frame)
+			frame.ip -= offset
+		case compiler.OpCall:
+			argCount := int(vm.readByte(frame))
+			vm.callValue(frame, vm.peek(argCount), argCount)
+			frame = &vm.frames[len(vm.frames)-1]
+		case compiler.OpInvoke:
+			method := vm.readString(frame)
+			argCount := int(vm.readByte(frame))
+			vm.invoke(frame, method, argCount)
+			frame = &vm.frames[len(vm.frames)-1]
+		case compiler.OpSuperInvoke:
+			method := vm.readString(frame)
+			argCount := int(vm.readByte(frame))
+			superclass, ok := vm.pop().(*Class)
+			if !ok {
+				vm.runtimeError(frame, "Superclass must be a class.")
+			}
+			vm.invokeFromClass(frame, superclass, method, argCount)
+			frame = &vm.frames[len(vm.frames)-1]
+		case compiler.OpClosure:
+			function := vm.readConstant(frame).(*compiler.Function)
+			vm.allocate(frame, limits.SizeFunction)
+			closure := NewClosure(function, frame.closure.Module)
+			vm.push(closure)
+
+			for i := range closure.Upvalues {
+				isLocal := vm.readByte(frame)
+				index := int(vm.readByte(frame))
+				if isLocal == 1 {
+					closure.Upvalues[i] = vm.captureUpvalue(frame.slots + index)
+				} else {
+					closure.Upvalues[i] = frame.closure.Upvalues[index]
+				}
+			}
+		case compiler.OpCloseUpvalue:
+			vm.closeUpvalues(len(vm.stack) - 1)
+			vm.pop()
+		case compiler.OpReturn:
+			// Handlers left installed by a return inside a try die with the
+			// frame.
+			for len(vm.handlers) > 0 && vm.handlers[len(vm.handlers)-1].frames >= len(vm.frames) {
+				vm.handlers = vm.handlers[:len(vm.handlers)-1]
+			}
+
+			result := vm.pop()
+			vm.closeUpvalues(frame.slots)
+			vm.frames = vm.frames[:len(vm.frames)-1]
+			vm.stack = vm.stack[:frame.slots]
+
+			if len(vm.frames) == base {
+				return result
+			}
+
+			vm.push(result)
+			frame = &vm.frames[len(vm.frames)-1]
+		case compiler.OpClass:
+			vm.allocate(frame, limits.SizeClass)
+			vm.push(NewClass(vm.readString(frame)))
+		case compiler.OpInherit:
+			superclass, ok := vm.peek(1).(*Class)
+			if !ok {
+				vm.runtimeError(frame, "Superclass must be a class.")
+			}
+
+			subclass, ok := vm.peek(0).(*Class)
+			if !ok {
+				vm.runtimeError(frame, "Only classes can inherit.")
+			}
+			for name, method := range superclass.Methods {
+				subclass.Methods[name] = method
+			}
+			vm.pop()
+		case compiler.OpMethod:
+			name := vm.readString(frame)
+			method, isClosure := vm.peek(0).(*Closure)
+			class, isClass := vm.peek(1).(*Class)
+			if !isClosure || !isClass {
+				vm.runtimeError(frame, "Methods must be functions defined in a class.")
+			}
+			class.Methods[name] = method
+			vm.pop()
+		}
+	}
+}
+
+func (vm *VM) readByte(frame *CallFrame) byte {
+	b := frame.chunk.Code[frame.ip]
+	frame.ip++
+	return b
+}
+
+func (vm *VM) readShort(frame *CallFrame) int {
+	frame.ip += 2
+	return int(frame.chunk.Code[frame.ip-2])<<8 | int(frame.chunk.Code[frame.ip-1])
+}
+
+func (vm *VM) readConstant(frame *CallFrame) interface{} {
+	return frame.chunk.Constants[vm.readShort(frame)]
+}
+
+func (vm *VM) readString(frame *CallFrame) string {
+	return vm.readConstant(frame).(string)
+}
+
+func (vm *VM) push(value interface{}) {
+	vm.stack = append(vm.stack, value)
+}
+
+func (vm *VM) pop() interface{} {
+	value := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+	return value
+}
+
+func (vm *VM) popN(n int) {
+	vm.stack = vm.stack[:len(vm.stack)-n]
+}
+
+func (vm *VM) peek(distance int) interface{} {
+	return vm.stack[len(vm.stack)-1-distance]
+}
+
+func (vm *VM) popNumberOperands(frame *CallFrame) (float64, float64) {
+	left, leftOk := vm.peek(1).(float64)
+	right, rightOk := vm.peek(0).(float64)
+	if !leftOk || !rightOk {
+		vm.runtimeError(frame, "Operands must be numbers.")
+	}
+
+	vm.popN(2)
+	return left, right
+}
+
+func (vm *VM) callValue(frame *CallFrame, callee interface{}, argCount int) {
+	switch callee := callee.(type) {
+	case *BoundMethod:
+		vm.stack[len(vm.stack)-argCount-1] = callee.Receiver
+		vm.call(callee.Method, argCount)
+	case *Class:
+		vm.allocate(frame, limits.SizeInstance)
+		vm.stack[len(vm.stack)-argCount-1] = NewInstance(callee)
+		if initializer, ok := callee.Methods["init"]; ok {
+			vm.call(initializer, argCount)
+		} else if argCount != 0 {
+			vm.runtimeError(frame, fmt.Sprintf("Expected 0 arguments but got %d.", argCount))
+		}
+	case *Closure:
+		vm.call(callee, argCount)
+	case *Native:
+		if callee.Arity != VariadicArity && argCount != callee.Arity {
+			vm.runtimeError(frame, fmt.Sprintf("Expected %d arguments but got %d.", callee.Arity, argCount))
+		}
+
+		result, err := callee.Function(vm.stack[len(vm.stack)-argCount:])
+		if err != nil {
+			panic(NewNativeError(vm.frameToken(frame), err))
+		}
+
+		vm.popN(argCount + 1)
+		vm.push(result)
+	default:
+		vm.runtimeError(frame, "Can only call functions and classes.")
+	}
+}
+
+func (vm *VM) call(closure *Closure, argCount int) {
+	if argCount != closure.Function.Arity {
+		vm.runtimeError(vm.currentFrame(), fmt.Sprintf("Expected %d arguments but got %d.", closure.Function.Arity, argCount))
+	}
+
+	if violation := vm.meter.CheckDepth(len(vm.frames)); violation != nil {
+		panic(NewLimitError(vm.frameToken(vm.currentFrame()), violation))
+	}
+	vm.checkContext(vm.currentFrame())
+
+	vm.frames = append(vm.frames, CallFrame{
+		closure: closure,
+		chunk:   &closure.Function.Chunk,
+		slots:   len(vm.stack) - argCount - 1,
+	})
+}
+
+func (vm *VM) invoke(frame *CallFrame, name string, argCount int) {
+	if module, ok := vm.peek(argCount).(*Module); ok {
+		value := vm.getModuleProperty(frame, module, name)
+		vm.stack[len(vm.stack)-argCount-1] = value
+		vm.callValue(frame, value, argCount)
+		return
+	}
+
+	instance, ok := vm.peek(argCount).(*Instance)
+	if !ok {
+		vm.runtimeError(frame, "Only instances have properties.")
+	}
+
+	if value, ok := instance.Fields[name]; ok {
+		vm.stack[len(vm.stack)-argCount-1] = value
+		vm.callValue(frame, value, argCount)
+		return
+	}
+
+	vm.invokeFromClass(frame, instance.Class, name, argCount)
+}
+
+func (vm *VM) invokeFromClass(frame *CallFrame, class *Class, name string, argCount int) {
+	method, ok := class.Methods[name]
+	if !ok {
+		vm.runtimeError(frame, fmt.Sprintf("Undefined property '%s'.", name))
+	}
+	vm.call(method, argCount)
+}
+
+func (vm *VM) bindMethod(frame *CallFrame, class *Class, name string) {
+	method, ok := class.Methods[name]
+	if !ok {
+		vm.runtimeError(frame, fmt.Sprintf("Undefined property '%s'.", name))
+	}
+
+	bound := &BoundMethod{Receiver: vm.peek(0), Method: method}
+	vm.pop()
+	vm.push(bound)
+}
+
+func (vm *VM) captureUpvalue(slot int) *Upvalue {
+	var previous *Upvalue
+	upvalue := vm.openUpvalues
+	for upvalue != nil && upvalue.slot > slot {
+		previous = upvalue
+		upvalue = upvalue.next
+	}
+
+	if upvalue != nil && upvalue.slot == slot {
+		return upvalue
+	}
+
+	created := &Upvalue{slot: slot, isOpen: true, next: upvalue}
+	if previous == nil {
+		vm.openUpvalues = created
+	} else {
+		previous.next = created
+	}
+
+	return created
+}
+
+func (vm *VM) closeUpvalues(last int) {
+	for vm.openUpvalues != nil && vm.openUpvalues.slot >= last {
+		upvalue := vm.openUpvalues
+		upvalue.closed = vm.stack[upvalue.slot]
+		upvalue.isOpen = false
+		vm.openUpvalues = upvalue.next
+	}
+}
+
+func (vm *VM) getUpvalue(upvalue *Upvalue) interface{} {
+	if upvalue.isOpen {
+		return vm.stack[upvalue.slot]
+	}
+	return upvalue.closed
+}
+
+func (vm *VM) setUpvalue(upvalue *Upvalue, value interface{}) {
+	if upvalue.isOpen {
+		vm.stack[upvalue.slot] = value
+		return
+	}
+	upvalue.closed = value
+}
+
+func (vm *VM) currentFrame() *CallFrame {
+	if len(vm.frames) == 0 {
+		return nil
+	}
+	return &vm.frames[len(vm.frames)-1]
+}
+
+func (vm *VM) resetStack() {
+	vm.stack = vm.stack[:0]
+	vm.frames = vm.frames[:0]
+	vm.handlers = vm.handlers[:0]
+	vm.openUpvalues = nil
+}
+
+func (vm *VM) runtimeError(frame *CallFrame, message string) {
+	panic(NewRuntimeError(vm.frameToken(frame), message))
+}
+
+func (vm *VM) allocate(frame *CallFrame, bytes int) {
+	if violation := vm.meter.Allocate(bytes); violation != nil {
+		panic(NewLimitError(vm.frameToken(frame), violation))
+	}
+}
+
+// checkContext stops the run once its context is done. It is called on loop
+// back-edges and calls.
+func (vm *VM) checkContext(frame *CallFrame) {
+	select {
+	case <-vm.ctx.Done():
+		panic(NewLimitError(vm.frameToken(frame), limits.Interrupted(vm.ctx.Err())))
+	default:
+	}
+}
+
+// frameToken stands in for the token at the current instruction of frame.
+func (vm *VM) frameToken(frame *CallFrame) ast.Token {
+	// Calls made from Go before any script has run have no frame to blame.
+	if frame == nil || frame.ip == 0 {
+		return ast.Token{}
+	}
+
+	token := ast.Token{Line: frame.chunk.Lines[frame.ip-1]}
+	if frame.ip <= len(frame.chunk.Spans) {
+		span := frame.chunk.Spans[frame.ip-1]
+		token.Column, token.Offset, token.Length = span.Column, span.Offset, span.Length
+		token.Source = frame.chunk.Source
+	}
+	return token
+}
+
+func isTruthy(value interface{}) bool {
+	if value == nil {
+		return false
+	}
+	if v, ok := value.(bool); ok {
+		return v
+	}
+
+	return true
+}
+
+func stringify(value interface{}) string {
+	if value == nil {
+		return "nil"
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,120 @@
+package lox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/distolma/golox/cmd/myinterpreter/ast"
+	"github.com/distolma/golox/cmd/myinterpreter/limits"
+	logerror "github.com/distolma/golox/cmd/myinterpreter/log_error"
+)
+
+// Errors matched by errors.Is when a run exceeds its Limits.
+var (
+	ErrStepLimit   = errors.New("step limit exceeded")
+	ErrCallDepth   = errors.New("call depth exceeded")
+	ErrMemoryLimit = errors.New("memory limit exceeded")
+)
+
+// Diagnostic is a single scanner, parser or resolver error.
+type Diagnostic struct {
+	Line    int
+	Where   string
+	Message string
+	Position
+}
+
+// Position locates an error more precisely than its line. File is empty for
+// source passed to Eval or Run, and the other fields are zero when the error
+// is only known to the line. EndColumn is just past the last character.
+type Position struct {
+	File      string
+	Column    int
+	EndLine   int
+	EndColumn int
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("[line %d] Error%s: %s", d.Line, d.Where, d.Message)
+}
+
+// CompileError is returned when a script fails to scan, parse or resolve.
+type CompileError struct {
+	Diagnostics []Diagnostic
+}
+
+func (e *CompileError) Error() string {
+	lines := make([]string, len(e.Diagnostics))
+	for i, diagnostic := range e.Diagnostics {
+		lines[i] = diagnostic.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// RuntimeError is returned when a script fails while executing.
+type RuntimeError struct {
+	Line    int
+	Message string
+	// Code identifies errors raised by exceeded limits or a done context; it
+	// is empty otherwise.
+	Code string
+	Position
+}
+
+func (e *RuntimeError) Error() string {
+	return fmt.Sprintf("%s\n[line %d]", e.Message, e.Line)
+}
+
+func (e *RuntimeError) Unwrap() error {
+	switch e.Code {
+	case limits.CodeStepLimit:
+		return ErrStepLimit
+	case limits.CodeCallDepth:
+		return ErrCallDepth
+	case limits.CodeMemoryLimit:
+		return ErrMemoryLimit
+	case limits.CodeCancelled:
+		return context.Canceled
+	case limits.CodeTimeout:
+		return context.DeadlineExceeded
+	}
+	return nil
+}
+
+func newCompileError(reports []logerror.Report) *CompileError {
+	err := &CompileError{}
+	for _, report := range reports {
+		if !report.Runtime {
+			err.Diagnostics = append(err.Diagnostics, Diagnostic{
+				Line:     report.Line,
+				Where:    report.Where,
+				Message:  report.Message,
+				Position: reportPosition(report),
+			})
+		}
+	}
+	return err
+}
+
+func newRuntimeError(reports []logerror.Report) *RuntimeError {
+	for _, report := range reports {
+		if report.Runtime {
+			return &RuntimeError{Line: report.Line, Message: report.Message, Code: report.Code, Position: reportPosition(report)}
+		}
+	}
+	return &RuntimeError{}
+}
+
+// tokenRuntimeError describes an error raised at token the way the runtime
+// errors reported by a run are described.
+func tokenRuntimeError(token ast.Token, message string, code string) *RuntimeError {
+	report := logerror.Report{Line: token.Line}
+	report.Locate(token)
+	return &RuntimeError{Line: report.Line, Message: message, Code: code, Position: reportPosition(report)}
+}
+
+func reportPosition(report logerror.Report) Position {
+	return Position{File: report.File, Column: report.Column, EndLine: report.EndLine, EndColumn: report.EndColumn}
+}
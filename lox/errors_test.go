@@ -0,0 +1,102 @@
+package lox
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestErrorPositions(t *testing.T) {
+	dir := t.TempDir()
+	module := "fun fail() {\n  return nil - 1;\n}\n"
+	if err := os.WriteFile(filepath.Join(dir, "failing.lox"), []byte(module), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		source string
+		want   Position
+		line   int
+	}{
+		"runtime error": {
+			source: "var a = 1;\nprint a + \"x\";",
+			want:   Position{Column: 9, EndLine: 2, EndColumn: 10},
+			line:   2,
+		},
+		"error in an imported file": {
+			source: "import \"failing.lox\" as failing;\nfailing.fail();",
+			want:   Position{File: filepath.Join(dir, "failing.lox"), Column: 14, EndLine: 2, EndColumn: 15},
+			line:   2,
+		},
+		"error re-raised from a callback at its call": {
+			source: "call(fun () { return -\"x\"; });",
+			want:   Position{Column: 29, EndLine: 1, EndColumn: 30},
+			line:   1,
+		},
+	}
+
+	for _, backend := range []Backend{BackendTreeWalker, BackendVM} {
+		for name, test := range tests {
+			t.Run(string(backend)+"/"+name, func(t *testing.T) {
+				vm := NewVM(Options{Backend: backend, SearchPath: []string{dir}})
+				if err := vm.Register("call", func(fn *Function) (Value, error) {
+					_, err := fn.Call()
+					return nil, err
+				}); err != nil {
+					t.Fatal(err)
+				}
+
+				err := vm.Run(context.Background(), test.source)
+				var runtimeError *RuntimeError
+				if !errors.As(err, &runtimeError) {
+					t.Fatalf("Run() = %v, want a runtime error", err)
+				}
+				if runtimeError.Line != test.line || runtimeError.Position != test.want {
+					t.Errorf("error at line %d %+v, want line %d %+v", runtimeError.Line, runtimeError.Position, test.line, test.want)
+				}
+			})
+		}
+	}
+}
+
+func TestDiagnosticPositions(t *testing.T) {
+	err := NewVM(Options{}).Run(context.Background(), "var a = 1;\nprint a +;")
+
+	var compileError *CompileError
+	if !errors.As(err, &compileError) || len(compileError.Diagnostics) != 1 {
+		t.Fatalf("Run() = %v, want one diagnostic", err)
+	}
+	diagnostic := compileError.Diagnostics[0]
+	want := Position{Column: 10, EndLine: 2, EndColumn: 11}
+	if diagnostic.Line != 2 || diagnostic.Position != want {
+		t.Errorf("diagnostic at line %d %+v, want line 2 %+v", diagnostic.Line, diagnostic.Position, want)
+	}
+}
+
+func TestFunctionCallErrorPosition(t *testing.T) {
+	for _, backend := range []Backend{BackendTreeWalker, BackendVM} {
+		t.Run(string(backend), func(t *testing.T) {
+			vm := NewVM(Options{Backend: backend})
+			value, err := vm.Eval("fun f() {\n  return -\"x\";\n}\nf;")
+			if err != nil {
+				t.Fatal(err)
+			}
+			function, ok := value.(*Function)
+			if !ok {
+				t.Fatalf("Eval() = %v, want a function", value)
+			}
+
+			_, err = function.Call()
+			var runtimeError *RuntimeError
+			if !errors.As(err, &runtimeError) {
+				t.Fatalf("Call() = %v, want a runtime error", err)
+			}
+			want := Position{Column: 10, EndLine: 2, EndColumn: 11}
+			if runtimeError.Line != 2 || runtimeError.Position != want {
+				t.Errorf("error at line %d %+v, want line 2 %+v", runtimeError.Line, runtimeError.Position, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package lox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestMemoryLimitCountsCollectionGrowth(t *testing.T) {
+	programs := map[string]string{
+		"push":      "var l = []; while (true) { push(l, 1); }",
+		"set index": "var m = {}; var i = 0; while (true) { m[i] = i; i = i + 1; }",
+		"slice":     "var l = [1, 2, 3, 4, 5, 6, 7, 8]; while (true) { slice(l, 0, 8); }",
+		"keys":      "var m = {1: 1, 2: 2, 3: 3}; while (true) { keys(m); values(m); }",
+	}
+
+	for _, backend := range []Backend{BackendTreeWalker, BackendVM} {
+		for name, source := range programs {
+			t.Run(string(backend)+"/"+name, func(t *testing.T) {
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				defer cancel()
+
+				vm := NewVM(Options{Backend: backend, Limits: Limits{MaxMemory: 10000}})
+				if err := vm.Run(ctx, source); !errors.Is(err, ErrMemoryLimit) {
+					t.Fatalf("Run() = %v, want %v", err, ErrMemoryLimit)
+				}
+			})
+		}
+	}
+}
+
+func TestStepLimit(t *testing.T) {
+	programs := map[string]string{
+		"loop":       "while (true) {}",
+		"recursion":  "fun f(n) { if (n > 0) return f(n - 1); return n; } f(1000);",
+		"statements": "print 1; print 2; print 3; print 4; print 5; print 6;",
+	}
+
+	for _, backend := range []Backend{BackendTreeWalker, BackendVM} {
+		for name, source := range programs {
+			t.Run(string(backend)+"/"+name, func(t *testing.T) {
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				defer cancel()
+
+				vm := NewVM(Options{Backend: backend, Stdout: io.Discard, Limits: Limits{MaxSteps: 5}})
+				if err := vm.Run(ctx, source); !errors.Is(err, ErrStepLimit) {
+					t.Fatalf("Run() = %v, want %v", err, ErrStepLimit)
+				}
+			})
+		}
+	}
+}
+
+// TestVMUsableAfterLimit checks that a run stopped by a limit leaves the VM
+// ready for the next one.
+func TestVMUsableAfterLimit(t *testing.T) {
+	programs := map[string]string{
+		"subclass": "class A {} class B < A { m() {} n() {} }",
+		"block":    "{ var a = [1, 2, 3]; { var b = [a, a, a, a]; } }",
+		"function": "fun f() { return [1, 2, 3, 4, 5, 6]; } f();",
+	}
+
+	for _, backend := range []Backend{BackendTreeWalker, BackendVM} {
+		for name, source := range programs {
+			t.Run(string(backend)+"/"+name, func(t *testing.T) {
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				defer cancel()
+
+				var stdout bytes.Buffer
+				vm := NewVM(Options{Backend: backend, Stdout: &stdout, Limits: Limits{MaxMemory: 200}})
+				if err := vm.Run(ctx, source); !errors.Is(err, ErrMemoryLimit) {
+					t.Fatalf("Run() = %v, want %v", err, ErrMemoryLimit)
+				}
+				if err := vm.Run(ctx, "var x = 1; print x;"); err != nil {
+					t.Fatalf("Run() after the limit = %v", err)
+				}
+				if got := stdout.String(); got != "1\n" {
+					t.Errorf("output = %q, want %q", got, "1\n")
+				}
+			})
+		}
+	}
+}
+
+// TestContextStopsLoop checks that a run returns promptly with the error of
+// its context once that is cancelled or times out.
+func TestContextStopsLoop(t *testing.T) {
+	contexts := map[string]struct {
+		context func() (context.Context, context.CancelFunc)
+		want    error
+	}{
+		"cancel": {
+			func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(50*time.Millisecond, cancel)
+				return ctx, cancel
+			},
+			context.Canceled,
+		},
+		"timeout": {
+			func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 50*time.Millisecond)
+			},
+			context.DeadlineExceeded,
+		},
+	}
+
+	for _, backend := range []Backend{BackendTreeWalker, BackendVM} {
+		for name, test := range contexts {
+			t.Run(string(backend)+"/"+name, func(t *testing.T) {
+				ctx, cancel := test.context()
+				defer cancel()
+
+				done := make(chan error, 1)
+				go func() {
+					done <- NewVM(Options{Backend: backend}).Run(ctx, "while (true) {}")
+				}()
+
+				select {
+				case err := <-done:
+					if !errors.Is(err, test.want) {
+						t.Fatalf("Run() = %v, want %v", err, test.want)
+					}
+				case <-time.After(5 * time.Second):
+					t.Fatal("Run() did not return after its context was done")
+				}
+			})
+		}
+	}
+}
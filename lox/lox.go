@@ -0,0 +1,185 @@
+// Package lox embeds the Lox interpreter in Go programs.
+package lox
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/distolma/golox/cmd/myinterpreter/ast"
+	"github.com/distolma/golox/cmd/myinterpreter/compiler"
+	"github.com/distolma/golox/cmd/myinterpreter/interpreter"
+	"github.com/distolma/golox/cmd/myinterpreter/limits"
+	logerror "github.com/distolma/golox/cmd/myinterpreter/log_error"
+	"github.com/distolma/golox/cmd/myinterpreter/parser"
+	"github.com/distolma/golox/cmd/myinterpreter/resolver"
+	"github.com/distolma/golox/cmd/myinterpreter/scanner"
+	"github.com/distolma/golox/cmd/myinterpreter/vm"
+)
+
+type Backend string
+
+const (
+	BackendTreeWalker Backend = "tree"
+	BackendVM         Backend = "vm"
+)
+
+// Value is a Lox value: nil, bool, float64, string, []Value for a list,
+// map[Value]Value for a map, or an opaque callable, class or instance.
+type Value = interface{}
+
+// Limits bounds the steps, call depth and approximate memory of each run.
+// Zero fields mean no limit, except for call depth, which has a default.
+type Limits = limits.Limits
+
+type Options struct {
+	// Stdout receives the output of print statements. Defaults to os.Stdout.
+	Stdout io.Writer
+	// Diagnostics receives error messages formatted as the command line
+	// prints them. Defaults to io.Discard; errors are also returned.
+	Diagnostics io.Writer
+	// Backend selects the execution engine. Defaults to BackendTreeWalker.
+	Backend Backend
+	// Limits bounds the resources each Eval or Run may use.
+	Limits Limits
+	// SearchPath lists the directories searched for imported files after the
+	// working directory.
+	SearchPath []string
+}
+
+// VM runs Lox source. Globals persist between calls. A VM is not safe for
+// concurrent use.
+type VM struct {
+	backend     Backend
+	log         *logerror.LogError
+	interpreter *interpreter.Interpreter
+	vm          *vm.VM
+}
+
+func NewVM(options Options) *VM {
+	if options.Stdout == nil {
+		options.Stdout = os.Stdout
+	}
+	if options.Diagnostics == nil {
+		options.Diagnostics = io.Discard
+	}
+	if options.Backend == "" {
+		options.Backend = BackendTreeWalker
+	}
+
+	log := &logerror.LogError{Output: options.Diagnostics}
+
+	treeWalker := interpreter.NewInterpreter(log)
+	treeWalker.SetStdout(options.Stdout)
+	treeWalker.SetLimits(options.Limits)
+
+	bytecode := vm.NewVM(log)
+	bytecode.SetStdout(options.Stdout)
+	bytecode.SetLimits(options.Limits)
+
+	v := &VM{
+		backend:     options.Backend,
+		log:         log,
+		interpreter: treeWalker,
+		vm:          bytecode,
+	}
+
+	treeWalker.SetSearchPath(options.SearchPath)
+	treeWalker.SetModuleLoader(v.loadModule)
+	bytecode.SetSearchPath(options.SearchPath)
+	bytecode.SetModuleLoader(v.compileModule)
+	return v
+}
+
+// Eval runs source and returns the value of its final statement if that is
+// an expression statement, or nil otherwise.
+func (v *VM) Eval(source string) (Value, error) {
+	return v.execute(context.Background(), source, true)
+}
+
+// Run runs source to completion, or until ctx is done, in which case the
+// returned error matches ctx.Err() under errors.Is.
+func (v *VM) Run(ctx context.Context, source string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	_, err := v.execute(ctx, source, false)
+	return err
+}
+
+func (v *VM) execute(ctx context.Context, source string, eval bool) (Value, error) {
+	v.log.Reset()
+
+	statements := v.parse("", source)
+	if v.log.HadError {
+		return nil, newCompileError(v.log.Reports)
+	}
+
+	var result Value
+	if v.backend == BackendVM {
+		var function *compiler.Function
+		if eval {
+			function = compiler.NewCompiler(v.log).CompileEval(statements)
+		} else {
+			function = compiler.NewCompiler(v.log).Compile(statements)
+		}
+
+		if v.log.HadError {
+			return nil, newCompileError(v.log.Reports)
+		}
+
+		result = v.vm.Interpret(ctx, function)
+	} else if eval {
+		result = v.interpreter.Eval(ctx, statements)
+	} else {
+		v.interpreter.Interpret(ctx, statements)
+	}
+
+	// Imported files can have syntax errors too.
+	if v.log.HadError {
+		return nil, newCompileError(v.log.Reports)
+	}
+	if v.log.HadRuntimeError {
+		return nil, newRuntimeError(v.log.Reports)
+	}
+
+	return v.fromLox(result), nil
+}
+
+func (v *VM) parse(name string, source string) []ast.Stmt {
+	scanner := scanner.NewFileScanner(name, source, v.log)
+	tokens := scanner.ScanTokens()
+
+	parser := parser.NewParser(tokens, v.log)
+	statements := parser.Parse()
+
+	if v.log.HadError {
+		return nil
+	}
+
+	resolver := resolver.NewResolver(v.interpreter, v.log)
+	resolver.ResolveStmts(statements)
+
+	return statements
+}
+
+func (v *VM) loadModule(path string) ([]ast.Stmt, bool) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	statements := v.parse(path, string(source))
+	return statements, !v.log.HadError
+}
+
+func (v *VM) compileModule(path string) (*compiler.Function, bool) {
+	statements, ok := v.loadModule(path)
+	if !ok {
+		return nil, false
+	}
+
+	function := compiler.NewCompiler(v.log).Compile(statements)
+	return function, !v.log.HadError
+}
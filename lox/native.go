@@ -0,0 +1,471 @@
+package lox
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/distolma/golox/cmd/myinterpreter/collection"
+	"github.com/distolma/golox/cmd/myinterpreter/interpreter"
+	"github.com/distolma/golox/cmd/myinterpreter/vm"
+)
+
+// Function is a Lox function, method, class or native handed to Go code.
+type Function struct {
+	vm    *VM
+	value interface{}
+}
+
+// Call invokes the function with the given arguments, converted as for the
+// results of a registered host function.
+func (f *Function) Call(arguments ...Value) (Value, error) {
+	converted := make([]interface{}, len(arguments))
+	for i, argument := range arguments {
+		value, err := f.vm.toLox(reflect.ValueOf(argument))
+		if err != nil {
+			return nil, err
+		}
+		converted[i] = value
+	}
+
+	var result interface{}
+	var err error
+	if f.vm.backend == BackendVM {
+		result, err = f.vm.vm.Call(f.value, converted)
+	} else {
+		result, err = f.vm.interpreter.Call(f.value, converted)
+	}
+
+	var interpreterError *interpreter.RuntimeError
+	var vmError *vm.RuntimeError
+	switch {
+	case errors.As(err, &interpreterError):
+		return nil, tokenRuntimeError(interpreterError.Token, interpreterError.Message, interpreterError.Code)
+	case errors.As(err, &vmError):
+		return nil, tokenRuntimeError(vmError.Token, vmError.Message, vmError.Code)
+	case err != nil:
+		return nil, &RuntimeError{Message: err.Error()}
+	}
+
+	return f.vm.fromLox(result), nil
+}
+
+func (f *Function) String() string {
+	return fmt.Sprint(f.value)
+}
+
+// Instance is an instance of a Lox class handed to Go code.
+type Instance struct {
+	vm    *VM
+	value interface{}
+}
+
+func (i *Instance) ClassName() string {
+	switch instance := i.value.(type) {
+	case *interpreter.Instance:
+		return instance.ClassName()
+	case *vm.Instance:
+		return instance.Class.Name
+	}
+	return ""
+}
+
+// Get returns the field or bound method called name.
+func (i *Instance) Get(name string) (Value, bool) {
+	switch instance := i.value.(type) {
+	case *interpreter.Instance:
+		value, ok := instance.Property(name)
+		return i.vm.fromLox(value), ok
+	case *vm.Instance:
+		if value, ok := instance.Fields[name]; ok {
+			return i.vm.fromLox(value), true
+		}
+		if method, ok := instance.Class.Methods[name]; ok {
+			return i.vm.fromLox(&vm.BoundMethod{Receiver: instance, Method: method}), true
+		}
+	}
+	return nil, false
+}
+
+// Set stores value in the field called name.
+func (i *Instance) Set(name string, value Value) error {
+	converted, err := i.vm.toLox(reflect.ValueOf(value))
+	if err != nil {
+		return err
+	}
+
+	switch instance := i.value.(type) {
+	case *interpreter.Instance:
+		instance.SetField(name, converted)
+	case *vm.Instance:
+		instance.Fields[name] = converted
+	}
+	return nil
+}
+
+func (i *Instance) String() string {
+	return fmt.Sprint(i.value)
+}
+
+var (
+	errorType    = reflect.TypeOf((*error)(nil)).Elem()
+	functionType = reflect.TypeOf((*Function)(nil))
+	instanceType = reflect.TypeOf((*Instance)(nil))
+)
+
+// Register defines a global called name that calls fn, which must be a Go
+// function. Parameters may be booleans, strings, any numeric type,
+// *Function, *Instance, interface{}, or slices and maps of these, which
+// receive Lox lists and maps; a variadic final parameter accepts any number
+// of trailing arguments. fn may return nothing, a value, an error, or a
+// value and an error. A non-nil error is raised as a runtime error at the
+// call.
+func (v *VM) Register(name string, fn interface{}) error {
+	function := reflect.ValueOf(fn)
+	if function.Kind() != reflect.Func {
+		return fmt.Errorf("cannot register %T as a native: not a function", fn)
+	}
+
+	signature := function.Type()
+	params := make([]reflect.Type, signature.NumIn())
+	for i := range params {
+		params[i] = signature.In(i)
+		if signature.IsVariadic() && i == len(params)-1 {
+			params[i] = params[i].Elem()
+		}
+
+		if !isSupportedType(params[i]) {
+			return fmt.Errorf("cannot register %s as a native: unsupported parameter type %s", name, params[i])
+		}
+	}
+
+	switch signature.NumOut() {
+	case 0:
+	case 1:
+		if result := signature.Out(0); result != errorType && !isSupportedType(result) {
+			return fmt.Errorf("cannot register %s as a native: unsupported result type %s", name, result)
+		}
+	case 2:
+		if result := signature.Out(0); !isSupportedType(result) {
+			return fmt.Errorf("cannot register %s as a native: unsupported result type %s", name, result)
+		}
+		if signature.Out(1) != errorType {
+			return fmt.Errorf("cannot register %s as a native: second result must be an error", name)
+		}
+	default:
+		return fmt.Errorf("cannot register %s as a native: too many results", name)
+	}
+
+	arity := len(params)
+	if signature.IsVariadic() {
+		arity = interpreter.VariadicArity
+	}
+
+	native := func(arguments []interface{}) (interface{}, error) {
+		if signature.IsVariadic() && len(arguments) < len(params)-1 {
+			return nil, fmt.Errorf("Expected at least %d arguments but got %d.", len(params)-1, len(arguments))
+		}
+
+		in := make([]reflect.Value, len(arguments))
+		for i, argument := range arguments {
+			param := params[min(i, len(params)-1)]
+
+			converted, err := v.toGo(argument, param)
+			if err != nil {
+				return nil, fmt.Errorf("Argument %d to '%s': %v.", i+1, name, err)
+			}
+			in[i] = converted
+		}
+
+		return v.results(function.Call(in))
+	}
+
+	v.interpreter.DefineNative(name, arity, native)
+	v.vm.DefineNative(name, arity, native)
+	return nil
+}
+
+func isSupportedType(t reflect.Type) bool {
+	if t == functionType || t == instanceType {
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Interface:
+		return t.NumMethod() == 0
+	case reflect.Slice:
+		return isSupportedType(t.Elem())
+	case reflect.Map:
+		return isSupportedType(t.Key()) && isSupportedType(t.Elem())
+	}
+	return false
+}
+
+func (v *VM) results(out []reflect.Value) (interface{}, error) {
+	if len(out) > 0 && out[len(out)-1].Type() == errorType {
+		if err := out[len(out)-1]; !err.IsNil() {
+			// A failed callback is re-raised at this call, so its line is
+			// replaced by the line of the native call.
+			var runtimeError *RuntimeError
+			if errors.As(err.Interface().(error), &runtimeError) {
+				return nil, errors.New(runtimeError.Message)
+			}
+			return nil, err.Interface().(error)
+		}
+		out = out[:len(out)-1]
+	}
+
+	if len(out) == 0 {
+		return nil, nil
+	}
+	return v.toLox(out[0])
+}
+
+// toGo converts a Lox value into a Go value of type t.
+func (v *VM) toGo(value interface{}, t reflect.Type) (reflect.Value, error) {
+	switch {
+	case t == functionType:
+		if !v.isCallable(value) {
+			return reflect.Value{}, fmt.Errorf("expected a function but got %s", typeName(value))
+		}
+		return reflect.ValueOf(&Function{vm: v, value: value}), nil
+	case t == instanceType:
+		if !isInstance(value) {
+			return reflect.Value{}, fmt.Errorf("expected an instance but got %s", typeName(value))
+		}
+		return reflect.ValueOf(&Instance{vm: v, value: value}), nil
+	case t.Kind() == reflect.Interface:
+		if value == nil {
+			return reflect.Zero(t), nil
+		}
+		return reflect.ValueOf(v.fromLox(value)), nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		if b, ok := value.(bool); ok {
+			return reflect.ValueOf(b).Convert(t), nil
+		}
+		return reflect.Value{}, fmt.Errorf("expected a boolean but got %s", typeName(value))
+	case reflect.String:
+		if s, ok := value.(string); ok {
+			return reflect.ValueOf(s).Convert(t), nil
+		}
+		return reflect.Value{}, fmt.Errorf("expected a string but got %s", typeName(value))
+	case reflect.Slice:
+		list, ok := value.(*collection.List)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("expected a list but got %s", typeName(value))
+		}
+
+		slice := reflect.MakeSlice(t, len(list.Elements), len(list.Elements))
+		for i, element := range list.Elements {
+			converted, err := v.toGo(element, t.Elem())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("element %d: %v", i, err)
+			}
+			slice.Index(i).Set(converted)
+		}
+		return slice, nil
+	case reflect.Map:
+		m, ok := value.(*collection.Map)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("expected a map but got %s", typeName(value))
+		}
+
+		result := reflect.MakeMapWithSize(t, m.Len())
+		for _, key := range m.Keys() {
+			convertedKey, err := v.toGo(key, t.Key())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("key %v: %v", key, err)
+			}
+
+			element, _ := m.Get(key)
+			convertedElement, err := v.toGo(element, t.Elem())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("value of key %v: %v", key, err)
+			}
+			result.SetMapIndex(convertedKey, convertedElement)
+		}
+		return result, nil
+	}
+
+	number, ok := value.(float64)
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("expected a number but got %s", typeName(value))
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if number != math.Trunc(number) || number < math.MinInt64 || number >= math.MaxInt64 ||
+			reflect.Zero(t).OverflowInt(int64(number)) {
+			return reflect.Value{}, fmt.Errorf("expected an integer in range but got %v", number)
+		}
+		return reflect.ValueOf(int64(number)).Convert(t), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if number != math.Trunc(number) || number < 0 || number >= math.MaxUint64 ||
+			reflect.Zero(t).OverflowUint(uint64(number)) {
+			return reflect.Value{}, fmt.Errorf("expected a non-negative integer in range but got %v", number)
+		}
+		return reflect.ValueOf(uint64(number)).Convert(t), nil
+	default:
+		return reflect.ValueOf(number).Convert(t), nil
+	}
+}
+
+// toLox converts a Go value into a Lox value.
+func (v *VM) toLox(value reflect.Value) (interface{}, error) {
+	if !value.IsValid() {
+		return nil, nil
+	}
+
+	switch value.Type() {
+	case functionType:
+		if value.IsNil() {
+			return nil, nil
+		}
+		return value.Interface().(*Function).value, nil
+	case instanceType:
+		if value.IsNil() {
+			return nil, nil
+		}
+		return value.Interface().(*Instance).value, nil
+	}
+
+	switch value.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if value.IsNil() {
+			return nil, nil
+		}
+		if value.Kind() == reflect.Interface {
+			return v.toLox(value.Elem())
+		}
+	case reflect.Bool:
+		return value.Bool(), nil
+	case reflect.String:
+		return value.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), nil
+	case reflect.Slice, reflect.Array:
+		elements := make([]interface{}, value.Len())
+		for i := range elements {
+			element, err := v.toLox(value.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = element
+		}
+		return collection.NewList(elements), nil
+	case reflect.Map:
+		m := collection.NewMap()
+		iterator := value.MapRange()
+		for iterator.Next() {
+			key, err := v.toLox(iterator.Key())
+			if err != nil {
+				return nil, err
+			}
+			element, err := v.toLox(iterator.Value())
+			if err != nil {
+				return nil, err
+			}
+			if err := m.Set(key, element); err != nil {
+				return nil, errors.New("cannot convert Go map key of type " + iterator.Key().Type().String() + " to a Lox map key")
+			}
+		}
+		return m, nil
+	}
+
+	return nil, errors.New("cannot convert Go value of type " + value.Type().String() + " to a Lox value")
+}
+
+// fromLox wraps callables and instances so Go code can use them and copies
+// lists into []Value and maps into map[Value]Value; other values are passed
+// through unchanged.
+func (v *VM) fromLox(value interface{}) Value {
+	return v.fromLoxSeen(value, make(map[interface{}]Value))
+}
+
+// fromLoxSeen converts value, reusing the copies in seen so that collections
+// that contain themselves are converted once.
+func (v *VM) fromLoxSeen(value interface{}, seen map[interface{}]Value) Value {
+	switch container := value.(type) {
+	case *collection.List:
+		if elements, ok := seen[container]; ok {
+			return elements
+		}
+
+		elements := make([]Value, len(container.Elements))
+		seen[container] = elements
+		for i, element := range container.Elements {
+			elements[i] = v.fromLoxSeen(element, seen)
+		}
+		return elements
+	case *collection.Map:
+		if entries, ok := seen[container]; ok {
+			return entries
+		}
+
+		entries := make(map[Value]Value, container.Len())
+		seen[container] = entries
+		for _, key := range container.Keys() {
+			element, _ := container.Get(key)
+			entries[key] = v.fromLoxSeen(element, seen)
+		}
+		return entries
+	}
+
+	if v.isCallable(value) {
+		return &Function{vm: v, value: value}
+	}
+	if isInstance(value) {
+		return &Instance{vm: v, value: value}
+	}
+	return value
+}
+
+func (v *VM) isCallable(value interface{}) bool {
+	return interpreter.IsCallable(value) || vm.IsCallable(value)
+}
+
+func isInstance(value interface{}) bool {
+	switch value.(type) {
+	case *interpreter.Instance, *vm.Instance:
+		return true
+	}
+	return false
+}
+
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "a boolean"
+	case float64:
+		return "a number"
+	case string:
+		return "a string"
+	case *collection.List:
+		return "a list"
+	case *collection.Map:
+		return "a map"
+	}
+
+	if interpreter.IsCallable(value) || vm.IsCallable(value) {
+		return "a function"
+	}
+	if isInstance(value) {
+		return "an instance"
+	}
+	return fmt.Sprintf("%T", value)
+}
@@ -0,0 +1,224 @@
+package lox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegister(t *testing.T) {
+	sum := func(xs []int) int {
+		total := 0
+		for _, x := range xs {
+			total += x
+		}
+		return total
+	}
+
+	tests := []struct {
+		name   string
+		fn     interface{}
+		source string
+		want   string
+		// err is the message of the runtime error the source fails with.
+		err string
+	}{
+		{
+			name:   "fixed arity",
+			fn:     func(a, b float64) float64 { return a + b },
+			source: "print f(1, 2);",
+			want:   "3\n",
+		},
+		{
+			name:   "too few arguments",
+			fn:     func(a, b float64) float64 { return a + b },
+			source: "f(1);",
+			err:    "Expected 2 arguments but got 1.",
+		},
+		{
+			name:   "too many arguments",
+			fn:     func() {},
+			source: "f(1);",
+			err:    "Expected 0 arguments but got 1.",
+		},
+		{
+			name:   "variadic",
+			fn:     func(separator string, parts ...string) string { return strings.Join(parts, separator) },
+			source: `print f("-", "a", "b", "c"); print f("-");`,
+			want:   "a-b-c\n\n",
+		},
+		{
+			name:   "variadic without its fixed arguments",
+			fn:     func(separator string, parts ...string) string { return strings.Join(parts, separator) },
+			source: "f();",
+			err:    "Expected at least 1 arguments but got 0.",
+		},
+		{
+			name:   "wrong variadic argument",
+			fn:     func(parts ...string) int { return len(parts) },
+			source: `f("a", 2);`,
+			err:    "Argument 2 to 'f': expected a string but got a number.",
+		},
+		{
+			name:   "integer",
+			fn:     func(n int) int { return n * 2 },
+			source: "print f(-21);",
+			want:   "-42\n",
+		},
+		{
+			name:   "integer overflow",
+			fn:     func(n int8) int8 { return n },
+			source: "f(128);",
+			err:    "Argument 1 to 'f': expected an integer in range but got 128.",
+		},
+		{
+			name:   "negative unsigned integer",
+			fn:     func(n uint) uint { return n },
+			source: "f(-1);",
+			err:    "Argument 1 to 'f': expected a non-negative integer in range but got -1.",
+		},
+		{
+			name:   "fractional integer",
+			fn:     func(n int) int { return n },
+			source: "f(1.5);",
+			err:    "Argument 1 to 'f': expected an integer in range but got 1.5.",
+		},
+		{
+			name:   "float",
+			fn:     func(x float32) float32 { return x / 2 },
+			source: "print f(3);",
+			want:   "1.5\n",
+		},
+		{
+			name:   "slice argument",
+			fn:     sum,
+			source: "print f([1, 2, 3]);",
+			want:   "6\n",
+		},
+		{
+			name:   "bad slice element",
+			fn:     sum,
+			source: `f([1, "2"]);`,
+			err:    "Argument 1 to 'f': element 1: expected a number but got a string.",
+		},
+		{
+			name:   "slice result",
+			fn:     func() []string { return []string{"a", "b"} },
+			source: `var l = f(); push(l, "c"); print l; print len(l);`,
+			want:   "[\"a\", \"b\", \"c\"]\n3\n",
+		},
+		{
+			name: "map argument",
+			fn: func(m map[string]int) int {
+				return m["a"] + m["b"]
+			},
+			source: `print f({"a": 1, "b": 2});`,
+			want:   "3\n",
+		},
+		{
+			name:   "bad map key",
+			fn:     func(m map[string]int) int { return len(m) },
+			source: `f({1: 1});`,
+			err:    "Argument 1 to 'f': key 1: expected a string but got a number.",
+		},
+		{
+			name:   "map result",
+			fn:     func() map[string][]int { return map[string][]int{"xs": {1, 2}} },
+			source: `print f()["xs"][1];`,
+			want:   "2\n",
+		},
+		{
+			name:   "callback",
+			fn:     func(fn *Function, x float64) (Value, error) { return fn.Call(x) },
+			source: "print f(fun (n) { return n + 1; }, 41); print f((n) => [n], 1);",
+			want:   "42\n[1]\n",
+		},
+		{
+			name:   "callback that fails",
+			fn:     func(fn *Function) (Value, error) { return fn.Call() },
+			source: `f(fun () { return 1 + "a"; });`,
+			err:    "Operands must be two numbers or two strings.",
+		},
+		{
+			name:   "callback that is not a function",
+			fn:     func(fn *Function) (Value, error) { return fn.Call() },
+			source: "f(1);",
+			err:    "Argument 1 to 'f': expected a function but got a number.",
+		},
+		{
+			name:   "error result",
+			fn:     func() error { return errors.New("boom") },
+			source: "f();",
+			err:    "boom",
+		},
+		{
+			name:   "caught error result",
+			fn:     func() error { return errors.New("boom") },
+			source: "try { f(); } catch (e) { print e.message; }",
+			want:   "boom\n",
+		},
+		{
+			name: "value and error results",
+			fn: func(ok bool) (string, error) {
+				if !ok {
+					return "", errors.New("not ok")
+				}
+				return "ok", nil
+			},
+			source: "print f(true); f(false);",
+			want:   "ok\n",
+			err:    "not ok",
+		},
+	}
+
+	for _, backend := range []Backend{BackendTreeWalker, BackendVM} {
+		for _, test := range tests {
+			t.Run(string(backend)+"/"+test.name, func(t *testing.T) {
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				defer cancel()
+
+				var stdout bytes.Buffer
+				vm := NewVM(Options{Backend: backend, Stdout: &stdout})
+				if err := vm.Register("f", test.fn); err != nil {
+					t.Fatalf("Register() = %v", err)
+				}
+
+				err := vm.Run(ctx, test.source)
+				var runtimeError *RuntimeError
+				switch {
+				case test.err == "" && err != nil:
+					t.Errorf("Run() = %v, want no error", err)
+				case test.err != "" && !errors.As(err, &runtimeError):
+					t.Errorf("Run() = %v, want a runtime error", err)
+				case test.err != "" && runtimeError.Message != test.err:
+					t.Errorf("error message = %q, want %q", runtimeError.Message, test.err)
+				}
+				if got := stdout.String(); got != test.want {
+					t.Errorf("output = %q, want %q", got, test.want)
+				}
+			})
+		}
+	}
+}
+
+func TestRegisterRejectsUnsupportedFunctions(t *testing.T) {
+	functions := map[string]interface{}{
+		"not a function":        42,
+		"channel parameter":     func(chan int) {},
+		"struct result":         func() struct{} { return struct{}{} },
+		"second result":         func() (int, int) { return 0, 0 },
+		"too many results":      func() (int, int, error) { return 0, 0, nil },
+		"unsupported map value": func(map[string]chan int) {},
+	}
+
+	for name, fn := range functions {
+		t.Run(name, func(t *testing.T) {
+			if err := NewVM(Options{}).Register("f", fn); err == nil {
+				t.Error("Register() succeeded, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package lox
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestBackendParity runs every program in testdata/parity on both backends,
+// which must print the same output and fail with the same error.
+func TestBackendParity(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "parity", "*.lox"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no programs in testdata/parity")
+	}
+
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			source, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tree := runBackend(t, BackendTreeWalker, string(source))
+			vm := runBackend(t, BackendVM, string(source))
+			if tree != vm {
+				t.Errorf("backends differ\n--- tree\n%s\n--- vm\n%s", tree, vm)
+			}
+		})
+	}
+}
+
+// runBackend runs source on backend and returns its output followed by the
+// error it failed with, if any.
+func runBackend(t *testing.T, backend Backend, source string) string {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var stdout bytes.Buffer
+	vm := NewVM(Options{
+		Backend:    backend,
+		Stdout:     &stdout,
+		SearchPath: []string{filepath.Join("testdata", "parity")},
+	})
+	if err := vm.Run(ctx, source); err != nil {
+		fmt.Fprintf(&stdout, "error: %v\n", err)
+	}
+	return stdout.String()
+}
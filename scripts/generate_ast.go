@@ -12,22 +12,37 @@ func main() {
 		"Assign   : Value Expr, Name Token",
 		"Binary   : Left Expr, Right Expr, Operator Token",
 		"Call     : Callee Expr, Paren Token, Arguments []Expr",
+		"Get      : Object Expr, Name Token",
 		"Grouping : Expression Expr",
-		"Literal  : Value interface{}",
+		"Index    : Object Expr, Bracket Token, Index Expr",
+		"List     : Bracket Token, Elements []Expr",
+		"Lambda   : Keyword Token, Function *Function",
+		"Literal  : Token Token, Value interface{}",
 		"Logical  : Left Expr, Right Expr, Operator Token",
+		"Map      : Brace Token, Keys []Expr, Values []Expr",
+		"Set      : Object Expr, Name Token, Value Expr",
+		"SetIndex : Object Expr, Bracket Token, Index Expr, Value Expr",
+		"Super    : Keyword Token, Method Token",
+		"This     : Keyword Token",
 		"Unary    : Right Expr, Operator Token",
 		"Variable : Name Token",
 	},
 	)
 	defineAst("./cmd/myinterpreter/ast", "Stmt", []string{
-		"Block      : Statements []Stmt",
+		"Block      : Statements []Stmt, Brace Token",
+		"Break      : Keyword Token",
+		"Class      : Name Token, Superclass *Variable, Methods []*Function, Brace Token",
+		"Continue   : Keyword Token",
 		"Expression : Expression Expr",
-		"Function   : Name Token, Params []Token, Body []Stmt",
+		"Function   : Name Token, Params []Token, Body []Stmt, Brace Token",
 		"If         : Condition Expr, ThenBranch Stmt, ElseBranch Stmt",
-		"Print      : Expression Expr",
+		"Import     : Keyword Token, Path Token, Alias Token, Names []Token",
+		"Print      : Keyword Token, Expression Expr",
 		"Return     : Keyword Token, Value Expr",
+		"Throw      : Keyword Token, Value Expr",
+		"Try        : Keyword Token, Body *Block, Name Token, Catch *Block, Finally *Block",
 		"Var        : Initializer Expr, Name Token",
-		"While      : Condition Expr, Body Stmt",
+		"While      : Keyword Token, Condition Expr, Body Stmt, Increment Expr",
 	},
 	)
 }